@@ -1,9 +1,15 @@
 package main
 
 import (
+	"os"
+
 	"github.com/phonegapX/QuantBot/handler"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "download" {
+		handler.Download(os.Args[2:])
+		return
+	}
 	handler.Server()
 }
@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/nubo/jwt"
+	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/symbol"
 )
 
 const (
@@ -60,7 +62,9 @@ type TickerResp struct {
 	} `json:"data"`
 }
 
+// GetTicker the currencyPair can be a canonical symbol(eg: BTC/USDT) or a big.one market(eg: BTC-USDT)
 func (bo *Bigone) GetTicker(currencyPair string) (*TickerResp, error) {
+	currencyPair = symbol.Market(constant.BigOne, currencyPair)
 	var resp TickerResp
 	tickerURI := fmt.Sprintf(TICKER_URI, currencyPair)
 	err := HttpGet(bo.httpClient, tickerURI, nil, &resp)
@@ -97,6 +101,7 @@ type PlaceOrderResp struct {
 }
 
 func (bo *Bigone) placeOrder(amount, price string, currencyPair string, orderType, orderSide string) (*PlaceOrderResp, error) {
+	currencyPair = symbol.Market(constant.BigOne, currencyPair)
 	path := ORDERS_URI
 	params := make(map[string]string)
 	params["market_id"] = currencyPair
@@ -187,6 +192,7 @@ type OrderListResp struct {
 }
 
 func (bo *Bigone) getOrdersList(currencyPair string, size int, tpy int) (*OrderListResp, error) {
+	currencyPair = symbol.Market(constant.BigOne, currencyPair)
 	apiURL := ""
 	apiURL = fmt.Sprintf("%s?market_id=%s", ORDERS_URI, currencyPair)
 
@@ -313,6 +319,7 @@ type DepthResp struct {
 }
 
 func (bo *Bigone) GetDepth(currencyPair string) (*DepthResp, error) {
+	currencyPair = symbol.Market(constant.BigOne, currencyPair)
 	var resp DepthResp
 	apiURL := fmt.Sprintf(DEPTH_URI, currencyPair)
 	err := HttpGet(bo.httpClient, apiURL, nil, &resp)
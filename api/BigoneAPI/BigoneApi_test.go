@@ -0,0 +1,38 @@
+package BigoneAPI
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/api/replay"
+)
+
+func TestGetTicker(t *testing.T) {
+	player, err := replay.Load(filepath.Join("testdata", "bigone.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bo := New(&http.Client{Transport: player}, "access", "secret")
+	tests := []struct {
+		name   string
+		pair   string
+		bid    string
+		ask    string
+		errors int
+	}{
+		{name: "ticker", pair: "BTC-USDT", bid: "30000.5", ask: "30001.5"},
+		{name: "not found", pair: "NONE-USDT", errors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bo.GetTicker(tt.pair)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Data.Bid.Price != tt.bid || got.Data.Ask.Price != tt.ask || len(got.Errors) != tt.errors {
+				t.Errorf("GetTicker(%v) = %+v", tt.pair, got)
+			}
+		})
+	}
+}
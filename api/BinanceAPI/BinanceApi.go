@@ -10,7 +10,9 @@ import (
 
 	//"os"
 	"strconv"
-	"time"
+
+	"github.com/phonegapX/QuantBot/api/clock"
+	"github.com/phonegapX/QuantBot/constant"
 )
 
 const (
@@ -21,6 +23,8 @@ const (
 	TICKER_URI             = "ticker/24hr?symbol=%s"
 	TICKERS_URI            = "ticker/allBookTickers"
 	DEPTH_URI              = "depth?symbol=%s&limit=%d"
+	TIME_URI               = "time"
+	TRADES_URI             = "trades?symbol=%s&limit=%d"
 	ACCOUNT_URI            = "account?"
 	ORDER_URI              = "order?"
 	UNFINISHED_ORDERS_INFO = "openOrders?"
@@ -30,6 +34,7 @@ var (
 	ACCESS_KEY string       = ""
 	SECRET_KEY string       = ""
 	httpClient *http.Client = &http.Client{}
+	Clock      *clock.Clock = clock.Register(constant.Binance, GetServerTime)
 )
 
 func init() {
@@ -37,7 +42,7 @@ func init() {
 
 func buildParamsSigned(postForm *url.Values) error {
 	postForm.Set("recvWindow", "60000")
-	tonce := strconv.FormatInt(time.Now().UnixNano(), 10)[0:13]
+	tonce := strconv.FormatInt(Clock.Now().UnixNano(), 10)[0:13]
 	postForm.Set("timestamp", tonce)
 	payload := postForm.Encode()
 	sign, _ := GetParamHmacSHA256Sign(SECRET_KEY, payload)
@@ -45,6 +50,14 @@ func buildParamsSigned(postForm *url.Values) error {
 	return nil
 }
 
+func GetServerTime() (int64, error) {
+	resp, err := HttpGet(httpClient, API_V3+TIME_URI)
+	if err != nil {
+		return 0, err
+	}
+	return int64(ToUint64(resp["serverTime"])), nil
+}
+
 func GetDepth(size int, symbol string) (map[string]interface{}, error) {
 	if size > 100 {
 		size = 100
@@ -57,6 +70,17 @@ func GetDepth(size int, symbol string) (map[string]interface{}, error) {
 	return resp, err
 }
 
+func GetRecentTrades(limit int, symbol string) ([]interface{}, error) {
+	if limit > 1000 {
+		limit = 1000
+	} else if limit < 1 {
+		limit = 500
+	}
+
+	apiUrl := fmt.Sprintf(API_V3+TRADES_URI, symbol, limit)
+	return HttpGet3(httpClient, apiUrl, nil)
+}
+
 func GetAccount() (map[string]interface{}, error) {
 	params := url.Values{}
 	buildParamsSigned(&params)
@@ -0,0 +1,106 @@
+package BinanceAPI
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/api/replay"
+)
+
+func newTestPlayer(t *testing.T) *replay.Player {
+	player, err := replay.Load(filepath.Join("testdata", "binance.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := httpClient.Transport
+	httpClient.Transport = player
+	t.Cleanup(func() { httpClient.Transport = transport })
+	return player
+}
+
+func TestGetServerTime(t *testing.T) {
+	newTestPlayer(t)
+	got, err := GetServerTime()
+	if err != nil || got != 1700000000000 {
+		t.Errorf("GetServerTime() = %v, %v, want 1700000000000", got, err)
+	}
+}
+
+func TestGetDepth(t *testing.T) {
+	newTestPlayer(t)
+	tests := []struct {
+		name   string
+		size   int
+		symbol string
+		bids   []interface{}
+	}{
+		{name: "min size", size: 1, symbol: "BTCUSDT", bids: []interface{}{[]interface{}{"30000.50000000", "0.50000000"}}},
+		{name: "max size", size: 1000, symbol: "ETHUSDT", bids: []interface{}{[]interface{}{"2000.10000000", "3.00000000"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetDepth(tt.size, tt.symbol)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got["bids"], tt.bids) {
+				t.Errorf("GetDepth(%v, %v) bids = %v, want %v", tt.size, tt.symbol, got["bids"], tt.bids)
+			}
+		})
+	}
+}
+
+func TestGetRecentTrades(t *testing.T) {
+	newTestPlayer(t)
+	got, err := GetRecentTrades(2, "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("GetRecentTrades() returns %v trades, want 2", len(got))
+	}
+	if price := got[1].(map[string]interface{})["price"]; price != "30000.20" {
+		t.Errorf("GetRecentTrades()[1].price = %v, want 30000.20", price)
+	}
+}
+
+func TestGetAccount(t *testing.T) {
+	player := newTestPlayer(t)
+	ACCESS_KEY, SECRET_KEY = "access", "secret"
+	defer func() { ACCESS_KEY, SECRET_KEY = "", "" }()
+	got, err := GetAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got["balances"].([]interface{})) != 2 {
+		t.Errorf("GetAccount() balances = %v, want 2 assets", got["balances"])
+	}
+	for _, req := range player.Requests() {
+		if req.URL == "https://api.binance.com/api/v3/account?recvWindow=60000&signature=REDACTED&timestamp=REDACTED" {
+			if req.RequestHeader["X-Mbx-Apikey"] != replay.Redacted {
+				t.Errorf("X-MBX-APIKEY = %q, want %q", req.RequestHeader["X-Mbx-Apikey"], replay.Redacted)
+			}
+			return
+		}
+	}
+	t.Errorf("GetAccount() did not send a signed request")
+}
+
+func TestNewCurrencyPair2(t *testing.T) {
+	tests := []struct {
+		symbol string
+		want   string
+	}{
+		{symbol: "btc_usdt", want: "BTCUSDT"},
+		{symbol: "btc/usdt", want: "BTCUSDT"},
+		{symbol: "ETH/BTC", want: "ETHBTC"},
+		{symbol: "BTC/USDT:USDT", want: UNKNOWN_PAIR.ToSymbol("")},
+		{symbol: "BTCUSDT", want: UNKNOWN_PAIR.ToSymbol("")},
+	}
+	for _, tt := range tests {
+		if got := NewCurrencyPair2(tt.symbol).ToSymbol(""); got != tt.want {
+			t.Errorf("NewCurrencyPair2(%v) = %v, want %v", tt.symbol, got, tt.want)
+		}
+	}
+}
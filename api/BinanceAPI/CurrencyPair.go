@@ -1,6 +1,10 @@
 package BinanceAPI
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/phonegapX/QuantBot/symbol"
+)
 
 type Currency struct {
 	Symbol string
@@ -160,7 +164,15 @@ func NewCurrencyPair(currencyA Currency, currencyB Currency) CurrencyPair {
 	return CurrencyPair{currencyA, currencyB}
 }
 
+// NewCurrencyPair2 create a currency pair from btc_usdt or a canonical spot symbol, eg: BTC/USDT
 func NewCurrencyPair2(currencyPairSymbol string) CurrencyPair {
+	if strings.Contains(currencyPairSymbol, "/") {
+		sym, err := symbol.Parse(currencyPairSymbol)
+		if err != nil || sym.Type != symbol.Spot {
+			return UNKNOWN_PAIR
+		}
+		return CurrencyPair{NewCurrency(sym.Base, ""), NewCurrency(sym.Quote, "")}
+	}
 	currencys := strings.Split(currencyPairSymbol, "_")
 	if len(currencys) == 2 {
 		return CurrencyPair{NewCurrency(currencys[0], ""),
@@ -8,6 +8,8 @@ import (
 	"github.com/phonegapX/QuantBot/api/HuobiProAPI/config"
 	"github.com/phonegapX/QuantBot/api/HuobiProAPI/models"
 	"github.com/phonegapX/QuantBot/api/HuobiProAPI/untils"
+	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/symbol"
 )
 
 // 批量操作的API下个版本再封装
@@ -16,13 +18,13 @@ import (
 // 交易API
 
 // 获取K线数据
-// strSymbol: 交易对, btcusdt, bccbtc......
+// strSymbol: 交易对, btcusdt, bccbtc......, 也可以是 BTC/USDT
 // strPeriod: K线类型, 1min, 5min, 15min......
 // nSize: 获取数量, [1-2000]
 // return: KLineReturn 对象
 func GetKLine(strSymbol, strPeriod string, nSize int) (r models.KLineReturn, err error) {
 	mapParams := make(map[string]string)
-	mapParams["symbol"] = strSymbol
+	mapParams["symbol"] = symbol.Market(constant.Huobi, strSymbol)
 	mapParams["period"] = strPeriod
 	mapParams["size"] = strconv.Itoa(nSize)
 
@@ -36,11 +38,11 @@ func GetKLine(strSymbol, strPeriod string, nSize int) (r models.KLineReturn, err
 }
 
 // 获取聚合行情
-// strSymbol: 交易对, btcusdt, bccbtc......
+// strSymbol: 交易对, btcusdt, bccbtc......, 也可以是 BTC/USDT
 // return: TickReturn对象
 func GetTicker(strSymbol string) (r models.TickerReturn, err error) {
 	mapParams := make(map[string]string)
-	mapParams["symbol"] = strSymbol
+	mapParams["symbol"] = symbol.Market(constant.Huobi, strSymbol)
 
 	strRequestUrl := "/market/detail/merged"
 	strUrl := config.MARKET_URL + strRequestUrl
@@ -52,12 +54,12 @@ func GetTicker(strSymbol string) (r models.TickerReturn, err error) {
 }
 
 // 获取交易深度信息
-// strSymbol: 交易对, btcusdt, bccbtc......
+// strSymbol: 交易对, btcusdt, bccbtc......, 也可以是 BTC/USDT
 // strType: Depth类型, step0、step1......stpe5 (合并深度0-5, 0时不合并)
 // return: MarketDepthReturn对象
 func GetMarketDepth(strSymbol, strType string) (r models.MarketDepthReturn, err error) {
 	mapParams := make(map[string]string)
-	mapParams["symbol"] = strSymbol
+	mapParams["symbol"] = symbol.Market(constant.Huobi, strSymbol)
 	mapParams["type"] = strType
 
 	strRequestUrl := "/market/depth"
@@ -70,11 +72,11 @@ func GetMarketDepth(strSymbol, strType string) (r models.MarketDepthReturn, err
 }
 
 // 获取交易细节信息
-// strSymbol: 交易对, btcusdt, bccbtc......
+// strSymbol: 交易对, btcusdt, bccbtc......, 也可以是 BTC/USDT
 // return: TradeDetailReturn对象
 func GetTradeDetail(strSymbol string) (r models.TradeDetailReturn, err error) {
 	mapParams := make(map[string]string)
-	mapParams["symbol"] = strSymbol
+	mapParams["symbol"] = symbol.Market(constant.Huobi, strSymbol)
 
 	strRequestUrl := "/market/trade"
 	strUrl := config.MARKET_URL + strRequestUrl
@@ -86,12 +88,12 @@ func GetTradeDetail(strSymbol string) (r models.TradeDetailReturn, err error) {
 }
 
 // 批量获取最近的交易记录
-// strSymbol: 交易对, btcusdt, bccbtc......
+// strSymbol: 交易对, btcusdt, bccbtc......, 也可以是 BTC/USDT
 // nSize: 获取交易记录的数量, 范围1-2000
 // return: TradeReturn对象
 func GetTrade(strSymbol string, nSize int) (r models.TradeReturn, err error) {
 	mapParams := make(map[string]string)
-	mapParams["symbol"] = strSymbol
+	mapParams["symbol"] = symbol.Market(constant.Huobi, strSymbol)
 	mapParams["size"] = strconv.Itoa(nSize)
 
 	strRequestUrl := "/market/history/trade"
@@ -104,11 +106,11 @@ func GetTrade(strSymbol string, nSize int) (r models.TradeReturn, err error) {
 }
 
 // 获取Market Detail 24小时成交量数据
-// strSymbol: 交易对, btcusdt, bccbtc......
+// strSymbol: 交易对, btcusdt, bccbtc......, 也可以是 BTC/USDT
 // return: MarketDetailReturn对象
 func GetMarketDetail(strSymbol string) (r models.MarketDetailReturn, err error) {
 	mapParams := make(map[string]string)
-	mapParams["symbol"] = strSymbol
+	mapParams["symbol"] = symbol.Market(constant.Huobi, strSymbol)
 
 	strRequestUrl := "/market/detail"
 	strUrl := config.MARKET_URL + strRequestUrl
@@ -200,7 +202,7 @@ func Place(params models.PlaceRequestParams) (r models.PlaceReturn, err error) {
 	if 0 < len(params.Source) {
 		mapParams["source"] = params.Source
 	}
-	mapParams["symbol"] = params.Symbol
+	mapParams["symbol"] = symbol.Market(constant.Huobi, params.Symbol)
 	mapParams["type"] = params.Type
 
 	strRequest := "/v1/order/orders/place"
@@ -237,7 +239,7 @@ func GetOrderDetail(strOrderID string) (r models.OrderDetailReturn, err error) {
 func GetOrders(strSymbol string) (r models.OrdersReturn, err error) {
 	//pre-submitted 准备提交, submitted 已提交, partial-filled 部分成交, partial-canceled 部分成交撤销, filled 完全成交, canceled 已撤销
 	mapParams := make(map[string]string)
-	mapParams["symbol"] = strSymbol
+	mapParams["symbol"] = symbol.Market(constant.Huobi, strSymbol)
 	mapParams["states"] = "submitted,partial-filled"
 
 	strRequest := "/v1/order/orders"
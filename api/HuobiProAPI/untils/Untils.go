@@ -5,15 +5,18 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	//"os"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/phonegapX/QuantBot/api/HuobiProAPI/config"
+	"github.com/phonegapX/QuantBot/api/HuobiProAPI/models"
+	"github.com/phonegapX/QuantBot/api/clock"
+	"github.com/phonegapX/QuantBot/constant"
 	//"golang.org/x/net/proxy"
 )
 
@@ -130,13 +133,23 @@ func HttpPostRequest(strUrl string, mapParams map[string]string) string {
 	return string(body)
 }
 
+// 火币网服务器时钟, 签名时使用校正后的时间
+var Clock = clock.Register(constant.Huobi, func() (int64, error) {
+	var r models.TimestampReturn
+	err := json.Unmarshal([]byte(HttpGetRequest(config.TRADE_URL+"/v1/common/timestamp", nil)), &r)
+	if err == nil && r.Status != "ok" {
+		err = fmt.Errorf("%v %v", r.ErrCode, r.ErrMsg)
+	}
+	return r.Data, err
+})
+
 // 进行签名后的HTTP GET请求, 参考官方Python Demo写的
 // mapParams: map类型的请求参数, key:value
 // strRequest: API路由路径
 // return: 请求结果
 func ApiKeyGet(mapParams map[string]string, strRequestPath string) string {
 	strMethod := "GET"
-	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05")
+	timestamp := Clock.Now().UTC().Format("2006-01-02T15:04:05")
 
 	mapParams["AccessKeyId"] = config.ACCESS_KEY
 	mapParams["SignatureMethod"] = "HmacSHA256"
@@ -156,7 +169,7 @@ func ApiKeyGet(mapParams map[string]string, strRequestPath string) string {
 // return: 请求结果
 func ApiKeyPost(mapParams map[string]string, strRequestPath string) string {
 	strMethod := "POST"
-	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05")
+	timestamp := Clock.Now().UTC().Format("2006-01-02T15:04:05")
 
 	mapParams2Sign := make(map[string]string)
 	mapParams2Sign["AccessKeyId"] = config.ACCESS_KEY
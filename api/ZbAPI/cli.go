@@ -1,7 +1,13 @@
 package ZbAPI
 
 import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
 	"github.com/go-resty/resty"
+	"github.com/phonegapX/QuantBot/api/clock"
+	"github.com/phonegapX/QuantBot/constant"
 )
 
 type configure struct {
@@ -15,8 +21,27 @@ type configure struct {
 var (
 	Config                  configure
 	dataClient, tradeClient httpClient
+	// Clock 中币服务器时钟, 签名时使用校正后的时间
+	Clock = clock.Register(constant.Zb, serverTime)
 )
 
+// serverTime 行情接口返回的 date 是服务器时间, 不经过 resty 的钩子, 避免同步时钟时再签名
+func serverTime() (int64, error) {
+	resp, err := dataClient.GetClient().Get(Config.dataURL + "ticker?market=btc_usdt")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var res respTicker
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, err
+	}
+	if res.Date == "" {
+		return 0, fmt.Errorf("the server time is missing")
+	}
+	return strconv.ParseInt(res.Date, 10, 64)
+}
+
 func init() {
 
 	Config.ACCESS_KEY = ""
@@ -9,7 +9,6 @@ import (
 	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/go-resty/resty"
 )
@@ -62,7 +61,7 @@ func (client *httpClient) handleQueryParams() {
 	client.OnBeforeRequest(func(client *resty.Client, req *resty.Request) error {
 		client.SetQueryParams(map[string]string{
 			"accesskey": Config.ACCESS_KEY,
-			"reqTime":   strconv.FormatInt(time.Now().UnixNano()/1000000, 10),
+			"reqTime":   strconv.FormatInt(Clock.Now().UnixNano()/1000000, 10),
 		})
 		return nil
 	})
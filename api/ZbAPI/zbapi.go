@@ -5,6 +5,8 @@ import (
 	"fmt"
 
 	"github.com/mitchellh/mapstructure"
+	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/symbol"
 )
 
 // 市场深度
@@ -29,7 +31,9 @@ func depth(api, market, size string) (*respDepth, error) {
 	return &res, err
 }
 
+// GetDepth the market can be a canonical symbol(eg: BTC/USDT) or a zb market(eg: btc_usdt)
 func GetDepth(market, size string) (*respDepth, error) {
+	market = symbol.Market(constant.Zb, market)
 	return depth("depth", market, size)
 }
 
@@ -72,6 +76,11 @@ func trades(api, market string) *respTrades {
 	return &res
 }
 
+func GetTrades(market string) *respTrades {
+	market = symbol.Market(constant.Zb, market)
+	return trades("trades", market)
+}
+
 // 获取用户信息
 func accountInfo(api, sign string) (*respAccountInfo, error) {
 	resp, err := tradeClient.SetQueryParams(map[string]string{
@@ -122,6 +131,7 @@ func createOrder(api, amount, currency, tradeType, price, sign string) (*respOrd
 }
 
 func CreateOrder(amount, currency, tradeType, price string) (*respOrder, error) {
+	currency = symbol.Market(constant.Zb, currency)
 	createOrderParams := map[string]string{
 		"accesskey": Config.ACCESS_KEY,
 		"amount":    amount,
@@ -163,6 +173,7 @@ func getOrders(api, currency, sign string) (*respOrders, error) {
 }
 
 func GetOrders(currency string) (*respOrders, error) {
+	currency = symbol.Market(constant.Zb, currency)
 	orderParams := map[string]string{
 		"accesskey": Config.ACCESS_KEY,
 		"currency":  currency,
@@ -192,6 +203,7 @@ func cancelOrder(api, id, currency, sign string) (*respSimple, error) {
 }
 
 func CancelOrder(id, currency string) (*respSimple, error) {
+	currency = symbol.Market(constant.Zb, currency)
 	cancelParams := map[string]string{
 		"accesskey": Config.ACCESS_KEY,
 		"currency":  currency,
@@ -220,6 +232,7 @@ func getOrder(api, id, currency, sign string) (*order, error) {
 }
 
 func GetOrder(id, currency string) (*order, error) {
+	currency = symbol.Market(constant.Zb, currency)
 	orderParams := map[string]string{
 		"accesskey": Config.ACCESS_KEY,
 		"currency":  currency,
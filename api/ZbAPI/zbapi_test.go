@@ -0,0 +1,58 @@
+package ZbAPI
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/api/replay"
+)
+
+func newTestPlayer(t *testing.T) *replay.Player {
+	player, err := replay.Load(filepath.Join("testdata", "zb.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := dataClient.GetClient().Transport
+	dataClient.GetClient().Transport = player
+	t.Cleanup(func() { dataClient.GetClient().Transport = transport })
+	return player
+}
+
+func TestGetDepth(t *testing.T) {
+	newTestPlayer(t)
+	want := &respDepth{
+		Timestamp: 1700000000,
+		Asks:      []depthOrder{{30002, 1.2}, {30001.5, 0.8}},
+		Bids:      []depthOrder{{30000.5, 0.5}, {29999, 2}},
+	}
+	// zb 的交易对和统一的货币类型都可以使用
+	for _, market := range []string{"btc_usdt", "BTC/USDT"} {
+		got, err := GetDepth(market, "2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetDepth(%v) = %+v, want %+v", market, got, want)
+		}
+	}
+}
+
+func TestGetTrades(t *testing.T) {
+	newTestPlayer(t)
+	got := GetTrades("btc_usdt")
+	if got == nil || len(*got) != 2 {
+		t.Fatalf("GetTrades() = %+v, want 2 trades", got)
+	}
+	if trade := (*got)[1]; trade.Tid != 102 || trade.Price != "30000.2" || trade.TradeType != "ask" {
+		t.Errorf("GetTrades()[1] = %+v", trade)
+	}
+}
+
+func TestServerTime(t *testing.T) {
+	newTestPlayer(t)
+	ms, err := serverTime()
+	if err != nil || ms != 1700000000000 {
+		t.Fatalf("serverTime() = %v, %v", ms, err)
+	}
+}
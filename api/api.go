@@ -1,5 +1,9 @@
 package api
 
+import (
+	"github.com/phonegapX/QuantBot/api/clock"
+)
+
 // Option is an exchange option
 type Option struct {
 	TraderID   int64
@@ -8,7 +12,13 @@ type Option struct {
 	AccessKey  string
 	SecretKey  string
 	Passphrase string
-	Test       string
+	Test       string //"1" 表示连接交易所的模拟盘(sandbox)
+	Host       string //自定义的 REST API 地址, 例如本地的模拟服务器, 为空时使用交易所的默认地址
+}
+
+// Sandbox get whether the option connects to the sandbox
+func (opt Option) Sandbox() bool {
+	return opt.Test == "1"
 }
 
 // Exchange interface
@@ -16,17 +26,23 @@ type Exchange interface {
 	Log(...interface{})                                                                                   //向管理台发送这个交易所的打印信息
 	GetType() string                                                                                      //获取交易所类型,是火币还是OKEY等。。。
 	GetName() string                                                                                      //获取交易所名称,自定义的
+	IsSandbox() bool                                                                                      //是否连接的是交易所的模拟盘
 	SetLimit(times interface{}) float64                                                                   //设置交易所的API访问频率,和 E.AutoSleep() 配合使用
 	AutoSleep()                                                                                           //自动休眠以满足设置的交易所的API访问频率
+	GetClockStatus() clock.Status                                                                         //获取本地时钟和交易所服务器时钟的同步状态
 	GetMinAmount(stock string) float64                                                                    //获取交易所的最小交易数量
+	GetLastError() interface{}                                                                            //获取最近一次失败调用的错误(Error), 没有时返回 false
 	GetAccount() interface{}                                                                              //获取交易所的账户资金信息
 	Trade(tradeType string, stockType string, price, amount interface{}, msgs ...interface{}) interface{} //如果 Price <= 0 自动设置为市价单，数量参数也有所不同,如果成功返回订单的 ID,如果失败返回 false
 	GetOrder(instId string, option ...interface{}) interface{}                                            //返回订单信息
 	GetOrders(stockType string) interface{}                                                               //返回所有的未完成订单列表
 	GetTrades(stockType string) interface{}                                                               //返回最近的已完成订单列表
+	GetFeeRates(stockType string) interface{}                                                             //返回交易所的挂单和吃单费率
 	CancelOrder(order Order) bool                                                                         //取消一笔订单
 	GetTicker(stockType string, sizes ...interface{}) interface{}                                         //获取交易所的最新市场行情数据
+	GetDepth(stockType string, sizes ...interface{}) interface{}                                          //获取本地维护的全量市场深度
 	GetRecords(stockType, period string, sizes ...interface{}) interface{}
+	GetPublicTrades(stockType string, sizes ...interface{}) interface{} //返回市场最近的公开成交列表
 	GetPositions(options ...interface{}) interface{}
 	ClosePosition(instId, mgnMode, posSide string, options ...interface{}) bool
 	TradeAlgo(instId, tdMode, side, ordType, sz string, options map[string]interface{}) interface{}
@@ -35,3 +51,18 @@ type Exchange interface {
 var (
 	constructor = map[string]func(Option) Exchange{}
 )
+
+// balancer an exchange which can report the available balance of a currency
+type balancer interface {
+	getAvailable(currency string) (float64, error)
+}
+
+// GetAvailable get the available balance of a currency, ok is false if the exchange can not report it
+func GetAvailable(e Exchange, currency string) (available float64, ok bool, err error) {
+	b, ok := e.(balancer)
+	if !ok {
+		return
+	}
+	available, err = b.getAvailable(currency)
+	return
+}
@@ -0,0 +1,373 @@
+// Package apitest a conformance suite of the api.Exchange implementations,
+// every adapter runs it against its mock server or fixtures so the venues meet a common contract:
+//
+//	Symbol       交易对不区分大小写并忽略首尾空格, 返回的 StockType 是规范格式, 无法识别的交易对返回 false
+//	Lifecycle    下单、查询、撤单和成交的订单状态一致, 撤销已撤销的订单返回 false
+//	MarketOrder  Price <= 0 是市价单, 买单的数量是花费的计价货币, 卖单的数量是基础货币
+//	Rounding     价格和数量舍入到 8 位小数, 接受数字字符串, 舍入后不是正数的数量返回 false
+//	Errors       失败的调用返回 false, GetLastError 返回分类后的 api.Error
+//	Concurrency  多个协程可以同时调用同一个交易所
+package apitest
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/api"
+	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/symbol"
+)
+
+// Venue an exchange under the test and the market which it is connected to
+type Venue struct {
+	Exchange api.Exchange
+	// Fail make the next order placement fail with an error category(constant.ErrAuth, constant.ErrRateLimit
+	// or constant.ErrNetwork), returns false if the market can not simulate it, nil means none can be simulated
+	Fail func(category string) bool
+}
+
+// Config the options of the suite
+type Config struct {
+	New     func(t *testing.T) Venue //创建一个连接到全新市场的交易所, 每个子测试调用一次
+	Symbol  string                   //一个有买卖盘的现货交易对, eg: BTC/USDT
+	Amount  float64                  //一笔订单的数量, 账户可以买入和卖出这个数量, 买一和卖一的挂单量都不少于它的 2 倍
+	Workers int                      //并发测试的协程数, 默认为 8
+}
+
+// Run run the whole suite
+func Run(t *testing.T, c Config) {
+	if c.Workers <= 0 {
+		c.Workers = 8
+	}
+	tests := []struct {
+		name string
+		run  func(t *testing.T, c Config)
+	}{
+		{"Symbol", testSymbol},
+		{"Lifecycle", testLifecycle},
+		{"MarketOrder", testMarketOrder},
+		{"Rounding", testRounding},
+		{"Errors", testErrors},
+		{"Concurrency", testConcurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.run(t, c) })
+	}
+}
+
+// invalidSymbols the symbols which every venue must reject
+var invalidSymbols = []string{"", "BTC", "BTC/", "/USDT", "BTC/USDT/ETH", "BTC_USDT"}
+
+func testSymbol(t *testing.T, c Config) {
+	e := c.New(t).Exchange
+	canonical := symbol.Normalize(c.Symbol)
+	want := getTicker(t, e, canonical)
+	for _, s := range []string{strings.ToLower(canonical), " " + canonical + " "} {
+		if got := e.GetTicker(s); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetTicker(%q) = %+v, want %+v", s, got, want)
+		}
+	}
+	id := trade(t, e, "buy", strings.ToLower(canonical), want.Buy/2, c.Amount)
+	order := getOrder(t, e, " "+strings.ToLower(canonical), id)
+	if order.StockType != canonical {
+		t.Errorf("GetOrder().StockType = %q, want %q", order.StockType, canonical)
+	}
+	for _, o := range getOrders(t, e, strings.ToLower(canonical)) {
+		if o.StockType != canonical {
+			t.Errorf("GetOrders().StockType = %q, want %q", o.StockType, canonical)
+		}
+	}
+	for _, s := range invalidSymbols {
+		calls := map[string]func() interface{}{
+			"GetTicker":  func() interface{} { return e.GetTicker(s) },
+			"GetDepth":   func() interface{} { return e.GetDepth(s) },
+			"GetRecords": func() interface{} { return e.GetRecords(s, "M") },
+			"GetOrder":   func() interface{} { return e.GetOrder(s, id) },
+			"GetOrders":  func() interface{} { return e.GetOrders(s) },
+			"GetTrades":  func() interface{} { return e.GetTrades(s) },
+			"Trade":      func() interface{} { return e.Trade("BUY", s, want.Buy/2, c.Amount) },
+			"CancelOrder": func() interface{} {
+				return e.CancelOrder(api.Order{ID: id, StockType: s})
+			},
+		}
+		for name, call := range calls {
+			if got := call(); got != false {
+				t.Errorf("%v(%q) = %+v, want false", name, s, got)
+			} else if category := lastCategory(e); category != constant.ErrInvalid {
+				t.Errorf("%v(%q) error category = %q, want %q", name, s, category, constant.ErrInvalid)
+			}
+		}
+	}
+}
+
+func testLifecycle(t *testing.T, c Config) {
+	e := c.New(t).Exchange
+	canonical := symbol.Normalize(c.Symbol)
+	ticker := getTicker(t, e, c.Symbol)
+
+	// 挂单
+	price := ticker.Buy / 2
+	id := trade(t, e, "BUY", c.Symbol, price, c.Amount)
+	open, ok := findOrder(getOrders(t, e, c.Symbol), id)
+	if !ok {
+		t.Fatalf("GetOrders() does not contain the open order %v", id)
+	}
+	want := api.Order{ID: id, Price: price, Amount: c.Amount, TradeType: constant.TradeTypeBuy, StockType: canonical}
+	if open.ID != want.ID || open.Price != want.Price || open.Amount != want.Amount || open.DealAmount != 0 ||
+		open.TradeType != want.TradeType || open.StockType != want.StockType {
+		t.Errorf("the open order = %+v, want %+v", open, want)
+	}
+	if order := getOrder(t, e, c.Symbol, id); order.ID != id || order.Amount != c.Amount || order.DealAmount != 0 {
+		t.Errorf("GetOrder(%v) = %+v, want %+v", id, order, want)
+	}
+
+	// 撤单
+	if !e.CancelOrder(open) {
+		t.Fatalf("CancelOrder(%v) = false, error: %v", id, e.GetLastError())
+	}
+	if _, ok := findOrder(getOrders(t, e, c.Symbol), id); ok {
+		t.Errorf("GetOrders() contains the canceled order %v", id)
+	}
+	if e.CancelOrder(open) {
+		t.Errorf("CancelOrder(%v) twice = true, want false", id)
+	} else if category := lastCategory(e); category != constant.ErrRejected {
+		t.Errorf("CancelOrder(%v) twice error category = %q, want %q", id, category, constant.ErrRejected)
+	}
+
+	// 吃单成交后不在未完成订单中, 并出现在成交列表中
+	for _, side := range []struct {
+		tradeType string
+		price     float64
+	}{{"BUY", ticker.Sell}, {"SELL", ticker.Buy}} {
+		id := trade(t, e, side.tradeType, c.Symbol, side.price, c.Amount)
+		if order := getOrder(t, e, c.Symbol, id); order.DealAmount != c.Amount || order.TradeType != side.tradeType {
+			t.Errorf("the filled %v order = %+v, want DealAmount %v", side.tradeType, order, c.Amount)
+		}
+		if _, ok := findOrder(getOrders(t, e, c.Symbol), id); ok {
+			t.Errorf("GetOrders() contains the filled order %v", id)
+		}
+		trades, ok := e.GetTrades(c.Symbol).([]api.Order)
+		if !ok {
+			t.Fatalf("GetTrades(%v) failed, error: %v", c.Symbol, e.GetLastError())
+		}
+		if fill, ok := findOrder(trades, id); !ok || fill.DealAmount != c.Amount || fill.TradeType != side.tradeType || fill.StockType != canonical {
+			t.Errorf("GetTrades() = %+v, want the %v order %v", trades, side.tradeType, id)
+		}
+	}
+}
+
+func testMarketOrder(t *testing.T, c Config) {
+	e := c.New(t).Exchange
+	ticker := getTicker(t, e, c.Symbol)
+	for _, price := range []interface{}{0, -1} {
+		// 买单的数量是花费的计价货币
+		quote := round(ticker.Sell * c.Amount / 2)
+		id := trade(t, e, "BUY", c.Symbol, price, quote)
+		if order := getOrder(t, e, c.Symbol, id); math.Abs(order.DealAmount-c.Amount/2) > 1e-8 || order.TradeType != constant.TradeTypeBuy {
+			t.Errorf("the BUY market order(price %v) = %+v, want DealAmount %v", price, order, c.Amount/2)
+		}
+		id = trade(t, e, "SELL", c.Symbol, price, c.Amount/2)
+		if order := getOrder(t, e, c.Symbol, id); order.DealAmount != c.Amount/2 || order.TradeType != constant.TradeTypeSell {
+			t.Errorf("the SELL market order(price %v) = %+v, want DealAmount %v", price, order, c.Amount/2)
+		}
+	}
+}
+
+func testRounding(t *testing.T, c Config) {
+	e := c.New(t).Exchange
+	ticker := getTicker(t, e, c.Symbol)
+	price := round(ticker.Buy / 2)
+	amount := round(c.Amount)
+	tests := []struct {
+		name   string
+		price  interface{}
+		amount interface{}
+	}{
+		{"float64", price, amount},
+		{"float artifacts", price + 1e-10, amount + 1e-10},
+		{"string", strconv.FormatFloat(price, 'f', -1, 64), strconv.FormatFloat(amount, 'f', -1, 64)},
+	}
+	for _, tt := range tests {
+		id := trade(t, e, "BUY", c.Symbol, tt.price, tt.amount)
+		if order := getOrder(t, e, c.Symbol, id); order.Price != price || order.Amount != amount {
+			t.Errorf("%v: the order = %+v, want price %v and amount %v", tt.name, order, price, amount)
+		}
+	}
+	for _, tt := range []struct {
+		name   string
+		price  interface{}
+		amount interface{}
+	}{
+		{"rounded to zero", price, 1e-10},
+		{"zero amount", price, 0},
+		{"negative amount", price, -c.Amount},
+		{"NaN amount", price, math.NaN()},
+		{"infinite price", math.Inf(1), c.Amount},
+		{"invalid price", "abc", c.Amount},
+		{"invalid amount", price, "abc"},
+	} {
+		if got := e.Trade("BUY", c.Symbol, tt.price, tt.amount); got != false {
+			t.Errorf("%v: Trade(%v, %v) = %v, want false", tt.name, tt.price, tt.amount, got)
+		} else if category := lastCategory(e); category != constant.ErrInvalid {
+			t.Errorf("%v: error category = %q, want %q", tt.name, category, constant.ErrInvalid)
+		}
+	}
+}
+
+func testErrors(t *testing.T, c Config) {
+	v := c.New(t)
+	e := v.Exchange
+	if err := e.GetLastError(); err != false {
+		t.Errorf("GetLastError() of a new exchange = %+v, want false", err)
+	}
+	ticker := getTicker(t, e, c.Symbol)
+	expect := func(name string, got interface{}, category string) {
+		if got != false {
+			t.Errorf("%v = %v, want false", name, got)
+			return
+		}
+		err, ok := e.GetLastError().(api.Error)
+		if !ok {
+			t.Errorf("%v: GetLastError() = %+v, want an api.Error", name, e.GetLastError())
+			return
+		}
+		if err.Category != category || err.Method == "" || err.Message == "" {
+			t.Errorf("%v: GetLastError() = %+v, want a %q error with its method and message", name, err, category)
+		}
+	}
+	expect("Trade with an unrecognized tradeType", e.Trade("LONG_SHORT", c.Symbol, ticker.Buy/2, c.Amount), constant.ErrInvalid)
+	expect("GetRecords with an unrecognized period", e.GetRecords(c.Symbol, "M7"), constant.ErrInvalid)
+	expect("Trade with an insufficient balance", e.Trade("SELL", c.Symbol, ticker.Buy, 1e9), constant.ErrRejected)
+	expect("GetOrder of a nonexistent order", e.GetOrder(c.Symbol, "1"), constant.ErrRejected)
+	expect("CancelOrder of a nonexistent order", e.CancelOrder(api.Order{ID: "1", StockType: c.Symbol}), constant.ErrRejected)
+	for _, category := range []string{constant.ErrAuth, constant.ErrRateLimit, constant.ErrNetwork} {
+		if v.Fail == nil || !v.Fail(category) {
+			t.Logf("the venue can not simulate the %v error", category)
+			continue
+		}
+		expect(category+" Trade", e.Trade("BUY", c.Symbol, ticker.Buy/2, c.Amount), category)
+		// 失败之后交易所可以继续使用
+		trade(t, e, "BUY", c.Symbol, ticker.Buy/2, c.Amount)
+	}
+}
+
+func testConcurrency(t *testing.T, c Config) {
+	e := c.New(t).Exchange
+	e.SetLimit(1000)
+	ticker := getTicker(t, e, c.Symbol)
+	ids := make(chan string, c.Workers)
+	wg := sync.WaitGroup{}
+	for i := 0; i < c.Workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, ok := e.GetTicker(c.Symbol).(api.Ticker); !ok {
+				t.Errorf("worker %v: GetTicker() failed, error: %v", i, e.GetLastError())
+			}
+			e.GetTicker(invalidSymbols[i%len(invalidSymbols)])
+			e.GetLastError()
+			id, ok := e.Trade("BUY", c.Symbol, ticker.Buy/2, c.Amount).(string)
+			if !ok {
+				t.Errorf("worker %v: Trade() failed, error: %v", i, e.GetLastError())
+				return
+			}
+			orders, ok := e.GetOrder(c.Symbol, id).([]api.Order)
+			if !ok || len(orders) != 1 {
+				t.Errorf("worker %v: GetOrder(%v) = %+v", i, id, orders)
+				return
+			}
+			if !e.CancelOrder(orders[0]) {
+				t.Errorf("worker %v: CancelOrder(%v) failed, error: %v", i, id, e.GetLastError())
+			}
+			e.GetFeeRates(c.Symbol)
+			e.AutoSleep()
+			ids <- id
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+	seen := map[string]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("the order id %v is duplicated", id)
+		}
+		seen[id] = true
+	}
+	if orders := getOrders(t, e, c.Symbol); len(orders) != 0 {
+		t.Errorf("GetOrders() = %+v, want all canceled", orders)
+	}
+}
+
+// getTicker get the ticker and check the order of the books
+func getTicker(t *testing.T, e api.Exchange, stockType string) api.Ticker {
+	t.Helper()
+	ticker, ok := e.GetTicker(stockType).(api.Ticker)
+	if !ok {
+		t.Fatalf("GetTicker(%v) failed, error: %v", stockType, e.GetLastError())
+	}
+	if ticker.Buy <= 0 || ticker.Sell <= ticker.Buy || ticker.Mid != (ticker.Buy+ticker.Sell)/2 {
+		t.Errorf("GetTicker(%v) = %+v, want 0 < Buy < Sell and Mid = (Buy + Sell) / 2", stockType, ticker)
+	}
+	if !sort.SliceIsSorted(ticker.Bids, func(i, j int) bool { return ticker.Bids[i].Price > ticker.Bids[j].Price }) ||
+		!sort.SliceIsSorted(ticker.Asks, func(i, j int) bool { return ticker.Asks[i].Price < ticker.Asks[j].Price }) {
+		t.Errorf("GetTicker(%v) = %+v, want the bids in descending order and the asks in ascending order", stockType, ticker)
+	}
+	return ticker
+}
+
+// trade place an order which must succeed, returns its id
+func trade(t *testing.T, e api.Exchange, tradeType, stockType string, price, amount interface{}) string {
+	t.Helper()
+	id, ok := e.Trade(tradeType, stockType, price, amount).(string)
+	if !ok || id == "" {
+		t.Fatalf("Trade(%v, %v, %v, %v) failed, error: %v", tradeType, stockType, price, amount, e.GetLastError())
+	}
+	return id
+}
+
+func getOrder(t *testing.T, e api.Exchange, stockType, id string) api.Order {
+	t.Helper()
+	orders, ok := e.GetOrder(stockType, id).([]api.Order)
+	if !ok || len(orders) != 1 {
+		t.Fatalf("GetOrder(%q, %v) = %+v, error: %v", stockType, id, orders, e.GetLastError())
+	}
+	return orders[0]
+}
+
+func getOrders(t *testing.T, e api.Exchange, stockType string) []api.Order {
+	t.Helper()
+	orders, ok := e.GetOrders(stockType).([]api.Order)
+	if !ok {
+		t.Fatalf("GetOrders(%q) failed, error: %v", stockType, e.GetLastError())
+	}
+	return orders
+}
+
+func findOrder(orders []api.Order, id string) (api.Order, bool) {
+	for _, o := range orders {
+		if o.ID == id {
+			return o, true
+		}
+	}
+	return api.Order{}, false
+}
+
+// lastCategory get the category of the last error, or the printed value if it is not an api.Error
+func lastCategory(e api.Exchange) string {
+	if err, ok := e.GetLastError().(api.Error); ok {
+		return err.Category
+	}
+	return fmt.Sprint(e.GetLastError())
+}
+
+// round round a number to 8 decimals like the adapters
+func round(f float64) float64 {
+	r, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'f', 8, 64), 64)
+	return r
+}
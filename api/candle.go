@@ -0,0 +1,98 @@
+package api
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/miaolz123/conver"
+	"github.com/phonegapX/QuantBot/config"
+)
+
+// defaultCandleHistory the default amount of candlesticks kept for every stockType & period
+const defaultCandleHistory = 1000
+
+// CandleStore caches the candlesticks of every stockType & period, safe for concurrent use
+type CandleStore struct {
+	mu      sync.RWMutex
+	history int                 //每个货币类型和周期最多保存的K线数量
+	records map[string][]Record //key: stockType + "@" + period
+}
+
+// NewCandleStore create a candlestick store, the history length is read from config when history <= 0
+func NewCandleStore(history int) *CandleStore {
+	if history <= 0 {
+		history = conver.IntMust(config.String("candleHistory"))
+	}
+	if history <= 0 {
+		history = defaultCandleHistory
+	}
+	return &CandleStore{
+		history: history,
+		records: make(map[string][]Record),
+	}
+}
+
+func candleKey(stockType, period string) string {
+	return stockType + "@" + period
+}
+
+// SetHistory set the max amount of candlesticks kept for every stockType & period
+func (s *CandleStore) SetHistory(history int) {
+	if history <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history = history
+	for key, records := range s.records {
+		if len(records) > history {
+			s.records[key] = records[len(records)-history:]
+		}
+	}
+}
+
+// Merge merge the fetched candlesticks by time, a candlestick with an existing time
+// (eg: the last one which is still forming) replaces the old one
+func (s *CandleStore) Merge(stockType, period string, records []Record) []Record {
+	key := candleKey(stockType, period)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.records[key]
+	merged := make([]Record, 0, len(old)+len(records))
+	merged = append(merged, old...)
+	indexes := make(map[int64]int, len(merged))
+	for i, r := range merged {
+		indexes[r.Time] = i
+	}
+	sorted := true
+	for _, r := range records {
+		if i, ok := indexes[r.Time]; ok {
+			merged[i] = r
+			continue
+		}
+		if n := len(merged); n > 0 && merged[n-1].Time > r.Time {
+			sorted = false
+		}
+		indexes[r.Time] = len(merged)
+		merged = append(merged, r)
+	}
+	if !sorted {
+		sort.Slice(merged, func(i, j int) bool { return merged[i].Time < merged[j].Time })
+	}
+	if len(merged) > s.history {
+		merged = merged[len(merged)-s.history:]
+	}
+	s.records[key] = merged
+	return append([]Record{}, merged...)
+}
+
+// Get get the latest size candlesticks of a stockType & period, size <= 0 means all
+func (s *CandleStore) Get(stockType, period string, size int) []Record {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := s.records[candleKey(stockType, period)]
+	if size > 0 && len(records) > size {
+		records = records[len(records)-size:]
+	}
+	return append([]Record{}, records...)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+)
+
+// candles create the candlesticks of the times, the close price is the time plus offset
+func candles(offset float64, times ...int64) []Record {
+	records := []Record{}
+	for _, t := range times {
+		records = append(records, Record{Time: t, Close: float64(t) + offset})
+	}
+	return records
+}
+
+func TestCandleStoreMerge(t *testing.T) {
+	tests := []struct {
+		name    string
+		history int
+		merges  [][]Record
+		want    []Record
+	}{
+		{
+			name:    "overlapping windows are deduplicated by time",
+			history: 10,
+			merges:  [][]Record{candles(0, 1, 2, 3), candles(0, 2, 3, 4, 5)},
+			want:    candles(0, 1, 2, 3, 4, 5),
+		},
+		{
+			name:    "the forming bar is replaced",
+			history: 10,
+			merges:  [][]Record{candles(0, 1, 2, 3), candles(0.5, 3)},
+			want:    append(candles(0, 1, 2), candles(0.5, 3)...),
+		},
+		{
+			name:    "an older window is sorted in",
+			history: 10,
+			merges:  [][]Record{candles(0, 4, 5), candles(0, 1, 2, 4)},
+			want:    candles(0, 1, 2, 4, 5),
+		},
+		{
+			name:    "the oldest candlesticks are dropped over the history",
+			history: 3,
+			merges:  [][]Record{candles(0, 1, 2, 3), candles(0, 3, 4, 5)},
+			want:    candles(0, 3, 4, 5),
+		},
+	}
+	for _, tt := range tests {
+		s := NewCandleStore(tt.history)
+		var got []Record
+		for _, records := range tt.merges {
+			got = s.Merge("BTC/USDT", "M", records)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: Merge() = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if got := s.Get("BTC/USDT", "M", 0); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: Get() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCandleStoreKeys(t *testing.T) {
+	s := NewCandleStore(10)
+	s.Merge("BTC/USDT", "M", candles(0, 1, 2))
+	s.Merge("ETH/USDT", "M", candles(0.5, 2, 3))
+	s.Merge("BTC/USDT", "M5", candles(0, 5))
+	tests := []struct {
+		stockType, period string
+		want              []Record
+	}{
+		{"BTC/USDT", "M", candles(0, 1, 2)},
+		{"ETH/USDT", "M", candles(0.5, 2, 3)},
+		{"BTC/USDT", "M5", candles(0, 5)},
+		{"ETH/USDT", "M5", []Record{}},
+	}
+	for _, tt := range tests {
+		if got := s.Get(tt.stockType, tt.period, 0); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Get(%v, %v) = %+v, want %+v", tt.stockType, tt.period, got, tt.want)
+		}
+	}
+}
+
+func TestCandleStoreGet(t *testing.T) {
+	s := NewCandleStore(10)
+	s.Merge("BTC/USDT", "M", candles(0, 1, 2, 3, 4))
+	tests := []struct {
+		size int
+		want []Record
+	}{
+		{0, candles(0, 1, 2, 3, 4)},
+		{-1, candles(0, 1, 2, 3, 4)},
+		{2, candles(0, 3, 4)},
+		{10, candles(0, 1, 2, 3, 4)},
+	}
+	for _, tt := range tests {
+		if got := s.Get("BTC/USDT", "M", tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Get(%v) = %+v, want %+v", tt.size, got, tt.want)
+		}
+	}
+	// 返回的是副本, 修改不影响缓存
+	got := s.Get("BTC/USDT", "M", 1)
+	got[0].Close = 0
+	if got := s.Get("BTC/USDT", "M", 1); got[0].Close != 4 {
+		t.Errorf("Get() returns the cached slice")
+	}
+}
+
+func TestCandleStoreSetHistory(t *testing.T) {
+	s := NewCandleStore(10)
+	s.Merge("BTC/USDT", "M", candles(0, 1, 2, 3, 4))
+	s.Merge("ETH/USDT", "M", candles(0, 1, 2))
+	s.SetHistory(0)
+	if got := s.Get("BTC/USDT", "M", 0); len(got) != 4 {
+		t.Errorf("SetHistory(0) should be ignored, got %+v", got)
+	}
+	s.SetHistory(3)
+	tests := []struct {
+		stockType string
+		want      []Record
+	}{
+		{"BTC/USDT", candles(0, 2, 3, 4)},
+		{"ETH/USDT", candles(0, 1, 2)},
+	}
+	for _, tt := range tests {
+		if got := s.Get(tt.stockType, "M", 0); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SetHistory(3) %v = %+v, want %+v", tt.stockType, got, tt.want)
+		}
+	}
+	if got := s.Merge("BTC/USDT", "M", candles(0, 5)); !reflect.DeepEqual(got, candles(0, 3, 4, 5)) {
+		t.Errorf("Merge() after SetHistory(3) = %+v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package clock
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// DefaultInterval the default interval between two synchronizations
+const DefaultInterval = time.Minute
+
+// Fetcher get the server time of an exchange, in milliseconds
+type Fetcher func() (int64, error)
+
+// Status the synchronization status of a clock
+type Status struct {
+	Offset   int64     //本地时钟和服务器时钟的偏差,毫秒,服务器时间 = 本地时间 + Offset
+	Latency  int64     //最近一次同步的往返延迟,毫秒
+	LastSync time.Time //最近一次成功同步的时间
+	LastErr  string    //最近一次同步的错误信息
+}
+
+// Clock keeps the offset between the local clock and the server clock of an exchange
+type Clock struct {
+	name     string
+	fetch    Fetcher
+	interval time.Duration
+	once     sync.Once
+	stopOnce sync.Once
+	stop     chan struct{} //关闭后停止后台同步
+	mu       sync.RWMutex
+	offset   time.Duration
+	latency  time.Duration
+	lastSync time.Time
+	lastErr  error
+}
+
+var (
+	mu     sync.Mutex
+	clocks = map[string]*Clock{} //每个交易所共用一个时钟
+)
+
+// Register get the clock of an exchange, create it if it does not exist
+func Register(name string, fetch Fetcher) *Clock {
+	mu.Lock()
+	defer mu.Unlock()
+	if c, ok := clocks[name]; ok {
+		return c
+	}
+	c := New(name, fetch, DefaultInterval)
+	clocks[name] = c
+	return c
+}
+
+// New create a clock which is not registered, it syncs every interval after the first use until it is stopped
+func New(name string, fetch Fetcher, interval time.Duration) *Clock {
+	return &Clock{name: name, fetch: fetch, interval: interval, stop: make(chan struct{})}
+}
+
+// Get get the clock of an exchange, return nil if it does not exist
+func Get(name string) *Clock {
+	mu.Lock()
+	defer mu.Unlock()
+	return clocks[name]
+}
+
+// start sync the clock at the first use, and then periodically in background
+func (c *Clock) start() {
+	c.once.Do(func() {
+		if c.fetch == nil {
+			return
+		}
+		if err := c.Sync(); err != nil {
+			log.Printf("Sync %v clock error: %v\n", c.name, err)
+		}
+		go func() {
+			ticker := time.NewTicker(c.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					c.Sync()
+				case <-c.stop:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// Stop stop the background synchronization and unregister the clock, the offset is kept
+func (c *Clock) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if clocks[c.name] == c {
+		delete(clocks, c.name)
+	}
+}
+
+// Sync query the server time once and update the offset
+func (c *Clock) Sync() error {
+	begin := time.Now()
+	ms, err := c.fetch()
+	end := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.lastErr = err
+		return err
+	}
+	latency := end.Sub(begin)
+	server := time.Unix(0, ms*int64(time.Millisecond)).Add(latency / 2)
+	c.offset = server.Sub(end)
+	c.latency = latency
+	c.lastSync = end
+	c.lastErr = nil
+	return nil
+}
+
+// Now get the current time corrected by the offset
+func (c *Clock) Now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	c.start()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return time.Now().Add(c.offset)
+}
+
+// Status get the synchronization status of the clock
+func (c *Clock) Status() (status Status) {
+	if c == nil {
+		return
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	status.Offset = int64(c.offset / time.Millisecond)
+	status.Latency = int64(c.latency / time.Millisecond)
+	status.LastSync = c.lastSync
+	if c.lastErr != nil {
+		status.LastErr = c.lastErr.Error()
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package clock
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSync(t *testing.T) {
+	var server int64
+	c := New("test", func() (int64, error) {
+		time.Sleep(20 * time.Millisecond)
+		return atomic.LoadInt64(&server), nil
+	}, time.Hour)
+	defer c.Stop()
+	// 服务器时钟比本地快 5 秒
+	atomic.StoreInt64(&server, time.Now().Add(5*time.Second).UnixNano()/int64(time.Millisecond))
+	if err := c.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	s := c.Status()
+	if s.Latency < 20 || s.Latency > 200 {
+		t.Errorf("latency = %v, want about 20ms", s.Latency)
+	}
+	// 服务器时间在请求的中间取得, 所以偏差约为 5 秒减去一半的延迟
+	if want := int64(5000) - s.Latency/2; s.Offset < want-50 || s.Offset > want+50 {
+		t.Errorf("offset = %v, want about %v", s.Offset, want)
+	}
+	if d := c.Now().Sub(time.Now()); d < 4800*time.Millisecond || d > 5200*time.Millisecond {
+		t.Errorf("Now() is %v ahead, want about 5s", d)
+	}
+	if s.LastSync.IsZero() || s.LastErr != "" {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+func TestSyncError(t *testing.T) {
+	c := New("test", func() (int64, error) { return 0, errors.New("timeout") }, time.Hour)
+	defer c.Stop()
+	if err := c.Sync(); err == nil {
+		t.Fatal("Sync() should fail")
+	}
+	if s := c.Status(); s.Offset != 0 || s.LastErr != "timeout" {
+		t.Errorf("status = %+v", s)
+	}
+	var nilClock *Clock
+	if d := time.Since(nilClock.Now()); d < 0 || d > time.Second {
+		t.Errorf("a nil clock should use the local time")
+	}
+}
+
+func TestStop(t *testing.T) {
+	var syncs int64
+	c := Register("stop", func() (int64, error) {
+		atomic.AddInt64(&syncs, 1)
+		return time.Now().UnixNano() / int64(time.Millisecond), nil
+	})
+	if Get("stop") != c {
+		t.Fatal("the clock is not registered")
+	}
+	c.interval = 5 * time.Millisecond
+	c.Now()
+	time.Sleep(50 * time.Millisecond)
+	c.Stop()
+	if Get("stop") != nil {
+		t.Error("the stopped clock should be unregistered")
+	}
+	n := atomic.LoadInt64(&syncs)
+	if n < 2 {
+		t.Errorf("synced %v times, want the background synchronization", n)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if m := atomic.LoadInt64(&syncs); m > n+1 {
+		t.Errorf("synced %v times after Stop()", m-n)
+	}
+	c.Stop()
+}
@@ -0,0 +1,51 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/api"
+	"github.com/phonegapX/QuantBot/api/apitest"
+	"github.com/phonegapX/QuantBot/api/okexmock"
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+func TestOKEXConformance(t *testing.T) {
+	apitest.Run(t, apitest.Config{
+		New: func(t *testing.T) apitest.Venue {
+			s := okexmock.NewServer()
+			s.AddAccount("access", "secret", "passphrase", map[string]float64{"USDT": 100000, "BTC": 1})
+			s.AddLiquidity("BTC-USDT", "sell", 30010, 1)
+			s.AddLiquidity("BTC-USDT", "buy", 29990, 1)
+			s.Start()
+			t.Cleanup(s.Close)
+			e := api.NewOKEX(api.Option{
+				Type:       constant.Okex,
+				Name:       "okex",
+				AccessKey:  "access",
+				SecretKey:  "secret",
+				Passphrase: "passphrase",
+				Host:       s.URL,
+			})
+			// 下一次下单返回对应类型的错误
+			failures := map[string]struct {
+				status    int
+				code, msg string
+			}{
+				constant.ErrAuth:      {http.StatusUnauthorized, "50113", "Invalid Sign"},
+				constant.ErrRateLimit: {http.StatusTooManyRequests, "50011", "Too Many Requests"},
+				constant.ErrNetwork:   {http.StatusServiceUnavailable, "50001", "Service temporarily unavailable"},
+			}
+			fail := func(category string) bool {
+				f, ok := failures[category]
+				if ok {
+					s.Fail("/api/v5/trade/order", f.status, f.code, f.msg)
+				}
+				return ok
+			}
+			return apitest.Venue{Exchange: e, Fail: fail}
+		},
+		Symbol: "BTC/USDT",
+		Amount: 0.1,
+	})
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miaolz123/conver"
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+// Depth struct, a snapshot of the full-depth order book
+type Depth struct {
+	Time int64       //unix时间戳,毫秒
+	Bids []OrderBook //买单市场深度列表,价格从高到低
+	Asks []OrderBook //卖单市场深度列表,价格从低到高
+}
+
+// AmountAt get the amount at the price of either side
+func (d Depth) AmountAt(price float64) float64 {
+	for _, books := range [][]OrderBook{d.Bids, d.Asks} {
+		for _, b := range books {
+			if b.Price == price {
+				return b.Amount
+			}
+		}
+	}
+	return 0.0
+}
+
+// CumulativeAmount get the total amount of a side whose price is not worse than the price,
+// the side is BUY(Bids) or SELL(Asks)
+func (d Depth) CumulativeAmount(side string, price float64) (amount float64) {
+	switch strings.ToUpper(side) {
+	case constant.TradeTypeBuy:
+		for _, b := range d.Bids {
+			if b.Price < price {
+				break
+			}
+			amount += b.Amount
+		}
+	case constant.TradeTypeSell:
+		for _, a := range d.Asks {
+			if a.Price > price {
+				break
+			}
+			amount += a.Amount
+		}
+	}
+	return
+}
+
+// Vwap get the average price to fill the amount by a market order,
+// a BUY order takes the Asks and a SELL order takes the Bids, returns 0 if the depth is not enough
+func (d Depth) Vwap(side string, amount float64) float64 {
+	books := d.Asks
+	if strings.ToUpper(side) == constant.TradeTypeSell {
+		books = d.Bids
+	}
+	if amount <= 0 {
+		return 0.0
+	}
+	left, cost := amount, 0.0
+	for _, b := range books {
+		fill := math.Min(left, b.Amount)
+		cost += fill * b.Price
+		left -= fill
+		if left <= 0 {
+			return cost / amount
+		}
+	}
+	return 0.0
+}
+
+// Imbalance get (bids - asks) / (bids + asks) of the amount in the top levels, levels <= 0 means all
+func (d Depth) Imbalance(levels int) float64 {
+	sum := func(books []OrderBook) (amount float64) {
+		for i, b := range books {
+			if levels > 0 && i >= levels {
+				break
+			}
+			amount += b.Amount
+		}
+		return
+	}
+	bids, asks := sum(d.Bids), sum(d.Asks)
+	if bids+asks == 0 {
+		return 0.0
+	}
+	return (bids - asks) / (bids + asks)
+}
+
+// depthLevel a price level which keeps the raw strings for the checksum
+type depthLevel struct {
+	price  float64
+	amount float64
+	px     string
+	sz     string
+}
+
+// depthBook a full-depth order book maintained by a snapshot and the incremental updates
+type depthBook struct {
+	mu    sync.RWMutex
+	bids  []depthLevel //价格从高到低
+	asks  []depthLevel //价格从低到高
+	seqID int64
+	time  int64
+	ready bool
+}
+
+func newDepthLevel(px, sz string) depthLevel {
+	return depthLevel{
+		price:  conver.Float64Must(px),
+		amount: conver.Float64Must(sz),
+		px:     px,
+		sz:     sz,
+	}
+}
+
+// reset replace the book by a snapshot
+func (b *depthBook) reset(bids, asks []depthLevel, seqID, ts int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bids, b.asks = nil, nil
+	for _, l := range bids {
+		b.bids = upsertLevel(b.bids, l, true)
+	}
+	for _, l := range asks {
+		b.asks = upsertLevel(b.asks, l, false)
+	}
+	b.seqID = seqID
+	b.time = ts
+	b.ready = true
+}
+
+// update apply the incremental updates, an amount of 0 removes the level
+func (b *depthBook) update(bids, asks []depthLevel, seqID, ts int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, l := range bids {
+		b.bids = upsertLevel(b.bids, l, true)
+	}
+	for _, l := range asks {
+		b.asks = upsertLevel(b.asks, l, false)
+	}
+	b.seqID = seqID
+	b.time = ts
+}
+
+// invalidate mark the book as out of sync
+func (b *depthBook) invalidate() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ready = false
+}
+
+func upsertLevel(levels []depthLevel, l depthLevel, desc bool) []depthLevel {
+	i := sort.Search(len(levels), func(i int) bool {
+		if desc {
+			return levels[i].price <= l.price
+		}
+		return levels[i].price >= l.price
+	})
+	if i < len(levels) && levels[i].price == l.price {
+		if l.amount <= 0 {
+			return append(levels[:i], levels[i+1:]...)
+		}
+		levels[i] = l
+		return levels
+	}
+	if l.amount <= 0 {
+		return levels
+	}
+	levels = append(levels, depthLevel{})
+	copy(levels[i+1:], levels[i:])
+	levels[i] = l
+	return levels
+}
+
+// topLevels get the raw top size levels of both sides, used to calculate the checksum
+func (b *depthBook) topLevels(size int) (bids, asks []depthLevel) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bids = b.bids
+	if len(bids) > size {
+		bids = bids[:size]
+	}
+	asks = b.asks
+	if len(asks) > size {
+		asks = asks[:size]
+	}
+	return append([]depthLevel{}, bids...), append([]depthLevel{}, asks...)
+}
+
+// snapshot get a copy of the top size levels, size <= 0 means all
+func (b *depthBook) snapshot(size int) (depth Depth, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.ready {
+		return
+	}
+	depth.Time = b.time
+	depth.Bids = []OrderBook{}
+	depth.Asks = []OrderBook{}
+	for i, l := range b.bids {
+		if size > 0 && i >= size {
+			break
+		}
+		depth.Bids = append(depth.Bids, OrderBook{Price: l.price, Amount: l.amount})
+	}
+	for i, l := range b.asks {
+		if size > 0 && i >= size {
+			break
+		}
+		depth.Asks = append(depth.Asks, OrderBook{Price: l.price, Amount: l.amount})
+	}
+	return depth, true
+}
+
+func (b *depthBook) synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.ready
+}
+
+func (b *depthBook) lastSeqID() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.seqID
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDepth(t *testing.T) {
+	d := Depth{
+		Bids: []OrderBook{{Price: 100, Amount: 1}, {Price: 99, Amount: 2}, {Price: 98, Amount: 3}},
+		Asks: []OrderBook{{Price: 101, Amount: 0.5}, {Price: 102, Amount: 1.5}},
+	}
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"AmountAt bid", d.AmountAt(99), 2},
+		{"AmountAt ask", d.AmountAt(102), 1.5},
+		{"AmountAt missing", d.AmountAt(100.5), 0},
+		{"CumulativeAmount buy", d.CumulativeAmount("buy", 99), 3},
+		{"CumulativeAmount sell", d.CumulativeAmount("SELL", 101.5), 0.5},
+		{"CumulativeAmount above the best bid", d.CumulativeAmount("BUY", 100.5), 0},
+		{"CumulativeAmount invalid side", d.CumulativeAmount("HOLD", 100), 0},
+		{"Vwap buy", d.Vwap("BUY", 1), (101*0.5 + 102*0.5) / 1},
+		{"Vwap sell", d.Vwap("SELL", 2), (100*1 + 99*1) / 2.0},
+		{"Vwap not enough depth", d.Vwap("BUY", 3), 0},
+		{"Vwap zero amount", d.Vwap("BUY", 0), 0},
+		{"Imbalance top 1", d.Imbalance(1), (1 - 0.5) / 1.5},
+		{"Imbalance all", d.Imbalance(0), (6 - 2) / 8.0},
+		{"Imbalance empty", Depth{}.Imbalance(5), 0},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%v = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestDepthBook(t *testing.T) {
+	b := &depthBook{}
+	if _, ok := b.snapshot(0); ok {
+		t.Fatal("the book should not be ready before the snapshot")
+	}
+	b.reset([]depthLevel{newDepthLevel("99", "1"), newDepthLevel("100", "2")}, []depthLevel{newDepthLevel("102", "1"), newDepthLevel("101", "1")}, 1, 1000)
+	b.update([]depthLevel{newDepthLevel("100", "0"), newDepthLevel("99.5", "3")}, []depthLevel{newDepthLevel("103", "0")}, 2, 2000)
+	d, ok := b.snapshot(1)
+	if !ok || d.Time != 2000 || len(d.Bids) != 1 || d.Bids[0] != (OrderBook{Price: 99.5, Amount: 3}) || d.Asks[0] != (OrderBook{Price: 101, Amount: 1}) {
+		t.Errorf("snapshot() = %+v, %v", d, ok)
+	}
+	if b.lastSeqID() != 2 {
+		t.Errorf("lastSeqID() = %v, want 2", b.lastSeqID())
+	}
+	b.invalidate()
+	if b.synced() {
+		t.Error("the book should be out of sync after invalidate()")
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+// Error a failed call of the exchange api, the Category is one of constant.ErrInvalid,
+// constant.ErrRejected, constant.ErrAuth, constant.ErrRateLimit and constant.ErrNetwork
+type Error struct {
+	Category string //错误类型, 用于判断是否可以重试
+	Method   string //出错的方法, eg: Trade
+	Code     string //交易所返回的错误码, 没有时为空
+	Message  string //错误信息
+}
+
+func (err Error) Error() string {
+	if err.Code != "" {
+		return fmt.Sprintf("%v() error, [%v] %v", err.Method, err.Code, err.Message)
+	}
+	return fmt.Sprintf("%v() error, %v", err.Method, err.Message)
+}
+
+// newError create an error of the method, the messages are joined like fmt.Sprint
+func newError(category, method string, msgs ...interface{}) Error {
+	return Error{
+		Category: category,
+		Method:   method,
+		Message:  fmt.Sprint(msgs...),
+	}
+}
+
+// httpCategory categorize an error of the http request by its status, "" if the status means nothing
+func httpCategory(err error) string {
+	herr, ok := err.(*httpError)
+	if !ok {
+		return constant.ErrNetwork
+	}
+	switch {
+	case herr.status == 429:
+		return constant.ErrRateLimit
+	case herr.status == 401 || herr.status == 403:
+		return constant.ErrAuth
+	case herr.status >= 500:
+		return constant.ErrNetwork
+	}
+	return ""
+}
+
+// lastError keep the last error of an exchange, it is safe for concurrent use
+type lastError struct {
+	mu  sync.Mutex
+	err *Error
+}
+
+func (l *lastError) set(err Error) {
+	l.mu.Lock()
+	l.err = &err
+	l.mu.Unlock()
+}
+
+// GetLastError get the error of the last failed call, false if no call failed
+func (l *lastError) GetLastError() interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		return false
+	}
+	return *l.err
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/symbol"
+)
+
+// defaultFeeRates the base tier fee rates of every exchange type
+var defaultFeeRates = map[string]FeeRate{
+	constant.Zb:         {Maker: 0.002, Taker: 0.002},
+	constant.Okex:       {Maker: 0.0008, Taker: 0.001},
+	constant.Huobi:      {Maker: 0.002, Taker: 0.002},
+	constant.Binance:    {Maker: 0.001, Taker: 0.001},
+	constant.GateIo:     {Maker: 0.002, Taker: 0.002},
+	constant.Poloniex:   {Maker: 0.0009, Taker: 0.0009},
+	constant.OkexFuture: {Maker: 0.0002, Taker: 0.0005},
+	constant.BigOne:     {Maker: 0.001, Taker: 0.001},
+}
+
+// FeeModel estimates the trading fee locally, OKEX.parseOrders fills the fee of an order which okex does not return
+type FeeModel struct {
+	mu    sync.RWMutex
+	rates map[string]FeeRate //按货币类型单独设置的费率
+	base  FeeRate            //默认费率
+}
+
+// NewFeeModel create a fee model with the default fee rates of the exchange type
+func NewFeeModel(exchangeType string) *FeeModel {
+	return &FeeModel{
+		rates: make(map[string]FeeRate),
+		base:  defaultFeeRates[exchangeType],
+	}
+}
+
+// SetRate set the fee rates of a stockType, an empty stockType sets the default fee rates
+func (m *FeeModel) SetRate(stockType string, rate FeeRate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stockType == "" {
+		m.base = rate
+		return
+	}
+	m.rates[stockType] = rate
+}
+
+// GetRate get the fee rates of a stockType
+func (m *FeeModel) GetRate(stockType string) FeeRate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if rate, ok := m.rates[stockType]; ok {
+		return rate
+	}
+	return m.base
+}
+
+// Estimate estimate the fee of a fill, the fee is charged in the quote currency
+func (m *FeeModel) Estimate(stockType string, price, amount float64, taker bool) float64 {
+	rate := m.GetRate(stockType)
+	if taker {
+		return price * amount * rate.Taker
+	}
+	return price * amount * rate.Maker
+}
+
+// Apply fill the Fee of an order according to its dealt amount at price, the fee is charged in
+// the settle currency of a derivative or the quote currency of a spot
+func (m *FeeModel) Apply(order *Order, price float64, taker bool) {
+	order.Fee = m.Estimate(order.StockType, price, order.DealAmount, taker)
+	if sym, err := symbol.Parse(order.StockType); err == nil {
+		order.FeeCcy = sym.Quote
+		if sym.Settle != "" {
+			order.FeeCcy = sym.Settle
+		}
+	}
+}
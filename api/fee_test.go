@@ -0,0 +1,84 @@
+package api
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+func TestFeeModelEstimate(t *testing.T) {
+	m := NewFeeModel(constant.Okex)
+	m.SetRate("BTC/USDT:USDT", FeeRate{Maker: -0.0001, Taker: 0.0005})
+	tests := []struct {
+		stockType string
+		taker     bool
+		want      float64
+	}{
+		{"BTC/USDT", false, 30000 * 0.5 * 0.0008},
+		{"BTC/USDT", true, 30000 * 0.5 * 0.001},
+		{"BTC/USDT:USDT", false, -30000 * 0.5 * 0.0001},
+		{"BTC/USDT:USDT", true, 30000 * 0.5 * 0.0005},
+	}
+	for _, tt := range tests {
+		if fee := m.Estimate(tt.stockType, 30000, 0.5, tt.taker); math.Abs(fee-tt.want) > 1e-9 {
+			t.Errorf("Estimate(%v, %v) = %v, want %v", tt.stockType, tt.taker, fee, tt.want)
+		}
+	}
+	m.SetRate("", FeeRate{Maker: 0.002, Taker: 0.003})
+	if fee := m.Estimate("ETH/USDT", 2000, 1, true); math.Abs(fee-6) > 1e-9 {
+		t.Errorf("Estimate() with the default rates = %v, want 6", fee)
+	}
+}
+
+func TestFeeModelApply(t *testing.T) {
+	m := NewFeeModel(constant.Okex)
+	tests := []struct {
+		stockType string
+		fee       float64
+		feeCcy    string
+	}{
+		{"BTC/USDT", 10, "USDT"},
+		{"BTC/USDT:USDT", 10, "USDT"},
+		{"BTC/USD:BTC", 10, "BTC"},
+		{"BTC/USD:BTC@240329", 10, "BTC"},
+		{"invalid", 10, ""},
+	}
+	for _, tt := range tests {
+		order := Order{StockType: tt.stockType, Price: 1, DealAmount: 1}
+		m.Apply(&order, 10000, true)
+		if math.Abs(order.Fee-tt.fee) > 1e-9 || order.FeeCcy != tt.feeCcy {
+			t.Errorf("Apply(%v) = %v %v, want %v %v", tt.stockType, order.Fee, order.FeeCcy, tt.fee, tt.feeCcy)
+		}
+	}
+}
+
+func TestOKEXEstimateMissingFees(t *testing.T) {
+	e := NewOKEX(Option{Type: constant.Okex, Name: "okex"}).(*OKEX)
+	e.feed = nil
+	data, err := simplejson.NewJson([]byte(`[
+		{"ordId": "1", "px": "30000", "sz": "1", "accFillSz": "0.5", "fee": "-3", "feeCcy": "USDT", "side": "buy", "ordType": "limit"},
+		{"ordId": "2", "px": "30000", "sz": "1", "accFillSz": "0.5", "fee": "", "feeCcy": "", "side": "buy", "ordType": "limit"},
+		{"ordId": "3", "px": "", "avgPx": "31000", "sz": "1", "accFillSz": "1", "fee": "", "feeCcy": "", "side": "buy", "ordType": "market"},
+		{"ordId": "4", "px": "30000", "sz": "1", "accFillSz": "0", "fee": "", "feeCcy": "", "side": "buy", "ordType": "limit"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders := e.parseOrders(data, "BTC/USDT")
+	want := []struct {
+		fee    float64
+		feeCcy string
+	}{
+		{3, "USDT"},
+		{30000 * 0.5 * 0.0008, "USDT"},
+		{31000 * 0.001, "USDT"},
+		{0, ""},
+	}
+	for i, w := range want {
+		if math.Abs(orders[i].Fee-w.fee) > 1e-9 || orders[i].FeeCcy != w.feeCcy {
+			t.Errorf("order %v fee = %v %v, want %v %v", orders[i].ID, orders[i].Fee, orders[i].FeeCcy, w.fee, w.feeCcy)
+		}
+	}
+}
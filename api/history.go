@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/phonegapX/QuantBot/model"
+	"github.com/phonegapX/QuantBot/symbol"
+)
+
+// historian an exchange which can page backwards through its history candlesticks
+type historian interface {
+	Exchange
+	getHistoryRecords(stockType, period string, before int64, size int) ([]Record, error)
+}
+
+var (
+	downloading     sync.Map //正在下载的K线, 防止重复下载
+	periodDurations = map[string]int64{
+		"M":   60 * 1000,
+		"M5":  5 * 60 * 1000,
+		"M15": 15 * 60 * 1000,
+		"M30": 30 * 60 * 1000,
+		"H":   60 * 60 * 1000,
+		"H4":  4 * 60 * 60 * 1000,
+		"D":   24 * 60 * 60 * 1000,
+		"W":   7 * 24 * 60 * 60 * 1000,
+	}
+)
+
+// DownloadRecords download the candlesticks in [begin, end)(unix ms) and save them into the candles table,
+// the download is resumed from the saved candlesticks, returns the amount of the saved candlesticks
+func DownloadRecords(e Exchange, stockType, period string, begin, end int64, progress func(saved int)) (saved int, err error) {
+	h, ok := e.(historian)
+	if !ok {
+		return 0, fmt.Errorf("DownloadRecords() error, %v does not support history candlesticks", e.GetType())
+	}
+	if begin >= end {
+		return 0, fmt.Errorf("DownloadRecords() error, invalid time range: %v - %v", begin, end)
+	}
+	stockType = symbol.Normalize(stockType)
+	key := e.GetType() + "@" + stockType + "@" + period
+	if _, loaded := downloading.LoadOrStore(key, true); loaded {
+		return 0, fmt.Errorf("DownloadRecords() error, %v is downloading", key)
+	}
+	defer downloading.Delete(key)
+	duration, ok := periodDurations[period]
+	if !ok {
+		return 0, fmt.Errorf("DownloadRecords() error, unrecognized period: %v", period)
+	}
+	// 从结束时间往前翻页, 如果游标之前已经保存了连续的K线, 就直接跳到已保存的最早一条继续下载
+	cursor := end
+	for cursor > begin {
+		earliest, _, count, err := model.CandleRange(e.GetType(), stockType, period, begin, cursor)
+		if err != nil {
+			return saved, err
+		}
+		if count > 0 && count >= (cursor-earliest+duration-1)/duration {
+			cursor = earliest
+			continue
+		}
+		h.AutoSleep()
+		records, err := h.getHistoryRecords(stockType, period, cursor, 100)
+		if err != nil {
+			return saved, err
+		}
+		if len(records) == 0 {
+			break
+		}
+		candles := []model.Candle{}
+		for _, r := range records {
+			if r.Time >= begin && r.Time < end {
+				candles = append(candles, model.Candle{
+					Time:   r.Time,
+					Open:   r.Open,
+					High:   r.High,
+					Low:    r.Low,
+					Close:  r.Close,
+					Volume: r.Volume,
+				})
+			}
+		}
+		if err = model.SaveCandles(e.GetType(), stockType, period, candles); err != nil {
+			return saved, err
+		}
+		saved += len(candles)
+		if progress != nil {
+			progress(saved)
+		}
+		if records[0].Time >= cursor {
+			break
+		}
+		cursor = records[0].Time
+	}
+	return
+}
+
+// LoadRecords load the downloaded candlesticks in [begin, end)(unix ms) ordered by time
+func LoadRecords(exchangeType, stockType, period string, begin, end int64) ([]Record, error) {
+	candles, err := model.ListCandles(exchangeType, symbol.Normalize(stockType), period, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]Record, len(candles))
+	for i, c := range candles {
+		records[i] = Record{
+			Time:   c.Time,
+			Open:   c.Open,
+			High:   c.High,
+			Low:    c.Low,
+			Close:  c.Close,
+			Volume: c.Volume,
+		}
+	}
+	return records, nil
+}
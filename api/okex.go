@@ -1,19 +1,25 @@
 package api
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	encodingJson "encoding/json"
 	"fmt"
+	"math"
 	netUrl "net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bitly/go-simplejson"
 	"github.com/miaolz123/conver"
+	"github.com/phonegapX/QuantBot/api/clock"
 	"github.com/phonegapX/QuantBot/constant"
 	"github.com/phonegapX/QuantBot/model"
+	"github.com/phonegapX/QuantBot/symbol"
 )
 
 const (
@@ -23,18 +29,24 @@ const (
 
 // OKEX the exchange struct of okex.com
 type OKEX struct {
-	stockTypeMap     map[string]string
 	tradeTypeMap     map[string]string
 	recordsPeriodMap map[string]string
 	minAmountMap     map[string]float64
-	records          map[string][]Record
+	candles          *CandleStore
+	fees             *FeeModel
+	clock            *clock.Clock
+	feed             *okexFeed
 	host             string
+	endpoint         Endpoint
 	logger           model.Logger
 	option           Option
+	lastError
 
+	mu        sync.Mutex //保护 limit, lastSleep 和 ctx
 	limit     float64
 	lastSleep int64
-	lastTimes int64
+	lastTimes int64           //原子操作
+	ctx       context.Context //策略停止时被取消, 中断正在进行的请求和 AutoSleep
 }
 
 var mgnModes map[string]bool = map[string]bool{
@@ -44,17 +56,21 @@ var mgnModes map[string]bool = map[string]bool{
 
 // NewOKEX create an exchange struct of okex.com
 func NewOKEX(opt Option) Exchange {
+	endpoint, err := GetEndpoint(opt.Type, opt.Sandbox())
+	if err != nil {
+		endpoint, _ = GetEndpoint(constant.Okex, false)
+	}
+	if opt.Host != "" {
+		endpoint.REST = strings.TrimRight(opt.Host, "/") + "/api/v5/"
+		endpoint.WS = ""
+	}
+	host := endpoint.REST
+	// 没有 websocket 地址时只使用 REST 接口
+	var feed *okexFeed
+	if endpoint.WS != "" {
+		feed = getOkexFeed(endpoint.WS)
+	}
 	return &OKEX{
-		stockTypeMap: map[string]string{
-			"BTC/USDT":      "BTC-USDT",
-			"ETH/USDT":      "ETH-USDT",
-			"EOS/USDT":      "eos_usdt",
-			"ONT/USDT":      "ont_usdt",
-			"QTUM/USDT":     "qtum_usdt",
-			"ONT/ETH":       "ont_eth",
-			"BTC/USD/SWAP":  "BTC-USD-SWAP",
-			"BTC/USDT/SWAP": "BTC-USDT-SWAP",
-		},
 		tradeTypeMap: map[string]string{
 			"buy":         constant.TradeTypeBuy,
 			"sell":        constant.TradeTypeSell,
@@ -79,14 +95,19 @@ func NewOKEX(opt Option) Exchange {
 			"QTUM/USDT": 0.001,
 			"ONT/ETH":   0.001,
 		},
-		records: make(map[string][]Record),
+		candles: NewCandleStore(0),
+		fees:    NewFeeModel(opt.Type),
+		clock:   clock.Register(okexClockName(host), okexServerTime(host)),
+		feed:    feed,
 		// host:    "https://www.okex.com/api/v1/",
-		host:   "https://www.okx.com/api/v5/",
-		logger: model.Logger{TraderID: opt.TraderID, ExchangeType: opt.Type},
-		option: opt,
+		host:     host,
+		endpoint: endpoint,
+		logger:   model.Logger{TraderID: opt.TraderID, ExchangeType: opt.Type},
+		option:   opt,
 
 		limit:     10.0,
 		lastSleep: time.Now().UnixNano(),
+		ctx:       context.Background(),
 	}
 }
 
@@ -107,24 +128,57 @@ func (e *OKEX) GetName() string {
 
 // SetLimit set the limit calls amount per second of this exchange
 func (e *OKEX) SetLimit(times interface{}) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.limit = conver.Float64Must(times)
 	return e.limit
 }
 
 // AutoSleep auto sleep to achieve the limit calls amount per second of this exchange
 func (e *OKEX) AutoSleep() {
+	e.mu.Lock()
 	now := time.Now().UnixNano()
-	interval := 1e+9/e.limit*conver.Float64Must(e.lastTimes) - conver.Float64Must(now-e.lastSleep)
+	interval := 1e+9/e.limit*conver.Float64Must(atomic.SwapInt64(&e.lastTimes, 0)) - conver.Float64Must(now-e.lastSleep)
+	e.lastSleep = now
+	ctx := e.ctx
+	e.mu.Unlock()
 	if interval > 0.0 {
-		time.Sleep(time.Duration(conver.Int64Must(interval)))
+		t := time.NewTimer(time.Duration(conver.Int64Must(interval)))
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+		}
 	}
-	e.lastTimes = 0
-	e.lastSleep = now
+}
+
+// SetContext set the context of the requests, the requests in flight are aborted when it is done
+func (e *OKEX) SetContext(ctx context.Context) {
+	e.mu.Lock()
+	e.ctx = ctx
+	e.mu.Unlock()
+}
+
+// context get the context of the requests
+func (e *OKEX) context() context.Context {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.ctx
+}
+
+// IsSandbox get whether this exchange is connected to the sandbox
+func (e *OKEX) IsSandbox() bool {
+	return e.option.Sandbox()
+}
+
+// GetClockStatus get the synchronization status between the local clock and the server clock
+func (e *OKEX) GetClockStatus() clock.Status {
+	return e.clock.Status()
 }
 
 // GetMinAmount get the min trade amonut of this exchange
 func (e *OKEX) GetMinAmount(stock string) float64 {
-	return e.minAmountMap[stock]
+	return e.minAmountMap[symbol.Normalize(stock)]
 }
 
 func (e *OKEX) getAuthJSON(url string, method string, body interface{}) (json *simplejson.Json, err error) {
@@ -139,7 +193,7 @@ func (e *OKEX) getAuthJSON(url string, method string, body interface{}) (json *s
 	var timestamp string
 	var signStr string
 	if method == "GET" {
-		timestamp, signStr = sign("GET", requestPath, "", []byte(e.option.SecretKey))
+		timestamp, signStr = sign("GET", requestPath, "", []byte(e.option.SecretKey), e.clock.Now())
 	} else {
 		j, err := encodingJson.Marshal(body)
 		if err != nil {
@@ -149,7 +203,7 @@ func (e *OKEX) getAuthJSON(url string, method string, body interface{}) (json *s
 		if body == "{}" {
 			signBody = ""
 		}
-		timestamp, signStr = sign("POST", requestPath, signBody, []byte(e.option.SecretKey))
+		timestamp, signStr = sign("POST", requestPath, signBody, []byte(e.option.SecretKey), e.clock.Now())
 	}
 
 	header := map[string]string{
@@ -161,17 +215,17 @@ func (e *OKEX) getAuthJSON(url string, method string, body interface{}) (json *s
 		// "x-simulated-trading":  "1",
 	}
 
-	if e.option.Test == "1" {
-		header["x-simulated-trading"] = "1"
+	for k, v := range e.endpoint.Header {
+		header[k] = v
 	}
 
-	e.lastTimes++
+	atomic.AddInt64(&e.lastTimes, 1)
 	var errs error
 	var resp []byte
 	if method == "GET" {
-		resp, errs = getWithHeader(url, header, body)
+		resp, errs = getWithHeader(e.context(), url, header, body)
 	} else if method == "POST" {
-		resp, errs = postWithHeader(url, header, body)
+		resp, errs = postWithHeader(e.context(), url, header, body)
 	}
 	if errs != nil {
 		return nil, errs
@@ -183,17 +237,12 @@ func (e *OKEX) getAuthJSON(url string, method string, body interface{}) (json *s
 // GetAccount get the account detail of this exchange
 func (e *OKEX) GetAccount() interface{} {
 	json, err := e.getAuthJSON(e.host+"account/balance", "GET", nil)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetAccount() balance error, ", err)
+	if err = okexCheck("GetAccount", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
 
 	json = json.Get("data").GetIndex(0)
-	// if result := json.Get("data").GetIndex(0).MustBool(); !result {
-	// 	err = fmt.Errorf("GetAccount() error, the error number is %v", json.Get("error_code").MustInt())
-	// 	e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetAccount() error, ", err)
-	// 	return false
-	// }
 	return map[string]float64{
 		"adjEq": conver.Float64Must(json.Get("adjEq").MustString()),
 		// details := conver.Float64Must(json.Get("details").MustString())
@@ -208,14 +257,32 @@ func (e *OKEX) GetAccount() interface{} {
 	}
 }
 
+// getAvailable get the available balance of a currency
+func (e *OKEX) getAvailable(currency string) (float64, error) {
+	json, err := e.getAuthJSON(e.host+"account/balance?ccy="+strings.ToUpper(currency), "GET", nil)
+	if err != nil {
+		return 0.0, err
+	}
+	if code := json.Get("code").MustString(); code != "0" {
+		return 0.0, fmt.Errorf("account/balance error, %v", json.Get("msg").MustString())
+	}
+	details := json.Get("data").GetIndex(0).Get("details")
+	for i := 0; i < len(details.MustArray()); i++ {
+		if detail := details.GetIndex(i); detail.Get("ccy").MustString() == strings.ToUpper(currency) {
+			return conver.Float64Must(detail.Get("availBal").MustString()), nil
+		}
+	}
+	return 0.0, nil
+}
+
 // 策略下单，提供止盈止损
 func (e *OKEX) TradeAlgo(instId, tdMode, side, ordType, sz string, options map[string]interface{}) interface{} {
-	if _, ok := e.stockTypeMap[instId]; !ok {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "Trade() error, unrecognized stockType: ", instId)
+	if e.toInstId(instId) == "" {
+		e.fail(newError(constant.ErrInvalid, "TradeAlgo", "unrecognized stockType: ", instId))
 		return false
 	}
 	body := map[string]interface{}{
-		"instId":  e.stockTypeMap[instId],
+		"instId":  e.toInstId(instId),
 		"tdMode":  tdMode,
 		"side":    side,
 		"ordType": ordType,
@@ -227,173 +294,155 @@ func (e *OKEX) TradeAlgo(instId, tdMode, side, ordType, sz string, options map[s
 	}
 
 	json, err := e.getAuthJSON(e.host+"trade/order-algo", "POST", body)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "trade/order-algo error, ", err)
-		return false
-	}
-
-	j := json.Get("data").GetIndex(0)
-	if sCode := j.Get("sCode").MustString(); sCode != "0" {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "trade/order-algo error,", j.Get("sMsg").MustString())
+	if err = okexCheck("TradeAlgo", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
-	return j.Get("algoId").MustString()
+	return json.Get("data").GetIndex(0).Get("algoId").MustString()
 }
 
-// Trade place an order
+// Trade place an order, it is a market order if the price <= 0
 func (e *OKEX) Trade(tradeType string, stockType string, _price, _amount interface{}, msgs ...interface{}) interface{} {
-	stockType = strings.ToUpper(stockType)
 	tradeType = strings.ToUpper(tradeType)
-	price := conver.Float64Must(_price)
-	amount := conver.Float64Must(_amount)
-	if _, ok := e.stockTypeMap[stockType]; !ok {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "Trade() error, unrecognized stockType: ", stockType)
+	instId := e.toInstId(stockType)
+	if instId == "" {
+		e.fail(newError(constant.ErrInvalid, "Trade", "unrecognized stockType: ", stockType))
+		return false
+	}
+	price, err := conver.Float64(_price)
+	if err != nil || math.IsNaN(price) || math.IsInf(price, 0) {
+		e.fail(newError(constant.ErrInvalid, "Trade", "invalid price: ", _price))
+		return false
+	}
+	amount, err := conver.Float64(_amount)
+	// 数量在舍入之后必须为正数
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) || amount <= 0 || formatDecimal(amount) == "0" {
+		e.fail(newError(constant.ErrInvalid, "Trade", "invalid amount: ", _amount))
 		return false
 	}
 	switch tradeType {
 	case constant.TradeTypeBuy:
-		return e.buy(stockType, price, amount, msgs...)
+		return e.order("buy", instId, price, amount, msgs...)
 	case constant.TradeTypeSell:
-		return e.sell(stockType, price, amount, msgs...)
+		return e.order("sell", instId, price, amount, msgs...)
 	default:
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "Trade() error, unrecognized tradeType: ", tradeType)
+		e.fail(newError(constant.ErrInvalid, "Trade", "unrecognized tradeType: ", tradeType))
 		return false
 	}
 }
 
-func (e *OKEX) buy(stockType string, price, amount float64, msgs ...interface{}) interface{} {
-	body := map[string]string{
-		"instId":  e.stockTypeMap[stockType],
-		"tdMode":  "cross",
-		"side":    "buy",
-		"ordType": "limit",
-		"posSide": "long",
-		"px":      conver.StringMust(price),
-		"sz":      conver.StringMust(amount),
+// order place a limit order, or a market order if the price <= 0,
+// the amount of a spot market buy order is the quote currency to spend, see E.Trade in the docs
+func (e *OKEX) order(side, instId string, price, amount float64, msgs ...interface{}) interface{} {
+	posSide := "long"
+	if side == "sell" {
+		posSide = "short"
 	}
-	json, err := e.getAuthJSON(e.host+"trade/order", "POST", body)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "Buy() error, ", err)
-		return false
-	}
-	if json == nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "Buy() error, ", err)
-		return false
-	}
-
-	j := json.Get("data").GetIndex(0)
-	if sCode := j.Get("sCode").MustString(); sCode != "0" {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "Buy() error, the error number is ", json.Get("error_code").MustInt())
-		return false
-	}
-	e.logger.Log(constant.BUY, stockType, price, amount, msgs...)
-	return j.Get("ordId").MustString()
-}
-
-func (e *OKEX) sell(stockType string, price, amount float64, msgs ...interface{}) interface{} {
 	body := map[string]string{
-		"instId":  e.stockTypeMap[stockType],
+		"instId":  instId,
 		"tdMode":  "cross",
-		"side":    "sell",
+		"side":    side,
 		"ordType": "limit",
-		"posSide": "short",
-		"px":      conver.StringMust(price),
-		"sz":      conver.StringMust(amount),
+		"posSide": posSide,
+		"px":      formatDecimal(price),
+		"sz":      formatDecimal(amount),
 	}
-	json, err := e.getAuthJSON(e.host+"trade/order", "POST", body)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "Buy() error, ", err)
-		return false
+	if price <= 0 {
+		body["ordType"] = "market"
+		delete(body, "px")
 	}
-	if json == nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "Sell() error, ", err)
+	json, err := e.getAuthJSON(e.host+"trade/order", "POST", body)
+	if err = okexCheck("Trade", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
-
-	j := json.Get("data").GetIndex(0)
-	if sCode := j.Get("sCode").MustString(); sCode != "0" {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "Sell() error, the error number is ", json.Get("error_code").MustInt())
-		return false
+	method := constant.BUY
+	if side == "sell" {
+		method = constant.SELL
 	}
-	e.logger.Log(constant.SELL, stockType, price, amount, msgs...)
-	return j.Get("ordId").MustString()
+	e.logger.Log(method, fromInstId(instId), price, amount, msgs...)
+	return json.Get("data").GetIndex(0).Get("ordId").MustString()
 }
 
-// GetOrder get details of an order
+// GetOrder get details of an order, the options are the ordId and the clOrdId
 func (e *OKEX) GetOrder(instId string, option ...interface{}) interface{} {
-	instId = strings.ToUpper(instId)
-	if _, ok := e.stockTypeMap[instId]; !ok {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetOrder() error, unrecognized stockType: ", instId)
+	stockType := symbol.Normalize(instId)
+	if e.toInstId(stockType) == "" {
+		e.fail(newError(constant.ErrInvalid, "GetOrder", "unrecognized stockType: ", instId))
 		return false
 	}
 	params := []string{
-		"instId=" + e.stockTypeMap[instId],
+		"instId=" + e.toInstId(stockType),
 	}
-
-	query := ""
 	for i, v := range option {
 		var value string
 		var ok bool
-		if value, ok = v.(string); !ok {
-			e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetOrder() params type is error: ", v)
+		if value, ok = v.(string); !ok || value == "" {
+			e.fail(newError(constant.ErrInvalid, "GetOrder", "invalid order id: ", v))
 			return false
 		}
 		switch i {
 		case 0:
-			params = append(params, "ordId="+value)
-			break
+			params = append(params, "ordId="+netUrl.QueryEscape(value))
 		case 1:
-			params = append(params, "clOrdId="+value)
-			break
-		}
-	}
-
-	for index, v := range params {
-		if index == 0 {
-			query = v
-		} else {
-			query = query + "&" + v
+			params = append(params, "clOrdId="+netUrl.QueryEscape(value))
 		}
 	}
-	json, err := e.getAuthJSON(e.host+"trade/order?"+query, "GET", params)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "/api/v5/trade/order error, ", err)
+	if len(params) < 2 {
+		e.fail(newError(constant.ErrInvalid, "GetOrder", "the order id is required"))
 		return false
 	}
-
-	if code := json.Get("code").MustString(); code != "0" {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "/api/v5/trade/order error ")
+	json, err := e.getAuthJSON(e.host+"trade/order?"+strings.Join(params, "&"), "GET", nil)
+	if err = okexCheck("GetOrder", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
+	return e.parseOrders(json.Get("data"), stockType)
+}
 
-	json = json.Get("data")
-	count := len(json.MustArray())
+// parseOrders parse the orders of the v5 api
+func (e *OKEX) parseOrders(json *simplejson.Json, stockType string) []Order {
 	orders := []Order{}
-	for i := 0; i < count; i++ {
+	for i := 0; i < len(json.MustArray()); i++ {
 		orderJSON := json.GetIndex(i)
 		order := Order{
-			ID:         fmt.Sprint(orderJSON.Get("ordId").MustString()),
+			ID:         orderJSON.Get("ordId").MustString(),
 			Price:      conver.Float64Must(orderJSON.Get("px").MustString()),
 			Amount:     conver.Float64Must(orderJSON.Get("sz").MustString()),
-			DealAmount: conver.Float64Must(0),
+			DealAmount: conver.Float64Must(orderJSON.Get("accFillSz").MustString()),
+			Fee:        -conver.Float64Must(orderJSON.Get("fee").MustString()),
+			FeeCcy:     orderJSON.Get("feeCcy").MustString(),
 			TradeType:  e.tradeTypeMap[orderJSON.Get("side").MustString()],
-			StockType:  instId,
+			StockType:  stockType,
 			Pnl:        conver.Float64Must(orderJSON.Get("pnl").MustString()),
 		}
+		// 没有返回交易费时用本地的费率估算, 市价单和 ioc, fok 订单按吃单计算
+		if order.FeeCcy == "" && order.DealAmount > 0 {
+			price := conver.Float64Must(orderJSON.Get("avgPx").MustString())
+			if price <= 0 {
+				price = order.Price
+			}
+			switch orderJSON.Get("ordType").MustString() {
+			case "market", "ioc", "fok", "optimal_limit_ioc":
+				e.fees.Apply(&order, price, true)
+			default:
+				e.fees.Apply(&order, price, false)
+			}
+		}
 		orders = append(orders, order)
 	}
 	return orders
 }
 
-// GetOrder get details of an order
+// GetOrderHistosy get the completed orders of the last 7 days
 func (e *OKEX) GetOrderHistosy(instId, instType string, option ...map[string]interface{}) interface{} {
-	instId = strings.ToUpper(instId)
-	if _, ok := e.stockTypeMap[instId]; !ok {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetOrder() error, unrecognized stockType: ", instId)
+	stockType := symbol.Normalize(instId)
+	if e.toInstId(stockType) == "" {
+		e.fail(newError(constant.ErrInvalid, "GetOrderHistosy", "unrecognized stockType: ", instId))
 		return false
 	}
 	params := []string{
-		"instId=" + e.stockTypeMap[instId],
+		"instId=" + e.toInstId(stockType),
 		"instType=" + instType,
 	}
 
@@ -402,229 +451,236 @@ func (e *OKEX) GetOrderHistosy(instId, instType string, option ...map[string]int
 			var value string
 			var ok bool
 			if value, ok = v.(string); !ok {
-				e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetOrder() params type is error: ", k)
+				e.fail(newError(constant.ErrInvalid, "GetOrderHistosy", "params type is error: ", k))
 				return false
 			}
 			switch k {
-			case "uly":
-				params = append(params, "uly="+value)
-				break
-			case "ordType":
-				params = append(params, "ordType="+value)
-				break
-			case "state":
-				params = append(params, "state="+value)
-			case "category":
-				params = append(params, "category="+value)
-			case "after":
-				params = append(params, "after="+value)
-			case "before":
-				params = append(params, "before="+value)
-			case "limit":
-				params = append(params, "limit="+value)
+			case "uly", "ordType", "state", "category", "after", "before", "limit":
+				params = append(params, k+"="+value)
 			}
 		}
 	}
 
-	query := ""
-	for index, v := range params {
-		if index == 0 {
-			query = v
-		} else {
-			query = query + "&" + v
-		}
-	}
-	json, err := e.getAuthJSON(e.host+"trade/orders-history?"+query, "GET", params)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetOrder() error, ", err)
-		return false
-	}
-
-	if code := json.Get("code").MustString(); code != "0" {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "trade/orders-history error ")
+	json, err := e.getAuthJSON(e.host+"trade/orders-history?"+strings.Join(params, "&"), "GET", nil)
+	if err = okexCheck("GetOrderHistosy", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
-
-	json = json.Get("data")
-	count := len(json.MustArray())
-	orders := []Order{}
-	for i := 0; i < count; i++ {
-		orderJSON := json.GetIndex(i)
-		order := Order{
-			ID:         fmt.Sprint(orderJSON.Get("ordId").MustString()),
-			Price:      conver.Float64Must(orderJSON.Get("px").MustString()),
-			Amount:     conver.Float64Must(orderJSON.Get("sz").MustString()),
-			DealAmount: conver.Float64Must(0),
-			TradeType:  e.tradeTypeMap[orderJSON.Get("side").MustString()],
-			StockType:  instId,
-			Pnl:        conver.Float64Must(orderJSON.Get("pnl").MustString()),
-		}
-		orders = append(orders, order)
-	}
-	return orders
+	return e.parseOrders(json.Get("data"), stockType)
 }
 
 // GetOrders get all unfilled orders
 func (e *OKEX) GetOrders(stockType string) interface{} {
-	// stockType = strings.ToUpper(stockType)
-	if _, ok := e.stockTypeMap[stockType]; !ok {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetOrders() error, unrecognized stockType: ", stockType)
+	stockType = symbol.Normalize(stockType)
+	instId := e.toInstId(stockType)
+	if instId == "" {
+		e.fail(newError(constant.ErrInvalid, "GetOrders", "unrecognized stockType: ", stockType))
 		return false
 	}
-	params := []string{
-		"instId=" + e.stockTypeMap[stockType],
-		"instType=SPOT",
+	json, err := e.getAuthJSON(fmt.Sprintf("%vtrade/orders-pending?instType=%v&instId=%v", e.host, instTypeOf(instId), instId), "GET", nil)
+	if err = okexCheck("GetOrders", json, err); err != nil {
+		e.fail(err)
+		return false
 	}
+	return e.parseOrders(json.Get("data"), stockType)
+}
 
-	query := ""
-	for index, v := range params {
-		if index == 0 {
-			query = v
-		} else {
-			query = query + "&" + v
-		}
+// GetTrades get all filled orders recently
+func (e *OKEX) GetTrades(stockType string) interface{} {
+	stockType = symbol.Normalize(stockType)
+	if e.toInstId(stockType) == "" {
+		e.fail(newError(constant.ErrInvalid, "GetTrades", "unrecognized stockType: ", stockType))
+		return false
 	}
-	json, err := e.getAuthJSON(e.host+"trade/orders-pending?"+query, "GET", nil)
-
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetOrders() error, ", err)
+	instId := e.toInstId(stockType)
+	json, err := e.getAuthJSON(fmt.Sprintf("%vtrade/fills?instType=%v&instId=%v&limit=100", e.host, instTypeOf(instId), instId), "GET", nil)
+	if err = okexCheck("GetTrades", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
-
+	// 同一个订单可能分多次成交, 按订单ID合并成交明细, 成交价取成交量加权均价
 	orders := []Order{}
-	ordersJSON := json.Get("data")
-	count := len(ordersJSON.MustArray())
+	indexes := make(map[string]int)
+	fillsJSON := json.Get("data")
+	count := len(fillsJSON.MustArray())
 	for i := 0; i < count; i++ {
-		orderJSON := ordersJSON.GetIndex(i)
-		orders = append(orders, Order{
-			ID:         fmt.Sprint(orderJSON.Get("ordId").MustString()),
-			Price:      conver.Float64Must(orderJSON.Get("px").MustString()),
-			Amount:     conver.Float64Must(orderJSON.Get("sz").MustString()),
-			DealAmount: conver.Float64Must(orderJSON.Get("accFillSz").MustString()),
-			TradeType:  e.tradeTypeMap[orderJSON.Get("ordType").MustString()],
-			StockType:  stockType,
-		})
+		fillJSON := fillsJSON.GetIndex(i)
+		id := fillJSON.Get("ordId").MustString()
+		price := conver.Float64Must(fillJSON.Get("fillPx").MustString())
+		amount := conver.Float64Must(fillJSON.Get("fillSz").MustString())
+		fee := -conver.Float64Must(fillJSON.Get("fee").MustString())
+		feeCcy := fillJSON.Get("feeCcy").MustString()
+		index, ok := indexes[id]
+		if !ok {
+			indexes[id] = len(orders)
+			orders = append(orders, Order{
+				ID:        id,
+				FeeCcy:    feeCcy,
+				TradeType: e.tradeTypeMap[fillJSON.Get("side").MustString()],
+				StockType: stockType,
+			})
+			index = len(orders) - 1
+		}
+		order := &orders[index]
+		// 没有返回交易费时用本地的费率估算
+		if feeCcy == "" {
+			fill := Order{StockType: stockType, DealAmount: amount}
+			e.fees.Apply(&fill, price, fillJSON.Get("execType").MustString() == "T")
+			fee = fill.Fee
+			order.FeeCcy = fill.FeeCcy
+		}
+		if total := order.DealAmount + amount; total > 0 {
+			order.Price = (order.Price*order.DealAmount + price*amount) / total
+		}
+		order.DealAmount += amount
+		order.Amount = order.DealAmount
+		order.Fee += fee
 	}
 	return orders
 }
 
-// GetTrades get all filled orders recently
-func (e *OKEX) GetTrades(stockType string) interface{} {
-	stockType = strings.ToUpper(stockType)
-	if _, ok := e.stockTypeMap[stockType]; !ok {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetTrades() error, unrecognized stockType: ", stockType)
+// GetFeeRates get the maker & taker fee rates of a stockType
+func (e *OKEX) GetFeeRates(stockType string) interface{} {
+	stockType = symbol.Normalize(stockType)
+	if e.toInstId(stockType) == "" {
+		e.fail(newError(constant.ErrInvalid, "GetFeeRates", "unrecognized stockType: ", stockType))
 		return false
 	}
-	params := []string{
-		"symbol=" + e.stockTypeMap[stockType],
-		"status=1",
-		"current_page=1",
-		"page_length=200",
+	instId := e.toInstId(stockType)
+	instType := instTypeOf(instId)
+	query := "instType=" + instType + "&instId=" + instId
+	if instType != "SPOT" {
+		query = "instType=" + instType + "&instFamily=" + instFamilyOf(instId)
 	}
-	json, err := e.getAuthJSON(e.host+"order_history.do", "GET", params)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetTrades() error, ", err)
+	json, err := e.getAuthJSON(e.host+"account/trade-fee?"+query, "GET", nil)
+	if err = okexCheck("GetFeeRates", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
-	if result := json.Get("result").MustBool(); !result {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetTrades() error, the error number is ", json.Get("error_code").MustInt())
-		return false
+	// OKEX 的费率负数表示支出, 正数表示返佣, 这里统一转换成正数表示支出
+	data := json.Get("data").GetIndex(0)
+	rate := FeeRate{
+		Maker: -conver.Float64Must(data.Get("maker").MustString()),
+		Taker: -conver.Float64Must(data.Get("taker").MustString()),
 	}
-	orders := []Order{}
-	ordersJSON := json.Get("orders")
-	count := len(ordersJSON.MustArray())
-	for i := 0; i < count; i++ {
-		orderJSON := ordersJSON.GetIndex(i)
-		orders = append(orders, Order{
-			ID:         fmt.Sprint(orderJSON.Get("order_id").Interface()),
-			Price:      orderJSON.Get("price").MustFloat64(),
-			Amount:     orderJSON.Get("amount").MustFloat64(),
-			DealAmount: orderJSON.Get("deal_amount").MustFloat64(),
-			TradeType:  e.tradeTypeMap[orderJSON.Get("type").MustString()],
-			StockType:  stockType,
-		})
+	if instType != "SPOT" && strings.HasSuffix(instFamilyOf(instId), "-USDT") {
+		rate.Maker = -conver.Float64Must(data.Get("makerU").MustString())
+		rate.Taker = -conver.Float64Must(data.Get("takerU").MustString())
 	}
-	return orders
+	e.fees.SetRate(stockType, rate)
+	return rate
 }
 
 // CancelOrder cancel an order
 func (e *OKEX) CancelOrder(order Order) bool {
-	params := []string{
-		"symbol=" + e.stockTypeMap[order.StockType],
-		"order_id=" + order.ID,
+	instId := e.toInstId(order.StockType)
+	if instId == "" {
+		e.fail(newError(constant.ErrInvalid, "CancelOrder", "unrecognized stockType: ", order.StockType))
+		return false
 	}
-	json, err := e.getAuthJSON(e.host+"cancel_order.do", "GET", params)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "CancelOrder() error, ", err)
+	if order.ID == "" {
+		e.fail(newError(constant.ErrInvalid, "CancelOrder", "the order id is required"))
 		return false
 	}
-	if result := json.Get("result").MustBool(); !result {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "CancelOrder() error, the error number is ", json.Get("error_code").MustInt())
+	body := map[string]string{
+		"instId": instId,
+		"ordId":  order.ID,
+	}
+	json, err := e.getAuthJSON(e.host+"trade/cancel-order", "POST", body)
+	if err = okexCheck("CancelOrder", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
-	e.logger.Log(constant.CANCEL, order.StockType, order.Price, order.Amount-order.DealAmount, order)
+	e.logger.Log(constant.CANCEL, symbol.Normalize(order.StockType), order.Price, order.Amount-order.DealAmount, order)
 	return true
 }
 
 // getTicker get market ticker & depth
 func (e *OKEX) getTicker(stockType string, sizes ...interface{}) (ticker Ticker, err error) {
-	stockType = strings.ToUpper(stockType)
-	if _, ok := e.stockTypeMap[stockType]; !ok {
-		err = fmt.Errorf("GetTicker() error, unrecognized stockType: %+v", stockType)
-		return
-	}
 	size := 20
 	if len(sizes) > 0 && conver.IntMust(sizes[0]) > 0 {
 		size = conver.IntMust(sizes[0])
 	}
-	resp, err := get(fmt.Sprintf("%vmarket/books?instId=%v&sz=%v", e.host, e.stockTypeMap[stockType], size))
+	depth, err := e.getDepth("GetTicker", stockType, size)
 	if err != nil {
-		err = fmt.Errorf("GetTicker() error, %+v", err)
 		return
 	}
-	json, err := simplejson.NewJson(resp)
-	if err != nil {
-		err = fmt.Errorf("GetTicker() error, %+v", err)
+	ticker.Bids = depth.Bids
+	ticker.Asks = depth.Asks
+	if len(ticker.Bids) < 1 || len(ticker.Asks) < 1 {
+		err = newError(constant.ErrRejected, "GetTicker", "can not get enough Bids or Asks")
 		return
 	}
+	ticker.Buy = ticker.Bids[0].Price
+	ticker.Sell = ticker.Asks[0].Price
+	ticker.Mid = (ticker.Buy + ticker.Sell) / 2
+	return
+}
 
-	data := json.Get("data").GetIndex(0)
-	depthsJSON := data.Get("bids")
-	for i := 0; i < len(depthsJSON.MustArray()); i++ {
-		depthJSON := depthsJSON.GetIndex(i)
-		// d, _ := conver.Float64(depthJSON.GetIndex(0).MustString())
-		// d2, _ := conver.Float64(depthJSON.GetIndex(1).MustString())
-		price, amount := getPriceByJson(depthJSON)
-
-		ticker.Bids = append(ticker.Bids, OrderBook{
-			Price:  price,
-			Amount: amount,
-		})
+// getDepth get the order book from the locally maintained full-depth book,
+// or from a REST snapshot before the book is synchronized
+func (e *OKEX) getDepth(method, stockType string, size int) (depth Depth, err error) {
+	if e.toInstId(stockType) == "" {
+		err = newError(constant.ErrInvalid, method, "unrecognized stockType: ", stockType)
+		return
 	}
-	depthsJSON = data.Get("asks")
-	for i := len(depthsJSON.MustArray()); i > 0; i-- {
-		depthJSON := depthsJSON.GetIndex(i - 1)
-		price, amount := getPriceByJson(depthJSON)
-		ticker.Asks = append(ticker.Asks, OrderBook{
-			Price:  price,
-			Amount: amount,
-		})
+	instId := e.toInstId(stockType)
+	if e.feed != nil {
+		if depth, ok := e.feed.watchBooks(instId).snapshot(size); ok {
+			return depth, nil
+		}
 	}
-	if len(ticker.Bids) < 1 || len(ticker.Asks) < 1 {
-		err = fmt.Errorf("GetTicker() error, can not get enough Bids or Asks")
+	sz := size
+	if sz <= 0 || sz > 400 {
+		sz = 400
+	}
+	atomic.AddInt64(&e.lastTimes, 1)
+	resp, err := getContext(e.context(), fmt.Sprintf("%vmarket/books?instId=%v&sz=%v", e.host, instId, sz))
+	if err != nil {
+		err = okexCheck(method, nil, err)
 		return
 	}
-	ticker.Buy = ticker.Bids[0].Price
-	ticker.Sell = ticker.Asks[0].Price
-	ticker.Mid = (ticker.Buy + ticker.Sell) / 2
+	json, err := simplejson.NewJson(resp)
+	if err = okexCheck(method, json, err); err != nil {
+		return
+	}
+	data := json.Get("data").GetIndex(0)
+	depth.Time = conver.Int64Must(data.Get("ts").MustString())
+	depth.Bids = []OrderBook{}
+	depth.Asks = []OrderBook{}
+	// v5 接口的买单按价格从高到低排列, 卖单按价格从低到高排列, 不需要再翻转
+	for side, books := range map[string]*[]OrderBook{"bids": &depth.Bids, "asks": &depth.Asks} {
+		depthsJSON := data.Get(side)
+		for i := 0; i < len(depthsJSON.MustArray()); i++ {
+			price, amount := getPriceByJson(depthsJSON.GetIndex(i))
+			*books = append(*books, OrderBook{
+				Price:  price,
+				Amount: amount,
+			})
+		}
+	}
 	return
 }
 
+// GetDepth get the full-depth order book, size <= 0 means all levels
+func (e *OKEX) GetDepth(stockType string, sizes ...interface{}) interface{} {
+	size := 0
+	if len(sizes) > 0 {
+		size = conver.IntMust(sizes[0])
+	}
+	depth, err := e.getDepth("GetDepth", stockType, size)
+	if err != nil {
+		e.fail(err)
+		return false
+	}
+	return depth
+}
+
 // GetTicker get market ticker & depth
 func (e *OKEX) GetTicker(stockType string, sizes ...interface{}) interface{} {
 	ticker, err := e.getTicker(stockType, sizes...)
 	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, err)
+		e.fail(err)
 		return false
 	}
 	return ticker
@@ -632,48 +688,133 @@ func (e *OKEX) GetTicker(stockType string, sizes ...interface{}) interface{} {
 
 // GetRecords get candlestick data
 func (e *OKEX) GetRecords(stockType, period string, sizes ...interface{}) interface{} {
-	stockType = strings.ToUpper(stockType)
-	if _, ok := e.stockTypeMap[stockType]; !ok {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetRecords() error, unrecognized stockType: ", stockType)
+	stockType = symbol.Normalize(stockType)
+	if e.toInstId(stockType) == "" {
+		e.fail(newError(constant.ErrInvalid, "GetRecords", "unrecognized stockType: ", stockType))
 		return false
 	}
 	if _, ok := e.recordsPeriodMap[period]; !ok {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetRecords() error, unrecognized period: ", period)
+		e.fail(newError(constant.ErrInvalid, "GetRecords", "unrecognized period: ", period))
 		return false
 	}
 	size := 200
 	if len(sizes) > 0 && conver.IntMust(sizes[0]) > 0 {
 		size = conver.IntMust(sizes[0])
 	}
-	resp, err := get(fmt.Sprintf("%vmarket/candles?instId=%v&bar=%v&limit=%v", e.host, e.stockTypeMap[stockType], e.recordsPeriodMap[period], size))
+	resp, err := getContext(e.context(), fmt.Sprintf("%vmarket/candles?instId=%v&bar=%v&limit=%v", e.host, e.toInstId(stockType), e.recordsPeriodMap[period], size))
 	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetRecords() error, ", err)
+		e.fail(okexCheck("GetRecords", nil, err))
 		return false
 	}
 	json, err := simplejson.NewJson(resp)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetRecords() error, ", err)
+	if err = okexCheck("GetRecords", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
+	// OKEX 返回的K线按时间倒序排列, 这里转换成按时间正序排列
 	recordsNew := []Record{}
 	json = json.Get("data")
 	for i := len(json.MustArray()); i > 0; i-- {
 		recordJSON := json.GetIndex(i - 1)
-		recordTime := conver.Int64Must(recordJSON.GetIndex(0).MustString())
-		recordsNew = append([]Record{{
-			Time:   recordTime,
+		recordsNew = append(recordsNew, Record{
+			Time:   conver.Int64Must(recordJSON.GetIndex(0).MustString()),
 			Open:   conver.Float64Must(recordJSON.GetIndex(1).MustString()),
 			High:   conver.Float64Must(recordJSON.GetIndex(2).MustString()),
 			Low:    conver.Float64Must(recordJSON.GetIndex(3).MustString()),
 			Close:  conver.Float64Must(recordJSON.GetIndex(4).MustString()),
 			Volume: conver.Float64Must(recordJSON.GetIndex(5).MustString()),
-		}}, recordsNew...)
+		})
+	}
+	e.candles.Merge(stockType, period, recordsNew)
+	return e.candles.Get(stockType, period, size)
+}
+
+// GetPublicTrades get the latest executions of the market, sorted by time
+func (e *OKEX) GetPublicTrades(stockType string, sizes ...interface{}) interface{} {
+	stockType = symbol.Normalize(stockType)
+	if e.toInstId(stockType) == "" {
+		e.fail(newError(constant.ErrInvalid, "GetPublicTrades", "unrecognized stockType: ", stockType))
+		return false
+	}
+	size := 100
+	if len(sizes) > 0 && conver.IntMust(sizes[0]) > 0 {
+		size = conver.IntMust(sizes[0])
+	}
+	instId := e.toInstId(stockType)
+	// 优先使用 websocket 推送的成交数据, 还没有准备好的时候使用 REST 接口并用它初始化缓存
+	if e.feed != nil {
+		e.feed.watchTrades(instId)
+		if trades, ok := e.feed.recentTrades(instId, size); ok {
+			return trades
+		}
+	}
+	atomic.AddInt64(&e.lastTimes, 1)
+	resp, err := getContext(e.context(), fmt.Sprintf("%vmarket/trades?instId=%v&limit=%v", e.host, instId, maxPublicTrades))
+	if err != nil {
+		e.fail(okexCheck("GetPublicTrades", nil, err))
+		return false
+	}
+	json, err := simplejson.NewJson(resp)
+	if err = okexCheck("GetPublicTrades", json, err); err != nil {
+		e.fail(err)
+		return false
+	}
+	trades := []PublicTrade{}
+	json = json.Get("data")
+	for i := len(json.MustArray()); i > 0; i-- {
+		trades = append(trades, parseOkexTrade(json.GetIndex(i-1)))
+	}
+	if e.feed != nil {
+		e.feed.mergeTrades(instId, trades, true)
+	}
+	if len(trades) > size {
+		trades = trades[len(trades)-size:]
+	}
+	return trades
+}
+
+// getHistoryRecords get at most size candlesticks before the time(ms), sorted by time
+func (e *OKEX) getHistoryRecords(stockType, period string, before int64, size int) (records []Record, err error) {
+	stockType = symbol.Normalize(stockType)
+	if e.toInstId(stockType) == "" {
+		err = fmt.Errorf("GetHistoryRecords() error, unrecognized stockType: %+v", stockType)
+		return
+	}
+	if _, ok := e.recordsPeriodMap[period]; !ok {
+		err = fmt.Errorf("GetHistoryRecords() error, unrecognized period: %+v", period)
+		return
+	}
+	if size <= 0 || size > 100 {
+		size = 100
+	}
+	atomic.AddInt64(&e.lastTimes, 1)
+	resp, err := getContext(e.context(), fmt.Sprintf("%vmarket/history-candles?instId=%v&bar=%v&after=%v&limit=%v", e.host, e.toInstId(stockType), e.recordsPeriodMap[period], before, size))
+	if err != nil {
+		err = fmt.Errorf("GetHistoryRecords() error, %+v", err)
+		return
 	}
-	e.records[period] = append(e.records[period], recordsNew...)
-	if len(e.records[period]) > size {
-		e.records[period] = e.records[period][len(e.records[period])-size : len(e.records[period])]
+	json, err := simplejson.NewJson(resp)
+	if err != nil {
+		err = fmt.Errorf("GetHistoryRecords() error, %+v", err)
+		return
 	}
-	return e.records[period]
+	if code := json.Get("code").MustString(); code != "0" {
+		err = fmt.Errorf("GetHistoryRecords() error, %+v", json.Get("msg").MustString())
+		return
+	}
+	json = json.Get("data")
+	for i := len(json.MustArray()); i > 0; i-- {
+		recordJSON := json.GetIndex(i - 1)
+		records = append(records, Record{
+			Time:   conver.Int64Must(recordJSON.GetIndex(0).MustString()),
+			Open:   conver.Float64Must(recordJSON.GetIndex(1).MustString()),
+			High:   conver.Float64Must(recordJSON.GetIndex(2).MustString()),
+			Low:    conver.Float64Must(recordJSON.GetIndex(3).MustString()),
+			Close:  conver.Float64Must(recordJSON.GetIndex(4).MustString()),
+			Volume: conver.Float64Must(recordJSON.GetIndex(5).MustString()),
+		})
+	}
+	return
 }
 
 // GetPositions get the positions detail of this exchange
@@ -683,16 +824,16 @@ func (e *OKEX) GetPositions(options ...interface{}) interface{} {
 		var v string
 		var ok bool
 		if v, ok = value.(string); !ok {
-			e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetPositions() error, unrecognized stockType: ", v)
+			e.fail(newError(constant.ErrInvalid, "GetPositions", "unrecognized stockType: ", v))
 			return false
 		}
 		switch index {
 		case 0:
-			if _, ok := e.stockTypeMap[v]; !ok {
-				e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetTrades() error, unrecognized instId: ", v)
+			if e.toInstId(v) == "" {
+				e.fail(newError(constant.ErrInvalid, "GetPositions", "unrecognized instId: ", v))
 				return false
 			}
-			params = append(params, "?instId="+e.stockTypeMap[v])
+			params = append(params, "?instId="+e.toInstId(v))
 			break
 		case 1:
 			params = append(params, "instType="+v)
@@ -704,8 +845,8 @@ func (e *OKEX) GetPositions(options ...interface{}) interface{} {
 	}
 
 	json, err := e.getAuthJSON(e.host+"account/positions"+strings.Join(params, "&"), "GET", nil)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "GetPositions() error, ", err)
+	if err = okexCheck("GetPositions", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
 	positionsJSON := json.Get("data")
@@ -713,7 +854,6 @@ func (e *OKEX) GetPositions(options ...interface{}) interface{} {
 	positions := []Position{}
 	for i := 0; i < count; i++ {
 		positionJSON := positionsJSON.GetIndex(i)
-		fmt.Println(positionJSON)
 		positions = append(positions, Position{
 			MgnMode:       positionJSON.Get("mgnMode").MustString(),
 			Price:         conver.Float64Must(positionJSON.Get("avgPx").MustString()),
@@ -724,6 +864,7 @@ func (e *OKEX) GetPositions(options ...interface{}) interface{} {
 			Profit:        conver.Float64Must(positionJSON.Get("upl").MustString()),
 			ContractType:  positionJSON.Get("instType").MustString(),
 			TradeType:     positionJSON.Get("instType").MustString(),
+			StockType:     fromInstId(positionJSON.Get("instId").MustString()),
 			InstId:        positionJSON.Get("instId").MustString(),
 			PosId:         positionJSON.Get("posId").MustString(),
 			PosSide:       positionJSON.Get("posSide").MustString(),
@@ -734,23 +875,23 @@ func (e *OKEX) GetPositions(options ...interface{}) interface{} {
 
 // GetPositions get the positions detail of this exchange
 func (e *OKEX) ClosePosition(instId, mgnMode, posSide string, options ...interface{}) bool {
-	if _, ok := e.stockTypeMap[instId]; !ok {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "ClosePosition() error, unrecognized stockType: ", instId)
+	if e.toInstId(instId) == "" {
+		e.fail(newError(constant.ErrInvalid, "ClosePosition", "unrecognized stockType: ", instId))
 		return false
 	}
 
 	if !mgnModes[mgnMode] {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "ClosePosition() error, unrecognized mgnMode: ", mgnMode)
+		e.fail(newError(constant.ErrInvalid, "ClosePosition", "unrecognized mgnMode: ", mgnMode))
 		return false
 	}
 
 	if posSide == "" {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "ClosePosition() error, unrecognized posSide: ", posSide)
+		e.fail(newError(constant.ErrInvalid, "ClosePosition", "unrecognized posSide: ", posSide))
 		return false
 	}
 
 	body := map[string]interface{}{
-		"instId":  e.stockTypeMap[instId],
+		"instId":  e.toInstId(instId),
 		"mgnMode": mgnMode,
 		"posSide": posSide,
 	}
@@ -764,22 +905,17 @@ func (e *OKEX) ClosePosition(instId, mgnMode, posSide string, options ...interfa
 	}
 
 	json, err := e.getAuthJSON(e.host+"trade/close-position", "POST", body)
-	if err != nil {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "ClosePosition() error, ", err)
-		return false
-	}
-
-	if code := json.Get("code").MustString(); code != "0" {
-		e.logger.Log(constant.ERROR, "", 0.0, 0.0, "trade/close-position error ")
+	if err = okexCheck("ClosePosition", json, err); err != nil {
+		e.fail(err)
 		return false
 	}
 
 	return true
 }
 
-func sign(method, path, body string, secretKey []byte) (string, string) {
+func sign(method, path, body string, secretKey []byte, now time.Time) (string, string) {
 	format := "2006-01-02T15:04:05.999Z07:00"
-	t := time.Now().UTC().Format(format)
+	t := now.UTC().Format(format)
 	ts := fmt.Sprint(t)
 	s := ts + method + path + body
 	p := []byte(s)
@@ -788,6 +924,126 @@ func sign(method, path, body string, secretKey []byte) (string, string) {
 	return ts, base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
+// toInstId convert a symbol(canonical or legacy) to the instId of okex.com, returns "" if it is invalid
+func (e *OKEX) toInstId(stockType string) string {
+	instId, err := symbol.ToExchange(constant.Okex, stockType)
+	if err != nil {
+		return ""
+	}
+	return instId
+}
+
+// fromInstId convert an instId of okex.com to the canonical symbol, returns the instId if it is invalid
+func fromInstId(instId string) string {
+	sym, err := symbol.FromExchange(constant.Okex, instId)
+	if err != nil {
+		return instId
+	}
+	return sym.String()
+}
+
+// fail log an error and keep it for GetLastError
+func (e *OKEX) fail(err error) {
+	apiErr, ok := err.(Error)
+	if !ok {
+		apiErr = newError(constant.ErrNetwork, "", err)
+	}
+	e.set(apiErr)
+	e.logger.Log(constant.ERROR, "", 0.0, 0.0, apiErr)
+}
+
+// okexCheck check the response of a request, the error is categorized by the http status or the error code,
+// a rejected order is reported by the sCode in the data
+func okexCheck(method string, json *simplejson.Json, err error) error {
+	if err != nil {
+		category := httpCategory(err)
+		if herr, ok := err.(*httpError); ok {
+			if body, _ := simplejson.NewJson(herr.body); body != nil && body.Get("code").MustString() != "" {
+				code, msg := body.Get("code").MustString(), body.Get("msg").MustString()
+				if category == "" {
+					category = okexCategory(code)
+				}
+				return Error{Category: category, Method: method, Code: code, Message: msg}
+			}
+		}
+		if category == "" {
+			category = constant.ErrRejected
+		}
+		return newError(category, method, err)
+	}
+	if json == nil {
+		return newError(constant.ErrNetwork, method, "empty response")
+	}
+	code, msg := json.Get("code").MustString(), json.Get("msg").MustString()
+	if data := json.Get("data").GetIndex(0); data.Get("sCode").MustString("0") != "0" {
+		code, msg = data.Get("sCode").MustString(), data.Get("sMsg").MustString()
+	}
+	if code == "0" {
+		return nil
+	}
+	return Error{Category: okexCategory(code), Method: method, Code: code, Message: msg}
+}
+
+// okexCategory categorize an error code of okex.com
+func okexCategory(code string) string {
+	switch {
+	case code == "50011" || code == "50061":
+		return constant.ErrRateLimit
+	case strings.HasPrefix(code, "501") && len(code) == 5, code == "50030":
+		return constant.ErrAuth
+	case code == "50001" || code == "50004" || code == "50013" || code == "50026":
+		return constant.ErrNetwork
+	case code == "50000" || code == "50002" || code == "50014" || code == "51000" || code == "51001":
+		return constant.ErrInvalid
+	}
+	return constant.ErrRejected
+}
+
+// okexClockName the clock is shared by the exchanges which query the time from the same REST endpoint,
+// the scheme and the host are case insensitive
+func okexClockName(rest string) string {
+	u, err := netUrl.Parse(rest)
+	if err != nil {
+		return constant.Okex + "@" + rest
+	}
+	u.Scheme, u.Host = strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/") + "/"
+	return constant.Okex + "@" + u.String()
+}
+
+// okexServerTime get the server time of okex.com
+func okexServerTime(host string) clock.Fetcher {
+	return func() (int64, error) {
+		resp, err := get(host + "public/time")
+		if err != nil {
+			return 0, err
+		}
+		json, err := simplejson.NewJson(resp)
+		if err != nil {
+			return 0, err
+		}
+		return conver.Int64(json.Get("data").GetIndex(0).Get("ts").MustString())
+	}
+}
+
+// instTypeOf get the instrument type of an OKEX instId
+func instTypeOf(instId string) string {
+	sym, err := symbol.FromExchange(constant.Okex, instId)
+	if err != nil {
+		return symbol.Spot
+	}
+	return sym.Type
+}
+
+// instFamilyOf get the instrument family of an OKEX derivatives instId, eg: BTC-USDT-SWAP => BTC-USDT
+func instFamilyOf(instId string) string {
+	parts := strings.Split(instId, "-")
+	if len(parts) < 2 {
+		return instId
+	}
+	return parts[0] + "-" + parts[1]
+}
+
 func getPriceByJson(json *simplejson.Json) (float64, float64) {
 	var price float64
 	var amount float64
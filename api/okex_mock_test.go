@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/phonegapX/QuantBot/api/okexmock"
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+// newMockOKEX create an OKEX exchange which connects to a started mock server
+func newMockOKEX(t *testing.T) (*OKEX, *okexmock.Server) {
+	s := okexmock.NewServer()
+	s.AddAccount("access", "secret", "passphrase", map[string]float64{"USDT": 100000, "BTC": 1})
+	s.AddLiquidity("BTC-USDT", "sell", 30010, 0.5)
+	s.AddLiquidity("BTC-USDT", "buy", 29990, 0.5)
+	s.AddLiquidity("BTC-USDT-SWAP", "sell", 30000, 10)
+	s.AddLiquidity("BTC-USDT-SWAP", "buy", 29900, 10)
+	s.Start()
+	t.Cleanup(s.Close)
+	e := NewOKEX(Option{
+		Type:       constant.Okex,
+		Name:       "okex",
+		AccessKey:  "access",
+		SecretKey:  "secret",
+		Passphrase: "passphrase",
+		Host:       s.URL,
+	}).(*OKEX)
+	return e, s
+}
+
+func TestOKEXMockTrade(t *testing.T) {
+	e, s := newMockOKEX(t)
+	if e.feed != nil {
+		t.Fatal("a custom host should not connect to the websocket")
+	}
+	if got, want := e.GetTicker("BTC/USDT"), (Ticker{
+		Buy:  29990,
+		Mid:  30000,
+		Sell: 30010,
+		Bids: []OrderBook{{Price: 29990, Amount: 0.5}},
+		Asks: []OrderBook{{Price: 30010, Amount: 0.5}},
+	}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetTicker() = %+v, want %+v", got, want)
+	}
+	id, ok := e.Trade("BUY", "BTC/USDT", 30010, 0.2).(string)
+	if !ok {
+		t.Fatal("Trade() failed")
+	}
+	orders, ok := e.GetOrder("BTC/USDT", id).([]Order)
+	if !ok || len(orders) != 1 || orders[0].DealAmount != 0.2 {
+		t.Fatalf("GetOrder(%v) = %+v", id, orders)
+	}
+	if b := s.Balance("access", "BTC"); b.Avail != 1.2 {
+		t.Errorf("BTC = %+v, want 1.2 available", b)
+	}
+	if got := e.Trade("SELL", "BTC/USDT", 29990, 100); got != false {
+		t.Errorf("Trade() with an insufficient balance = %v, want false", got)
+	}
+	if _, ok := e.GetAccount().(map[string]float64); !ok {
+		t.Error("GetAccount() failed")
+	}
+}
+
+func TestOKEXMockRecords(t *testing.T) {
+	e, s := newMockOKEX(t)
+	s.SetCandles("BTC-USDT", "1m", []okexmock.Candle{
+		{Ts: 1700000060000, Open: 30010, High: 30030, Low: 30000, Close: 30020, Volume: 8},
+		{Ts: 1700000000000, Open: 30000, High: 30015, Low: 29990, Close: 30010, Volume: 10},
+	})
+	want := []Record{
+		{Time: 1700000000000, Open: 30000, High: 30015, Low: 29990, Close: 30010, Volume: 10},
+		{Time: 1700000060000, Open: 30010, High: 30030, Low: 30000, Close: 30020, Volume: 8},
+	}
+	if got := e.GetRecords("BTC/USDT", "M", 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRecords() = %+v, want %+v", got, want)
+	}
+}
+
+func TestOKEXMockPosition(t *testing.T) {
+	e, s := newMockOKEX(t)
+	if _, ok := e.Trade("BUY", "BTC/USDT:USDT", 30000, 2).(string); !ok {
+		t.Fatal("Trade() failed")
+	}
+	if p := s.Position("access", "BTC-USDT-SWAP", "long"); p.Pos != 2 {
+		t.Fatalf("position = %+v, want 2 long", p)
+	}
+	// 被限频时返回 false, 持仓不变
+	s.Fail("/api/v5/trade/close-position", http.StatusTooManyRequests, "50011", "Too Many Requests")
+	if e.ClosePosition("BTC/USDT:USDT", "cross", "long") {
+		t.Error("ClosePosition() should fail when it is rate limited")
+	}
+	if p := s.Position("access", "BTC-USDT-SWAP", "long"); p.Pos != 2 {
+		t.Fatalf("position = %+v, want 2 long", p)
+	}
+	if !e.ClosePosition("BTC/USDT:USDT", "cross", "long") {
+		t.Fatal("ClosePosition() failed")
+	}
+	if p := s.Position("access", "BTC-USDT-SWAP", "long"); p.Pos != 0 {
+		t.Errorf("position = %+v, want closed", p)
+	}
+}
+
+func TestOKEXSetContext(t *testing.T) {
+	block := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer s.Close()
+	defer close(block)
+	e := NewOKEX(Option{Type: constant.Okex, Name: "okex", Host: s.URL}).(*OKEX)
+	ctx, cancel := context.WithCancel(context.Background())
+	e.SetContext(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if got := e.GetTicker("BTC/USDT"); got != false {
+		t.Errorf("GetTicker() = %v, want false", got)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("GetTicker() returned in %v, the request should be aborted", d)
+	}
+	// 取消后 AutoSleep 立即返回
+	e.SetLimit(1)
+	atomic.StoreInt64(&e.lastTimes, 100)
+	start = time.Now()
+	e.AutoSleep()
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("AutoSleep() returned in %v after the context is canceled", d)
+	}
+}
+
+func TestDownloadRecords(t *testing.T) {
+	e, s := newMockOKEX(t)
+	candles := []okexmock.Candle{}
+	for i := int64(0); i < 250; i++ {
+		candles = append(candles, okexmock.Candle{Ts: 1700000000000 + i*60000, Open: 30000, High: 30010, Low: 29990, Close: 30000 + float64(i), Volume: 1})
+	}
+	s.SetCandles("BTC-USDT", "1m", candles)
+	begin, end := int64(1700000000000), int64(1700000000000+250*60000)
+	if saved, err := DownloadRecords(e, "btc/usdt", "M", begin, end, nil); err != nil || saved != 250 {
+		t.Fatalf("DownloadRecords() = %v, %v, want 250 saved", saved, err)
+	}
+	// 已经下载的K线不再重复下载
+	if saved, err := DownloadRecords(e, "BTC/USDT", "M", begin, end, nil); err != nil || saved != 0 {
+		t.Errorf("DownloadRecords() again = %v, %v, want 0 saved", saved, err)
+	}
+	records, err := LoadRecords(constant.Okex, "btc/usdt", "M", begin+60000, begin+3*60000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Time: begin + 60000, Open: 30000, High: 30010, Low: 29990, Close: 30001, Volume: 1},
+		{Time: begin + 2*60000, Open: 30000, High: 30010, Low: 29990, Close: 30002, Volume: 1},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("LoadRecords() = %+v, want %+v", records, want)
+	}
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/api/replay"
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+// newTestOKEX create an OKEX exchange which is served by the fixtures, the websocket feed is disabled
+func newTestOKEX(t *testing.T, fixtures ...string) (*OKEX, *replay.Player) {
+	paths := []string{filepath.Join("testdata", "okex", "time.json")}
+	for _, f := range fixtures {
+		paths = append(paths, filepath.Join("testdata", "okex", f))
+	}
+	player, err := replay.Load(paths...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := client.Transport
+	client.Transport = player
+	t.Cleanup(func() { client.Transport = transport })
+	e := NewOKEX(Option{
+		Type:       constant.Okex,
+		Name:       "okex",
+		AccessKey:  "access",
+		SecretKey:  "secret",
+		Passphrase: "passphrase",
+	}).(*OKEX)
+	e.feed = nil
+	return e, player
+}
+
+func TestOKEXGetTicker(t *testing.T) {
+	e, _ := newTestOKEX(t, "ticker.json")
+	tests := []struct {
+		name      string
+		stockType string
+		sizes     []interface{}
+		want      interface{}
+	}{
+		{
+			name:      "spot",
+			stockType: "btc/usdt",
+			want: Ticker{
+				Buy:  30000.5,
+				Mid:  30001,
+				Sell: 30001.5,
+				Bids: []OrderBook{{Price: 30000.5, Amount: 0.5}, {Price: 29999, Amount: 2}},
+				Asks: []OrderBook{{Price: 30001.5, Amount: 0.8}, {Price: 30002, Amount: 1.2}},
+			},
+		},
+		{
+			name:      "swap",
+			stockType: "BTC/USDT:USDT",
+			sizes:     []interface{}{1},
+			want: Ticker{
+				Buy:  30009.9,
+				Mid:  30009.95,
+				Sell: 30010,
+				Bids: []OrderBook{{Price: 30009.9, Amount: 20}},
+				Asks: []OrderBook{{Price: 30010, Amount: 15}},
+			},
+		},
+		{name: "empty book", stockType: "ETH/USDT", want: false},
+		{name: "invalid symbol", stockType: "BTCUSDT", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.GetTicker(tt.stockType, tt.sizes...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetTicker(%v) = %+v, want %+v", tt.stockType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOKEXGetRecords(t *testing.T) {
+	e, _ := newTestOKEX(t, "records.json")
+	tests := []struct {
+		name      string
+		stockType string
+		period    string
+		size      int
+		want      interface{}
+	}{
+		{
+			name:      "ascending order",
+			stockType: "BTC/USDT",
+			period:    "M",
+			size:      3,
+			want: []Record{
+				{Time: 1700000000000, Open: 30000, High: 30015, Low: 29990, Close: 30010, Volume: 10},
+				{Time: 1700000060000, Open: 30010, High: 30030, Low: 30000, Close: 30020, Volume: 8},
+				{Time: 1700000120000, Open: 30020, High: 30050, Low: 30010, Close: 30040, Volume: 12.5},
+			},
+		},
+		{
+			name:      "hour",
+			stockType: "eth/usdt",
+			period:    "H",
+			size:      2,
+			want: []Record{
+				{Time: 1700000000000, Open: 2000, High: 2015, Low: 1990, Close: 2010, Volume: 80},
+				{Time: 1700003600000, Open: 2010, High: 2030, Low: 2000, Close: 2020, Volume: 100},
+			},
+		},
+		{name: "invalid period", stockType: "BTC/USDT", period: "M3", size: 3, want: false},
+		{name: "invalid symbol", stockType: "BTC", period: "M", size: 3, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.GetRecords(tt.stockType, tt.period, tt.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetRecords(%v, %v) = %+v, want %+v", tt.stockType, tt.period, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOKEXTrade(t *testing.T) {
+	e, player := newTestOKEX(t, "trade.json")
+	tests := []struct {
+		name      string
+		tradeType string
+		stockType string
+		price     interface{}
+		amount    interface{}
+		want      interface{}
+	}{
+		{name: "buy", tradeType: "buy", stockType: "BTC/USDT", price: 30000, amount: 0.01, want: "312269865356374016"},
+		{name: "sell", tradeType: "SELL", stockType: "btc/usdt", price: "31000", amount: "0.02", want: "312269865356374017"},
+		{name: "rejected", tradeType: "BUY", stockType: "BTC/USDT", price: 30000, amount: 1000, want: false},
+		{name: "invalid tradeType", tradeType: "LONG", stockType: "BTC/USDT", price: 30000, amount: 1, want: false},
+		{name: "invalid symbol", tradeType: "BUY", stockType: "BTC_USDT", price: 30000, amount: 1, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.Trade(tt.tradeType, tt.stockType, tt.price, tt.amount); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Trade(%v, %v, %v, %v) = %v, want %v", tt.tradeType, tt.stockType, tt.price, tt.amount, got, tt.want)
+			}
+		})
+	}
+	// 私有接口需要签名, 并且不能泄露密钥
+	for _, req := range player.Requests() {
+		if req.Method != "POST" {
+			continue
+		}
+		for _, h := range []string{"Ok-Access-Key", "Ok-Access-Sign", "Ok-Access-Passphrase", "Ok-Access-Timestamp"} {
+			if req.RequestHeader[h] != replay.Redacted {
+				t.Errorf("header %v = %q, want %q", h, req.RequestHeader[h], replay.Redacted)
+			}
+		}
+		if _, ok := req.RequestHeader["X-Simulated-Trading"]; ok {
+			t.Errorf("a live exchange should not send x-simulated-trading")
+		}
+	}
+}
+
+func TestOKEXGetPositions(t *testing.T) {
+	e, _ := newTestOKEX(t, "positions.json")
+	long := Position{
+		InstId:        "BTC-USDT-SWAP",
+		MgnMode:       "cross",
+		Price:         30000.5,
+		Leverage:      5,
+		Amount:        10,
+		ConfirmAmount: 10,
+		Profit:        12.3,
+		ContractType:  "SWAP",
+		TradeType:     "SWAP",
+		StockType:     "BTC/USDT:USDT",
+		PosId:         "307173036051017730",
+		PosSide:       "long",
+	}
+	short := Position{
+		InstId:        "ETH-USD-SWAP",
+		MgnMode:       "isolated",
+		Price:         2000,
+		Leverage:      10,
+		Amount:        3,
+		ConfirmAmount: 3,
+		Profit:        -0.01,
+		ContractType:  "SWAP",
+		TradeType:     "SWAP",
+		StockType:     "ETH/USD:ETH",
+		PosId:         "307173036051017731",
+		PosSide:       "short",
+	}
+	tests := []struct {
+		name    string
+		options []interface{}
+		want    interface{}
+	}{
+		{name: "all", want: []Position{long, short}},
+		{name: "by symbol", options: []interface{}{"BTC/USDT:USDT", "SWAP"}, want: []Position{long}},
+		{name: "invalid symbol", options: []interface{}{"BTC"}, want: false},
+		{name: "invalid option", options: []interface{}{1}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.GetPositions(tt.options...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetPositions(%v) = %+v, want %+v", tt.options, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOKEXClosePosition(t *testing.T) {
+	e, _ := newTestOKEX(t, "close.json")
+	tests := []struct {
+		name      string
+		stockType string
+		mgnMode   string
+		posSide   string
+		want      bool
+	}{
+		{name: "closed", stockType: "BTC/USDT:USDT", mgnMode: "cross", posSide: "long", want: true},
+		{name: "no position", stockType: "ETH/USDT/SWAP", mgnMode: "isolated", posSide: "short", want: false},
+		{name: "invalid mgnMode", stockType: "BTC/USDT:USDT", mgnMode: "cash", posSide: "long", want: false},
+		{name: "invalid posSide", stockType: "BTC/USDT:USDT", mgnMode: "cross", posSide: "", want: false},
+		{name: "invalid symbol", stockType: "BTC", mgnMode: "cross", posSide: "long", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.ClosePosition(tt.stockType, tt.mgnMode, tt.posSide); got != tt.want {
+				t.Errorf("ClosePosition(%v, %v, %v) = %v, want %v", tt.stockType, tt.mgnMode, tt.posSide, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOKEXClock(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Option
+		same bool
+	}{
+		{"sandbox and live", Option{Type: constant.Okex}, Option{Type: constant.Okex, Test: "1"}, true},
+		{"trailing slash", Option{Type: constant.Okex, Host: "http://127.0.0.1:8080"}, Option{Type: constant.Okex, Host: "http://127.0.0.1:8080//"}, true},
+		{"host case", Option{Type: constant.Okex, Host: "http://LocalHost:8080/"}, Option{Type: constant.Okex, Host: "http://localhost:8080"}, true},
+		{"another host", Option{Type: constant.Okex}, Option{Type: constant.Okex, Host: "http://127.0.0.1:8080"}, false},
+	}
+	for _, tt := range tests {
+		a, b := NewOKEX(tt.a).(*OKEX), NewOKEX(tt.b).(*OKEX)
+		if same := a.clock == b.clock; same != tt.same {
+			t.Errorf("%v: the clocks of %v and %v are shared = %v, want %v", tt.name, a.host, b.host, same, tt.same)
+		}
+	}
+}
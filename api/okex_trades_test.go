@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+// newTradeFeed create a feed which never connects to okex.com, the trades are merged by the test
+func newTradeFeed(t *testing.T, connected bool) *okexFeed {
+	f := getOkexFeed("trades-" + t.Name())
+	f.once.Do(func() {})
+	f.connected = connected
+	return f
+}
+
+// trades create the trades of the ids, the time of a trade is its id
+func trades(ids ...int) []PublicTrade {
+	ts := []PublicTrade{}
+	for _, id := range ids {
+		ts = append(ts, PublicTrade{ID: fmt.Sprint(id), Time: int64(id), Price: 30000, Amount: 0.1, Side: constant.TradeTypeBuy})
+	}
+	return ts
+}
+
+func TestOKEXTradeTape(t *testing.T) {
+	f := newTradeFeed(t, false)
+	// 没有连接时 REST 的数据不能让缓存变为可用, 之后推送的成交会丢失
+	f.mergeTrades("BTC-USDT", trades(1, 2), true)
+	if _, ok := f.recentTrades("BTC-USDT", 10); ok {
+		t.Fatal("the tape should not be ready while disconnected")
+	}
+	f.connected = true
+	f.mergeTrades("BTC-USDT", trades(4, 5), false)
+	if _, ok := f.recentTrades("BTC-USDT", 10); ok {
+		t.Fatal("the tape should not be ready before it is seeded")
+	}
+	// REST 的数据和推送的成交重叠, 按成交ID去重并按时间排序
+	f.mergeTrades("BTC-USDT", trades(2, 3, 4), true)
+	tests := []struct {
+		size int
+		want []PublicTrade
+	}{
+		{10, trades(1, 2, 3, 4, 5)},
+		{0, trades(1, 2, 3, 4, 5)},
+		{2, trades(4, 5)},
+	}
+	for _, tt := range tests {
+		if got, ok := f.recentTrades("BTC-USDT", tt.size); !ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("recentTrades(%v) = %+v, %v, want %+v", tt.size, got, ok, tt.want)
+		}
+	}
+	if _, ok := f.recentTrades("ETH-USDT", 10); ok {
+		t.Error("the tape of another instId should not be ready")
+	}
+}
+
+func TestOKEXTradeTapeCap(t *testing.T) {
+	f := newTradeFeed(t, true)
+	ids := []int{}
+	for i := 1; i <= maxPublicTrades+100; i++ {
+		ids = append(ids, i)
+	}
+	f.mergeTrades("BTC-USDT", trades(ids...), true)
+	got, ok := f.recentTrades("BTC-USDT", 0)
+	if !ok || len(got) != maxPublicTrades || got[0].ID != "101" || got[len(got)-1].ID != fmt.Sprint(maxPublicTrades+100) {
+		t.Fatalf("recentTrades() = %v trades from %+v, want %v trades from 101", len(got), got[0], maxPublicTrades)
+	}
+	if len(f.seen["BTC-USDT"]) != maxPublicTrades {
+		t.Errorf("%v trade ids are kept, want %v", len(f.seen["BTC-USDT"]), maxPublicTrades)
+	}
+}
+
+func TestOKEXGetPublicTrades(t *testing.T) {
+	requests := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/market/trades" || r.URL.Query().Get("instId") != "BTC-USDT" || r.URL.Query().Get("limit") != fmt.Sprint(maxPublicTrades) {
+			t.Errorf("request = %v", r.URL)
+		}
+		// okex 按时间倒序返回
+		data := []string{}
+		for id := 3; id > 0; id-- {
+			data = append(data, fmt.Sprintf(`{"instId":"BTC-USDT","tradeId":"%v","px":"30000","sz":"0.1","side":"buy","ts":"%v"}`, id, id))
+		}
+		fmt.Fprintf(w, `{"code":"0","msg":"","data":[%v]}`, strings.Join(data, ","))
+	}))
+	defer server.Close()
+	e := NewOKEX(Option{Type: constant.Okex, Name: "okex"}).(*OKEX)
+	e.host = server.URL + "/"
+	e.feed = newTradeFeed(t, false)
+
+	// 没有连接时每次都使用 REST 接口
+	for i := 1; i <= 2; i++ {
+		if got := e.GetPublicTrades("BTC/USDT", 2); !reflect.DeepEqual(got, trades(2, 3)) {
+			t.Errorf("GetPublicTrades() = %+v, want %+v", got, trades(2, 3))
+		}
+		if n := atomic.LoadInt32(&requests); n != int32(i) {
+			t.Errorf("%v requests are sent, want %v", n, i)
+		}
+	}
+	// 连接后 REST 的数据初始化缓存, 之后使用推送的成交
+	e.feed.connected = true
+	e.feed.mergeTrades("BTC-USDT", trades(4), false)
+	if got := e.GetPublicTrades("BTC/USDT"); !reflect.DeepEqual(got, trades(1, 2, 3)) {
+		t.Errorf("GetPublicTrades() = %+v, want %+v", got, trades(1, 2, 3))
+	}
+	if got := e.GetPublicTrades("BTC/USDT", 3); !reflect.DeepEqual(got, trades(2, 3, 4)) {
+		t.Errorf("GetPublicTrades() = %+v, want %+v", got, trades(2, 3, 4))
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("%v requests are sent, want 3", n)
+	}
+}
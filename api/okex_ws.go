@@ -0,0 +1,325 @@
+package api
+
+import (
+	"hash/crc32"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+	"github.com/gorilla/websocket"
+	"github.com/miaolz123/conver"
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+const (
+	okexPublicWS     = "wss://ws.okx.com:8443/ws/v5/public"
+	okexPublicTestWS = "wss://wspap.okx.com:8443/ws/v5/public?brokerId=9999"
+	maxPublicTrades  = 500 //每个货币类型最多缓存的公开成交数量
+)
+
+// okexFeed the public market data feed of okex.com over websocket,
+// shared by all the OKEX exchanges which connect to the same url
+type okexFeed struct {
+	url  string
+	once sync.Once
+	wmu  sync.Mutex //websocket 连接只允许一个写入者
+	conn *websocket.Conn
+
+	mu        sync.RWMutex
+	connected bool
+	args      map[string]map[string]string //已订阅的频道, key: channel:instId
+	handlers  map[string]func(action string, data *simplejson.Json)
+	trades    map[string][]PublicTrade //最近的公开成交, 按时间正序排列
+	seen      map[string]map[string]bool
+	ready     map[string]bool       //频道在本次连接后是否已经得到完整的数据
+	books     map[string]*depthBook //本地维护的全量深度
+}
+
+var (
+	okexFeedsMu sync.Mutex
+	okexFeeds   = map[string]*okexFeed{}
+)
+
+// getOkexFeed get the public market data feed of the url
+func getOkexFeed(url string) *okexFeed {
+	okexFeedsMu.Lock()
+	defer okexFeedsMu.Unlock()
+	if f, ok := okexFeeds[url]; ok {
+		return f
+	}
+	f := &okexFeed{
+		url:      url,
+		args:     make(map[string]map[string]string),
+		handlers: make(map[string]func(string, *simplejson.Json)),
+		trades:   make(map[string][]PublicTrade),
+		seen:     make(map[string]map[string]bool),
+		ready:    make(map[string]bool),
+		books:    make(map[string]*depthBook),
+	}
+	okexFeeds[url] = f
+	return f
+}
+
+// subscribe subscribe a channel of an instId once, the feed is started at the first subscription
+func (f *okexFeed) subscribe(channel, instId string, handler func(action string, data *simplejson.Json)) {
+	key := channel + ":" + instId
+	f.mu.Lock()
+	if _, ok := f.args[key]; ok {
+		f.mu.Unlock()
+		return
+	}
+	arg := map[string]string{"channel": channel, "instId": instId}
+	f.args[key] = arg
+	f.handlers[key] = handler
+	f.mu.Unlock()
+	f.once.Do(func() { go f.run() })
+	f.send("subscribe", arg)
+}
+
+// resubscribe resubscribe a channel to get a fresh snapshot
+func (f *okexFeed) resubscribe(channel, instId string) {
+	f.mu.Lock()
+	arg, ok := f.args[channel+":"+instId]
+	f.ready[channel+":"+instId] = false
+	f.mu.Unlock()
+	if ok {
+		f.send("unsubscribe", arg)
+		f.send("subscribe", arg)
+	}
+}
+
+func (f *okexFeed) send(op string, args ...map[string]string) {
+	f.wmu.Lock()
+	defer f.wmu.Unlock()
+	if f.conn == nil || len(args) == 0 {
+		return
+	}
+	if err := f.conn.WriteJSON(map[string]interface{}{"op": op, "args": args}); err != nil {
+		log.Printf("OKEX websocket %v error: %v\n", op, err)
+	}
+}
+
+// run keep the websocket connected and dispatch the messages to the handlers
+func (f *okexFeed) run() {
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(f.url, nil)
+		if err != nil {
+			log.Printf("OKEX websocket connect error: %v\n", err)
+			time.Sleep(3 * time.Second)
+			continue
+		}
+		f.wmu.Lock()
+		f.conn = conn
+		f.wmu.Unlock()
+		f.mu.Lock()
+		f.connected = true
+		args := []map[string]string{}
+		for key, arg := range f.args {
+			args = append(args, arg)
+			f.ready[key] = false
+		}
+		f.mu.Unlock()
+		f.send("subscribe", args...)
+		done := make(chan struct{})
+		go f.ping(conn, done)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				log.Printf("OKEX websocket read error: %v\n", err)
+				break
+			}
+			f.dispatch(message)
+		}
+		close(done)
+		f.wmu.Lock()
+		f.conn = nil
+		f.wmu.Unlock()
+		conn.Close()
+		f.mu.Lock()
+		f.connected = false
+		for key := range f.ready {
+			f.ready[key] = false
+		}
+		for _, book := range f.books {
+			book.invalidate()
+		}
+		f.mu.Unlock()
+		time.Sleep(time.Second)
+	}
+}
+
+// ping OKEX closes the connection if there is no data in 30 seconds
+func (f *okexFeed) ping(conn *websocket.Conn, done chan struct{}) {
+	ticker := time.NewTicker(20 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			f.wmu.Lock()
+			conn.WriteMessage(websocket.TextMessage, []byte("ping"))
+			f.wmu.Unlock()
+		}
+	}
+}
+
+func (f *okexFeed) dispatch(message []byte) {
+	if string(message) == "pong" {
+		return
+	}
+	json, err := simplejson.NewJson(message)
+	if err != nil {
+		return
+	}
+	if event := json.Get("event").MustString(); event != "" {
+		if event == "error" {
+			log.Printf("OKEX websocket error: %v %v\n", json.Get("code").MustString(), json.Get("msg").MustString())
+		}
+		return
+	}
+	arg := json.Get("arg")
+	key := arg.Get("channel").MustString() + ":" + arg.Get("instId").MustString()
+	f.mu.RLock()
+	handler := f.handlers[key]
+	f.mu.RUnlock()
+	if handler != nil {
+		handler(json.Get("action").MustString(), json.Get("data"))
+	}
+}
+
+// watchTrades subscribe the trades channel of an instId
+func (f *okexFeed) watchTrades(instId string) {
+	f.subscribe("trades", instId, func(action string, data *simplejson.Json) {
+		trades := []PublicTrade{}
+		for i := 0; i < len(data.MustArray()); i++ {
+			trades = append(trades, parseOkexTrade(data.GetIndex(i)))
+		}
+		f.mergeTrades(instId, trades, false)
+	})
+}
+
+// mergeTrades merge the trades by trade ID, the tape becomes ready once it is seeded by a full snapshot
+func (f *okexFeed) mergeTrades(instId string, trades []PublicTrade, snapshot bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	seen := f.seen[instId]
+	if seen == nil {
+		seen = make(map[string]bool)
+		f.seen[instId] = seen
+	}
+	tape := f.trades[instId]
+	for _, t := range trades {
+		if !seen[t.ID] {
+			seen[t.ID] = true
+			tape = append(tape, t)
+		}
+	}
+	sort.SliceStable(tape, func(i, j int) bool { return tape[i].Time < tape[j].Time })
+	if len(tape) > maxPublicTrades {
+		for _, t := range tape[:len(tape)-maxPublicTrades] {
+			delete(seen, t.ID)
+		}
+		tape = tape[len(tape)-maxPublicTrades:]
+	}
+	f.trades[instId] = tape
+	if snapshot && f.connected {
+		f.ready["trades:"+instId] = true
+	}
+}
+
+// recentTrades get the latest size trades if the tape is ready
+func (f *okexFeed) recentTrades(instId string, size int) ([]PublicTrade, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if !f.ready["trades:"+instId] {
+		return nil, false
+	}
+	tape := f.trades[instId]
+	if size > 0 && len(tape) > size {
+		tape = tape[len(tape)-size:]
+	}
+	return append([]PublicTrade{}, tape...), true
+}
+
+// watchBooks subscribe the books channel of an instId and maintain the full-depth order book,
+// the book is resynchronized by a new snapshot when a sequence gap or a checksum error occurs
+func (f *okexFeed) watchBooks(instId string) *depthBook {
+	f.mu.Lock()
+	book, ok := f.books[instId]
+	if !ok {
+		book = &depthBook{}
+		f.books[instId] = book
+	}
+	f.mu.Unlock()
+	f.subscribe("books", instId, func(action string, data *simplejson.Json) {
+		json := data.GetIndex(0)
+		bids := parseOkexLevels(json.Get("bids"))
+		asks := parseOkexLevels(json.Get("asks"))
+		seqID := json.Get("seqId").MustInt64()
+		ts := conver.Int64Must(json.Get("ts").MustString())
+		switch action {
+		case "snapshot":
+			book.reset(bids, asks, seqID, ts)
+		case "update":
+			if !book.synced() {
+				return
+			}
+			if prevSeqID := json.Get("prevSeqId").MustInt64(); prevSeqID != book.lastSeqID() {
+				log.Printf("OKEX %v books sequence gap: %v != %v, resync\n", instId, prevSeqID, book.lastSeqID())
+				book.invalidate()
+				f.resubscribe("books", instId)
+				return
+			}
+			book.update(bids, asks, seqID, ts)
+		default:
+			return
+		}
+		if checksum, err := json.Get("checksum").Int64(); err == nil && int32(checksum) != okexChecksum(book) {
+			log.Printf("OKEX %v books checksum error, resync\n", instId)
+			book.invalidate()
+			f.resubscribe("books", instId)
+		}
+	})
+	return book
+}
+
+func parseOkexLevels(json *simplejson.Json) (levels []depthLevel) {
+	for i := 0; i < len(json.MustArray()); i++ {
+		level := json.GetIndex(i)
+		levels = append(levels, newDepthLevel(level.GetIndex(0).MustString(), level.GetIndex(1).MustString()))
+	}
+	return
+}
+
+// okexChecksum the crc32 of the top 25 levels, bid1:ask1:bid2:ask2...
+func okexChecksum(book *depthBook) int32 {
+	bids, asks := book.topLevels(25)
+	fields := []string{}
+	for i := 0; i < 25; i++ {
+		if i < len(bids) {
+			fields = append(fields, bids[i].px+":"+bids[i].sz)
+		}
+		if i < len(asks) {
+			fields = append(fields, asks[i].px+":"+asks[i].sz)
+		}
+	}
+	return int32(crc32.ChecksumIEEE([]byte(strings.Join(fields, ":"))))
+}
+
+func parseOkexTrade(json *simplejson.Json) PublicTrade {
+	side := constant.TradeTypeBuy
+	if json.Get("side").MustString() == "sell" {
+		side = constant.TradeTypeSell
+	}
+	return PublicTrade{
+		ID:     json.Get("tradeId").MustString(),
+		Time:   conver.Int64Must(json.Get("ts").MustString()),
+		Price:  conver.Float64Must(json.Get("px").MustString()),
+		Amount: conver.Float64Must(json.Get("sz").MustString()),
+		Side:   side,
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestFeed create a feed which writes to a local websocket server instead of okex.com,
+// the messages from okex.com are dispatched by the test, the requests sent by the feed are returned by the channel
+func newTestFeed(t *testing.T) (*okexFeed, chan string) {
+	requests := make(chan string, 10)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			requests <- strings.TrimSpace(string(message))
+		}
+	}))
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	f := getOkexFeed(server.URL)
+	f.once.Do(func() {}) // 不连接 okex.com
+	f.conn = conn
+	f.connected = true
+	return f, requests
+}
+
+// nextRequest wait for a request sent by the feed
+func nextRequest(t *testing.T, requests chan string) string {
+	select {
+	case r := <-requests:
+		return r
+	case <-time.After(time.Second):
+		t.Fatal("no request is sent")
+	}
+	return ""
+}
+
+func TestOKEXBooks(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "okex", "books.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []json.RawMessage{}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		t.Fatal(err)
+	}
+	f, requests := newTestFeed(t)
+	book := f.watchBooks("BTC-USDT")
+	if r := nextRequest(t, requests); r != `{"args":[{"channel":"books","instId":"BTC-USDT"}],"op":"subscribe"}` {
+		t.Fatalf("request = %v", r)
+	}
+
+	// 快照和校验和正确的增量更新
+	f.dispatch(messages[0])
+	f.dispatch(messages[1])
+	d, ok := book.snapshot(0)
+	want := Depth{
+		Time: 1700000000100,
+		Bids: []OrderBook{{Price: 30000, Amount: 1}, {Price: 29999, Amount: 2}},
+		Asks: []OrderBook{{Price: 30001, Amount: 0.3}, {Price: 30001.5, Amount: 0.8}, {Price: 30002, Amount: 1.2}},
+	}
+	if !ok || d.Time != want.Time || len(d.Bids) != 2 || d.Bids[0] != want.Bids[0] || len(d.Asks) != 3 || d.Asks[0] != want.Asks[0] {
+		t.Fatalf("snapshot() = %+v, %v, want %+v", d, ok, want)
+	}
+
+	// 校验和错误时重新订阅
+	f.dispatch(messages[2])
+	if book.synced() {
+		t.Error("the book should be out of sync after a checksum error")
+	}
+	if r := nextRequest(t, requests); !strings.Contains(r, `"op":"unsubscribe"`) {
+		t.Errorf("request = %v, want unsubscribe", r)
+	}
+	if r := nextRequest(t, requests); !strings.Contains(r, `"op":"subscribe"`) {
+		t.Errorf("request = %v, want subscribe", r)
+	}
+
+	// 新的快照恢复同步, 序号不连续时再次重新订阅
+	f.dispatch(messages[3])
+	if !book.synced() || book.lastSeqID() != 20 {
+		t.Fatalf("the book should be synced by the new snapshot, seqId = %v", book.lastSeqID())
+	}
+	f.dispatch(messages[4])
+	if book.synced() {
+		t.Error("the book should be out of sync after a sequence gap")
+	}
+	if r := nextRequest(t, requests); !strings.Contains(r, `"op":"unsubscribe"`) {
+		t.Errorf("request = %v, want unsubscribe", r)
+	}
+	if d, ok := book.snapshot(0); ok || d.Time != 0 {
+		t.Errorf("snapshot() of a book out of sync = %+v, %v", d, ok)
+	}
+}
@@ -0,0 +1,486 @@
+package okexmock
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// order states
+const (
+	StateLive            = "live"
+	StatePartiallyFilled = "partially_filled"
+	StateFilled          = "filled"
+	StateCanceled        = "canceled"
+)
+
+// DefaultLever the leverage of the swap positions
+const DefaultLever = 10.0
+
+// Order an order in the matching engine
+type Order struct {
+	OrdID     string
+	ClOrdID   string
+	Key       string //下单账户的 API Key, 为空表示做市商的挂单
+	InstID    string
+	Side      string //buy, sell
+	PosSide   string //long, short, net
+	TdMode    string
+	OrdType   string //limit, market, post_only, ioc, fok
+	TgtCcy    string //现货市价单 Sz 的单位, base_ccy 或 quote_ccy
+	Px        float64
+	Sz        float64
+	AccFillSz float64
+	AvgPx     float64
+	Fee       float64 //负数表示支出, 和 OKEX 一致
+	FeeCcy    string
+	Pnl       float64
+	State     string
+	CTime     int64
+	UTime     int64
+
+	frozen float64 //冻结的资金, 现货买单为计价货币, 卖单为基础货币, 合约为保证金
+	spent  float64 //成交金额, 以计价货币为单位
+	seq    int64
+}
+
+// Fill a fill of an order
+type Fill struct {
+	InstID   string
+	TradeID  string
+	OrdID    string
+	Side     string
+	PosSide  string
+	FillPx   float64
+	FillSz   float64
+	ExecType string //T: taker, M: maker
+	Fee      float64
+	FeeCcy   string
+	Ts       int64
+}
+
+// Trade an execution on the public trade tape
+type Trade struct {
+	InstID  string
+	TradeID string
+	Px      float64
+	Sz      float64
+	Side    string //主动成交方向
+	Ts      int64
+}
+
+// Candle a candlestick, sorted by time descending like OKEX
+type Candle struct {
+	Ts     int64
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Balance the balance of a currency
+type Balance struct {
+	Avail  float64
+	Frozen float64
+}
+
+// Position a swap position
+type Position struct {
+	InstID  string
+	PosSide string
+	Pos     float64
+	AvgPx   float64
+	Margin  float64
+	Lever   float64
+}
+
+// Account a trading account identified by the API Key
+type Account struct {
+	Key        string
+	Secret     string
+	Passphrase string
+	Balances   map[string]*Balance
+	Positions  map[string]*Position //key: instId:posSide
+	Fills      []Fill
+}
+
+type book struct {
+	bids []*Order //价格从高到低, 时间从早到晚
+	asks []*Order //价格从低到高, 时间从早到晚
+}
+
+// instrument the currencies of an instId
+type instrument struct {
+	base   string
+	quote  string
+	settle string
+	swap   bool
+}
+
+func parseInstID(instID string) (inst instrument, err error) {
+	parts := strings.Split(instID, "-")
+	switch {
+	case len(parts) == 2:
+		return instrument{base: parts[0], quote: parts[1]}, nil
+	case len(parts) == 3 && parts[2] == "SWAP" && parts[1] != "USD":
+		return instrument{base: parts[0], quote: parts[1], settle: parts[1], swap: true}, nil
+	}
+	return inst, fmt.Errorf("instrument %v is not supported by the mock", instID)
+}
+
+func (a *Account) balance(ccy string) *Balance {
+	b, ok := a.Balances[ccy]
+	if !ok {
+		b = &Balance{}
+		a.Balances[ccy] = b
+	}
+	return b
+}
+
+func (a *Account) position(instID, posSide string) *Position {
+	key := instID + ":" + posSide
+	p, ok := a.Positions[key]
+	if !ok {
+		p = &Position{InstID: instID, PosSide: posSide, Lever: DefaultLever}
+		a.Positions[key] = p
+	}
+	return p
+}
+
+// opening get whether a swap order opens a position
+func (o *Order) opening() bool {
+	switch o.PosSide {
+	case "long":
+		return o.Side == "buy"
+	case "short":
+		return o.Side == "sell"
+	}
+	return true
+}
+
+// remaining the unfilled size, it is in the quote currency if the order is sized by the quote currency
+func (o *Order) remaining() float64 {
+	if o.TgtCcy == "quote_ccy" {
+		// 按金额下单的市价单, 忽略浮点数误差
+		if left := o.Sz - o.spent; left > 1e-9 {
+			return left
+		}
+		return 0
+	}
+	return o.Sz - o.AccFillSz
+}
+
+func (o *Order) active() bool {
+	return o.State == StateLive || o.State == StatePartiallyFilled
+}
+
+// rejectError an order rejected by the engine
+type rejectError struct {
+	code string
+	msg  string
+}
+
+func (e rejectError) Error() string {
+	return e.code + ": " + e.msg
+}
+
+// freeze reserve the funds of a new order
+func (s *Server) freeze(a *Account, o *Order, inst instrument) error {
+	px := o.Px
+	if o.OrdType == "market" {
+		px = s.marketPrice(o.InstID, o.Side)
+		if px <= 0 {
+			return rejectError{"51400", "No liquidity for the market order"}
+		}
+	}
+	if inst.swap {
+		if o.opening() {
+			margin := px * o.Sz / DefaultLever
+			b := a.balance(inst.settle)
+			if b.Avail < margin {
+				return rejectError{"51008", fmt.Sprintf("Order failed. Insufficient %v margin in account.", inst.settle)}
+			}
+			b.Avail -= margin
+			b.Frozen += margin
+			o.frozen = margin
+			return nil
+		}
+		closing := 0.0
+		for _, other := range s.orders {
+			if other.Key == a.Key && other.InstID == o.InstID && other.PosSide == o.PosSide && other.active() && !other.opening() {
+				closing += other.remaining()
+			}
+		}
+		if a.position(o.InstID, o.PosSide).Pos-closing < o.Sz {
+			return rejectError{"51023", "Position does not exist or the size is not enough"}
+		}
+		return nil
+	}
+	if o.Side == "buy" {
+		cost := px * o.Sz
+		if o.TgtCcy == "quote_ccy" {
+			cost = o.Sz
+		}
+		b := a.balance(inst.quote)
+		if b.Avail < cost {
+			return rejectError{"51008", fmt.Sprintf("Order failed. Insufficient %v balance in account.", inst.quote)}
+		}
+		b.Avail -= cost
+		b.Frozen += cost
+		o.frozen = cost
+		return nil
+	}
+	b := a.balance(inst.base)
+	if b.Avail < o.Sz {
+		return rejectError{"51008", fmt.Sprintf("Order failed. Insufficient %v balance in account.", inst.base)}
+	}
+	b.Avail -= o.Sz
+	b.Frozen += o.Sz
+	o.frozen = o.Sz
+	return nil
+}
+
+// unfreeze release the funds which are still reserved by an order
+func (s *Server) unfreeze(o *Order) {
+	a, ok := s.accounts[o.Key]
+	if !ok || o.frozen <= 0 {
+		return
+	}
+	inst, _ := parseInstID(o.InstID)
+	ccy := inst.quote
+	if inst.swap {
+		ccy = inst.settle
+	} else if o.Side == "sell" {
+		ccy = inst.base
+	}
+	b := a.balance(ccy)
+	b.Frozen -= o.frozen
+	b.Avail += o.frozen
+	o.frozen = 0
+}
+
+// marketPrice the best price a market order takes
+func (s *Server) marketPrice(instID, side string) float64 {
+	bk := s.books[instID]
+	if bk == nil {
+		return 0
+	}
+	if side == "buy" && len(bk.asks) > 0 {
+		return bk.asks[0].Px
+	}
+	if side == "sell" && len(bk.bids) > 0 {
+		return bk.bids[0].Px
+	}
+	return 0
+}
+
+// match take the liquidity of the opposite side, then rest the remaining of a limit order
+func (s *Server) match(o *Order) error {
+	bk := s.books[o.InstID]
+	if bk == nil {
+		bk = &book{}
+		s.books[o.InstID] = bk
+	}
+	opposite := &bk.asks
+	crosses := func(px float64) bool { return o.OrdType == "market" || px <= o.Px }
+	if o.Side == "sell" {
+		opposite = &bk.bids
+		crosses = func(px float64) bool { return o.OrdType == "market" || px >= o.Px }
+	}
+	if o.OrdType == "post_only" && len(*opposite) > 0 && crosses((*opposite)[0].Px) {
+		return rejectError{"51019", "Order failed. The post_only order would be filled immediately"}
+	}
+	if o.OrdType == "fok" {
+		available := 0.0
+		for _, maker := range *opposite {
+			if crosses(maker.Px) {
+				available += maker.remaining()
+			}
+		}
+		if available < o.Sz {
+			return rejectError{"51020", "Order failed. The fok order can not be filled completely"}
+		}
+	}
+	for len(*opposite) > 0 && o.remaining() > 0 {
+		maker := (*opposite)[0]
+		if !crosses(maker.Px) {
+			break
+		}
+		sz := math.Min(o.remaining(), maker.remaining())
+		if o.TgtCcy == "quote_ccy" {
+			sz = math.Min(o.remaining()/maker.Px, maker.remaining())
+		}
+		s.fill(o, maker, maker.Px, sz)
+		if maker.remaining() <= 0 {
+			*opposite = (*opposite)[1:]
+		}
+	}
+	switch {
+	case o.remaining() <= 0:
+		o.State = StateFilled
+	case o.OrdType == "limit" || o.OrdType == "post_only":
+		s.rest(bk, o)
+		return nil
+	default:
+		// 市价单, ioc 和 fok 订单不挂单, 剩余的部分撤销
+		o.State = StateCanceled
+	}
+	s.unfreeze(o)
+	return nil
+}
+
+func (s *Server) rest(bk *book, o *Order) {
+	if o.Side == "buy" {
+		i := sort.Search(len(bk.bids), func(i int) bool { return bk.bids[i].Px < o.Px })
+		bk.bids = append(bk.bids, nil)
+		copy(bk.bids[i+1:], bk.bids[i:])
+		bk.bids[i] = o
+		return
+	}
+	i := sort.Search(len(bk.asks), func(i int) bool { return bk.asks[i].Px > o.Px })
+	bk.asks = append(bk.asks, nil)
+	copy(bk.asks[i+1:], bk.asks[i:])
+	bk.asks[i] = o
+}
+
+// remove take an order off the book
+func (s *Server) remove(o *Order) {
+	bk := s.books[o.InstID]
+	if bk == nil {
+		return
+	}
+	for _, side := range []*[]*Order{&bk.bids, &bk.asks} {
+		for i, other := range *side {
+			if other == o {
+				*side = append((*side)[:i], (*side)[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// fill execute sz at px between a taker and a maker
+func (s *Server) fill(taker, maker *Order, px, sz float64) {
+	now := s.now()
+	s.seq++
+	tradeID := fmt.Sprint(s.seq)
+	for _, o := range []*Order{taker, maker} {
+		execType := "T"
+		rate := s.TakerRate
+		if o == maker {
+			execType = "M"
+			rate = s.MakerRate
+		}
+		o.AvgPx = (o.AvgPx*o.AccFillSz + px*sz) / (o.AccFillSz + sz)
+		o.AccFillSz += sz
+		o.spent += px * sz
+		o.State = StatePartiallyFilled
+		if o.remaining() <= 0 {
+			o.State = StateFilled
+		}
+		o.UTime = now
+		a, ok := s.accounts[o.Key]
+		if !ok {
+			continue
+		}
+		fee := px * sz * rate
+		inst, _ := parseInstID(o.InstID)
+		if inst.swap {
+			s.settleSwap(a, o, inst, px, sz)
+		} else {
+			s.settleSpot(a, o, inst, px, sz)
+		}
+		feeCcy := inst.quote
+		a.balance(feeCcy).Avail -= fee
+		o.Fee -= fee
+		o.FeeCcy = feeCcy
+		a.Fills = append(a.Fills, Fill{
+			InstID:   o.InstID,
+			TradeID:  tradeID,
+			OrdID:    o.OrdID,
+			Side:     o.Side,
+			PosSide:  o.PosSide,
+			FillPx:   px,
+			FillSz:   sz,
+			ExecType: execType,
+			Fee:      -fee,
+			FeeCcy:   feeCcy,
+			Ts:       now,
+		})
+	}
+	s.trades[taker.InstID] = append(s.trades[taker.InstID], Trade{
+		InstID:  taker.InstID,
+		TradeID: tradeID,
+		Px:      px,
+		Sz:      sz,
+		Side:    taker.Side,
+		Ts:      now,
+	})
+}
+
+func (s *Server) settleSpot(a *Account, o *Order, inst instrument, px, sz float64) {
+	if o.Side == "buy" {
+		// 按委托价冻结, 按成交价扣款, 差价退回
+		reserved := math.Min(o.frozen, o.Px*sz)
+		if o.OrdType == "market" {
+			reserved = math.Min(o.frozen, px*sz)
+		}
+		quote := a.balance(inst.quote)
+		quote.Frozen -= reserved
+		quote.Avail += reserved - px*sz
+		o.frozen -= reserved
+		a.balance(inst.base).Avail += sz
+		return
+	}
+	base := a.balance(inst.base)
+	base.Frozen -= sz
+	o.frozen -= sz
+	a.balance(inst.quote).Avail += px * sz
+}
+
+func (s *Server) settleSwap(a *Account, o *Order, inst instrument, px, sz float64) {
+	posSide := o.PosSide
+	if posSide == "" {
+		posSide = "net"
+	}
+	p := a.position(o.InstID, posSide)
+	b := a.balance(inst.settle)
+	if o.opening() {
+		margin := px * sz / DefaultLever
+		reserved := math.Min(o.frozen, margin)
+		b.Frozen -= reserved
+		b.Avail += reserved - margin
+		o.frozen -= reserved
+		p.AvgPx = (p.AvgPx*p.Pos + px*sz) / (p.Pos + sz)
+		p.Pos += sz
+		p.Margin += margin
+		return
+	}
+	pnl := (px - p.AvgPx) * sz
+	if posSide == "short" {
+		pnl = -pnl
+	}
+	margin := p.Margin * sz / p.Pos
+	p.Pos -= sz
+	p.Margin -= margin
+	if p.Pos <= 0 {
+		p.Pos, p.AvgPx, p.Margin = 0, 0, 0
+	}
+	b.Avail += margin + pnl
+	o.Pnl += pnl
+}
+
+// upl the unrealized profit of a position by the mid price
+func (s *Server) upl(p *Position) float64 {
+	bid, ask := s.marketPrice(p.InstID, "sell"), s.marketPrice(p.InstID, "buy")
+	if bid <= 0 || ask <= 0 || p.Pos <= 0 {
+		return 0
+	}
+	upl := ((bid+ask)/2 - p.AvgPx) * p.Pos
+	if p.PosSide == "short" {
+		upl = -upl
+	}
+	return upl
+}
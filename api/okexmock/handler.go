@@ -0,0 +1,431 @@
+package okexmock
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miaolz123/conver"
+)
+
+// operationError a rejected order whose details are in the data like OKEX
+type operationError struct {
+	data interface{}
+}
+
+func (e operationError) Error() string {
+	return "Operation failed."
+}
+
+func instType(instID string) string {
+	if strings.HasSuffix(instID, "-SWAP") {
+		return "SWAP"
+	}
+	return "SPOT"
+}
+
+func orderData(o *Order) map[string]string {
+	px := format(o.Px)
+	if o.OrdType == "market" {
+		px = ""
+	}
+	avgPx := ""
+	if o.AccFillSz > 0 {
+		avgPx = format(o.AvgPx)
+	}
+	return map[string]string{
+		"instType":  instType(o.InstID),
+		"instId":    o.InstID,
+		"ordId":     o.OrdID,
+		"clOrdId":   o.ClOrdID,
+		"px":        px,
+		"sz":        format(o.Sz),
+		"ordType":   o.OrdType,
+		"tgtCcy":    o.TgtCcy,
+		"side":      o.Side,
+		"posSide":   o.PosSide,
+		"tdMode":    o.TdMode,
+		"accFillSz": format(o.AccFillSz),
+		"avgPx":     avgPx,
+		"state":     o.State,
+		"fee":       format(o.Fee),
+		"feeCcy":    o.FeeCcy,
+		"pnl":       format(o.Pnl),
+		"lever":     format(DefaultLever),
+		"cTime":     strconv.FormatInt(o.CTime, 10),
+		"uTime":     strconv.FormatInt(o.UTime, 10),
+	}
+}
+
+func (s *Server) serverTime(query url.Values) (interface{}, error) {
+	return []map[string]string{{"ts": strconv.FormatInt(s.now(), 10)}}, nil
+}
+
+// marketBooks the aggregated price levels, [px, sz, "0", orders count]
+func (s *Server) marketBooks(query url.Values) (interface{}, error) {
+	instID := query.Get("instId")
+	if _, err := parseInstID(instID); err != nil {
+		return nil, rejectError{"51001", "Instrument ID does not exist"}
+	}
+	size := intParam(query, "sz", 1)
+	levels := func(orders []*Order) [][]string {
+		result := [][]string{}
+		for _, o := range orders {
+			if n := len(result); n > 0 && result[n-1][0] == format(o.Px) {
+				last := result[n-1]
+				last[1] = format(conver.Float64Must(last[1]) + o.remaining())
+				last[3] = strconv.Itoa(conver.IntMust(last[3]) + 1)
+				continue
+			}
+			if len(result) >= size {
+				break
+			}
+			result = append(result, []string{format(o.Px), format(o.remaining()), "0", "1"})
+		}
+		return result
+	}
+	bk := s.books[instID]
+	if bk == nil {
+		bk = &book{}
+	}
+	return []map[string]interface{}{{
+		"asks":  levels(bk.asks),
+		"bids":  levels(bk.bids),
+		"ts":    strconv.FormatInt(s.now(), 10),
+		"seqId": s.seq,
+	}}, nil
+}
+
+// marketCandles the candlesticks before the after(ms) if it is given, sorted by time descending
+func (s *Server) marketCandles(query url.Values) (interface{}, error) {
+	instID := query.Get("instId")
+	if _, err := parseInstID(instID); err != nil {
+		return nil, rejectError{"51001", "Instrument ID does not exist"}
+	}
+	limit := intParam(query, "limit", 100)
+	after, _ := strconv.ParseInt(query.Get("after"), 10, 64)
+	data := [][]string{}
+	for _, c := range s.candles[instID+"@"+query.Get("bar")] {
+		if after > 0 && c.Ts >= after {
+			continue
+		}
+		if len(data) >= limit {
+			break
+		}
+		data = append(data, []string{
+			strconv.FormatInt(c.Ts, 10), format(c.Open), format(c.High), format(c.Low), format(c.Close),
+			format(c.Volume), format(c.Volume * c.Close), format(c.Volume * c.Close), "1",
+		})
+	}
+	return data, nil
+}
+
+func (s *Server) marketTrades(query url.Values) (interface{}, error) {
+	instID := query.Get("instId")
+	if _, err := parseInstID(instID); err != nil {
+		return nil, rejectError{"51001", "Instrument ID does not exist"}
+	}
+	limit := intParam(query, "limit", 100)
+	trades := s.trades[instID]
+	data := []map[string]string{}
+	for i := len(trades) - 1; i >= 0 && len(data) < limit; i-- {
+		t := trades[i]
+		data = append(data, map[string]string{
+			"instId":  t.InstID,
+			"tradeId": t.TradeID,
+			"px":      format(t.Px),
+			"sz":      format(t.Sz),
+			"side":    t.Side,
+			"ts":      strconv.FormatInt(t.Ts, 10),
+		})
+	}
+	return data, nil
+}
+
+// accountBalance the balances, the equity is valued in USD by the mid price of CCY-USDT
+func (s *Server) accountBalance(a *Account, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	filter := map[string]bool{}
+	for _, ccy := range strings.Split(query.Get("ccy"), ",") {
+		if ccy != "" {
+			filter[strings.ToUpper(ccy)] = true
+		}
+	}
+	details := []map[string]string{}
+	totalEq, frozenEq := 0.0, 0.0
+	for ccy, b := range a.Balances {
+		price := 1.0
+		if ccy != "USDT" && ccy != "USDC" && ccy != "USD" {
+			bid, ask := s.marketPrice(ccy+"-USDT", "sell"), s.marketPrice(ccy+"-USDT", "buy")
+			price = (bid + ask) / 2
+		}
+		totalEq += (b.Avail + b.Frozen) * price
+		frozenEq += b.Frozen * price
+		if len(filter) > 0 && !filter[ccy] {
+			continue
+		}
+		details = append(details, map[string]string{
+			"ccy":       ccy,
+			"availBal":  format(b.Avail),
+			"frozenBal": format(b.Frozen),
+			"cashBal":   format(b.Avail + b.Frozen),
+			"eq":        format(b.Avail + b.Frozen),
+		})
+	}
+	return []map[string]interface{}{{
+		"adjEq":       format(totalEq),
+		"details":     details,
+		"imr":         "0",
+		"isoEq":       "0",
+		"mgnRatio":    "0",
+		"mmr":         "0",
+		"notionalUsd": "0",
+		"ordFroz":     format(frozenEq),
+		"totalEq":     format(totalEq),
+		"uTime":       strconv.FormatInt(s.now(), 10),
+	}}, nil
+}
+
+func (s *Server) accountPositions(a *Account, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	data := []map[string]string{}
+	for _, key := range sortedKeys(a.Positions) {
+		p := a.Positions[key]
+		if p.Pos <= 0 {
+			continue
+		}
+		if instID := query.Get("instId"); instID != "" && instID != p.InstID {
+			continue
+		}
+		if t := query.Get("instType"); t != "" && t != instType(p.InstID) {
+			continue
+		}
+		data = append(data, map[string]string{
+			"instType": instType(p.InstID),
+			"instId":   p.InstID,
+			"mgnMode":  "cross",
+			"posId":    p.InstID + ":" + p.PosSide,
+			"posSide":  p.PosSide,
+			"pos":      format(p.Pos),
+			"avgPx":    format(p.AvgPx),
+			"lever":    format(p.Lever),
+			"margin":   format(p.Margin),
+			"upl":      format(s.upl(p)),
+			"uTime":    strconv.FormatInt(s.now(), 10),
+		})
+	}
+	return data, nil
+}
+
+// accountTradeFee the fee rates, negative means a cost like OKEX
+func (s *Server) accountTradeFee(a *Account, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	return []map[string]string{{
+		"instType": query.Get("instType"),
+		"maker":    format(-s.MakerRate),
+		"taker":    format(-s.TakerRate),
+		"makerU":   format(-s.MakerRate),
+		"takerU":   format(-s.TakerRate),
+		"ts":       strconv.FormatInt(s.now(), 10),
+	}}, nil
+}
+
+// tradeOrder place an order, the rejection is reported by sCode & sMsg
+func (s *Server) tradeOrder(a *Account, _ url.Values, body map[string]interface{}) (interface{}, error) {
+	str := func(key string) string { return fmt.Sprint(valueOr(body[key], "")) }
+	reject := func(err error) (interface{}, error) {
+		re, ok := err.(rejectError)
+		if !ok {
+			re = rejectError{"51000", err.Error()}
+		}
+		return nil, operationError{data: []map[string]string{{"ordId": "", "clOrdId": str("clOrdId"), "tag": "", "sCode": re.code, "sMsg": re.msg}}}
+	}
+	instID := str("instId")
+	inst, err := parseInstID(instID)
+	if err != nil {
+		return reject(rejectError{"51001", "Instrument ID does not exist"})
+	}
+	side, ordType := str("side"), str("ordType")
+	if side != "buy" && side != "sell" {
+		return reject(rejectError{"51000", "Parameter side error"})
+	}
+	switch ordType {
+	case "limit", "market", "post_only", "ioc", "fok":
+	default:
+		return reject(rejectError{"51000", "Parameter ordType error"})
+	}
+	sz, err := strconv.ParseFloat(str("sz"), 64)
+	if err != nil || sz <= 0 {
+		return reject(rejectError{"51000", "Parameter sz error"})
+	}
+	px, err := strconv.ParseFloat(str("px"), 64)
+	if ordType != "market" && (err != nil || px <= 0) {
+		return reject(rejectError{"51000", "Parameter px error"})
+	}
+	posSide := ""
+	if inst.swap {
+		posSide = str("posSide")
+		if posSide != "long" && posSide != "short" {
+			posSide = "net"
+		}
+	}
+	tgtCcy := ""
+	if !inst.swap && ordType == "market" {
+		// 现货市价买单默认按计价货币的金额下单, 卖单按基础货币的数量下单
+		tgtCcy = str("tgtCcy")
+		if tgtCcy == "" {
+			tgtCcy = map[string]string{"buy": "quote_ccy", "sell": "base_ccy"}[side]
+		}
+		// 模拟服务器不支持按金额卖出
+		if (tgtCcy != "quote_ccy" && tgtCcy != "base_ccy") || (tgtCcy == "quote_ccy" && side == "sell") {
+			return reject(rejectError{"51000", "Parameter tgtCcy error"})
+		}
+	}
+	o := s.newOrder(a.Key, instID, side, posSide, ordType, px, sz)
+	o.ClOrdID = str("clOrdId")
+	o.TdMode = str("tdMode")
+	o.TgtCcy = tgtCcy
+	if err := s.freeze(a, o, inst); err != nil {
+		delete(s.orders, o.OrdID)
+		return reject(err)
+	}
+	if err := s.match(o); err != nil {
+		s.unfreeze(o)
+		delete(s.orders, o.OrdID)
+		return reject(err)
+	}
+	return []map[string]string{{"ordId": o.OrdID, "clOrdId": o.ClOrdID, "tag": "", "sCode": "0", "sMsg": ""}}, nil
+}
+
+// findOrder find an order of the account by ordId or clOrdId
+func (s *Server) findOrder(a *Account, instID, ordID, clOrdID string) *Order {
+	for _, o := range s.orders {
+		if o.Key != a.Key || o.InstID != instID {
+			continue
+		}
+		if (ordID != "" && o.OrdID == ordID) || (ordID == "" && clOrdID != "" && o.ClOrdID == clOrdID) {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *Server) tradeCancelOrder(a *Account, _ url.Values, body map[string]interface{}) (interface{}, error) {
+	str := func(key string) string { return fmt.Sprint(valueOr(body[key], "")) }
+	o := s.findOrder(a, str("instId"), str("ordId"), str("clOrdId"))
+	if o == nil || !o.active() {
+		return nil, operationError{data: []map[string]string{{"ordId": str("ordId"), "clOrdId": str("clOrdId"), "sCode": "51400", "sMsg": "Order cancellation failed as the order has been filled, canceled or does not exist"}}}
+	}
+	s.remove(o)
+	s.unfreeze(o)
+	o.State = StateCanceled
+	o.UTime = s.now()
+	return []map[string]string{{"ordId": o.OrdID, "clOrdId": o.ClOrdID, "sCode": "0", "sMsg": ""}}, nil
+}
+
+func (s *Server) tradeGetOrder(a *Account, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	o := s.findOrder(a, query.Get("instId"), query.Get("ordId"), query.Get("clOrdId"))
+	if o == nil {
+		return nil, rejectError{"51603", "Order does not exist"}
+	}
+	return []map[string]string{orderData(o)}, nil
+}
+
+func (s *Server) listOrders(a *Account, query url.Values, active bool) (interface{}, error) {
+	orders := s.sortedOrders(func(o *Order) bool {
+		return o.Key == a.Key && o.active() == active &&
+			(query.Get("instId") == "" || query.Get("instId") == o.InstID) &&
+			(query.Get("instType") == "" || query.Get("instType") == instType(o.InstID)) &&
+			(query.Get("state") == "" || query.Get("state") == o.State)
+	})
+	limit := intParam(query, "limit", 100)
+	data := []map[string]string{}
+	for i := len(orders) - 1; i >= 0 && len(data) < limit; i-- {
+		data = append(data, orderData(orders[i]))
+	}
+	return data, nil
+}
+
+func (s *Server) tradeOrdersPending(a *Account, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	return s.listOrders(a, query, true)
+}
+
+func (s *Server) tradeOrdersHistory(a *Account, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	if query.Get("instType") == "" {
+		return nil, rejectError{"51000", "Parameter instType error"}
+	}
+	return s.listOrders(a, query, false)
+}
+
+func (s *Server) tradeFills(a *Account, query url.Values, _ map[string]interface{}) (interface{}, error) {
+	limit := intParam(query, "limit", 100)
+	data := []map[string]string{}
+	for i := len(a.Fills) - 1; i >= 0 && len(data) < limit; i-- {
+		f := a.Fills[i]
+		if (query.Get("instId") != "" && query.Get("instId") != f.InstID) ||
+			(query.Get("instType") != "" && query.Get("instType") != instType(f.InstID)) ||
+			(query.Get("ordId") != "" && query.Get("ordId") != f.OrdID) {
+			continue
+		}
+		data = append(data, map[string]string{
+			"instType": instType(f.InstID),
+			"instId":   f.InstID,
+			"tradeId":  f.TradeID,
+			"ordId":    f.OrdID,
+			"billId":   f.TradeID + f.OrdID,
+			"fillPx":   format(f.FillPx),
+			"fillSz":   format(f.FillSz),
+			"side":     f.Side,
+			"posSide":  f.PosSide,
+			"execType": f.ExecType,
+			"fee":      format(f.Fee),
+			"feeCcy":   f.FeeCcy,
+			"ts":       strconv.FormatInt(f.Ts, 10),
+		})
+	}
+	return data, nil
+}
+
+// tradeClosePosition close the whole position by a market order
+func (s *Server) tradeClosePosition(a *Account, _ url.Values, body map[string]interface{}) (interface{}, error) {
+	str := func(key string) string { return fmt.Sprint(valueOr(body[key], "")) }
+	instID, posSide := str("instId"), str("posSide")
+	if posSide == "" {
+		posSide = "net"
+	}
+	p, ok := a.Positions[instID+":"+posSide]
+	if !ok || p.Pos <= 0 {
+		return nil, rejectError{"51023", "Position does not exist"}
+	}
+	side := "sell"
+	if posSide == "short" {
+		side = "buy"
+	}
+	// 先撤销这个持仓的平仓挂单, 再按市价全部平仓
+	for _, o := range s.orders {
+		if o.Key == a.Key && o.InstID == instID && o.PosSide == posSide && o.active() && !o.opening() {
+			s.remove(o)
+			o.State = StateCanceled
+		}
+	}
+	o := s.newOrder(a.Key, instID, side, posSide, "market", 0, p.Pos)
+	o.TdMode = str("mgnMode")
+	if err := s.match(o); err != nil {
+		return nil, err
+	}
+	return []map[string]string{{"instId": instID, "posSide": posSide}}, nil
+}
+
+func valueOr(v interface{}, def interface{}) interface{} {
+	if v == nil {
+		return def
+	}
+	return v
+}
+
+func sortedKeys(positions map[string]*Position) (keys []string) {
+	for k := range positions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
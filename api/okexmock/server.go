@@ -0,0 +1,338 @@
+// Package okexmock a stateful fake of the OKEX v5 REST API with an embedded matching engine,
+// used to test the OKEX exchange and the strategies offline.
+//
+// The mock supports the spot instruments(BTC-USDT) and the USDT-margined swaps(BTC-USDT-SWAP, 1 contract = 1 base currency),
+// the fees are charged in the quote currency.
+package okexmock
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server the fake OKEX v5 REST API, it can be started by Start or mounted as an http.Handler
+type Server struct {
+	URL       string           //Start 之后的服务地址, eg: http://127.0.0.1:12345
+	Now       func() time.Time //服务器时钟, 默认为 time.Now
+	RateLimit int              //每个 API Key 每秒最多的私有请求数, 0 表示不限制
+	MakerRate float64          //挂单费率, 正数为支出
+	TakerRate float64          //吃单费率, 正数为支出
+
+	mu       sync.Mutex
+	server   *httptest.Server
+	accounts map[string]*Account
+	books    map[string]*book
+	orders   map[string]*Order
+	trades   map[string][]Trade
+	candles  map[string][]Candle //key: instId@bar
+	failures map[string][]failure
+	requests map[string][]time.Time
+	seq      int64
+}
+
+// failure an error response injected by Fail
+type failure struct {
+	status int
+	code   string
+	msg    string
+}
+
+// NewServer create a mock server which is not started yet
+func NewServer() *Server {
+	return &Server{
+		Now:       time.Now,
+		MakerRate: 0.0008,
+		TakerRate: 0.001,
+		accounts:  make(map[string]*Account),
+		books:     make(map[string]*book),
+		orders:    make(map[string]*Order),
+		trades:    make(map[string][]Trade),
+		candles:   make(map[string][]Candle),
+		failures:  make(map[string][]failure),
+		requests:  make(map[string][]time.Time),
+	}
+}
+
+// Start start the server on a random local port, returns its url
+func (s *Server) Start() string {
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	return s.URL
+}
+
+// Close shut down the server
+func (s *Server) Close() {
+	if s.server != nil {
+		s.server.Close()
+	}
+}
+
+// AddAccount add a trading account with the initial balances
+func (s *Server) AddAccount(key, secret, passphrase string, balances map[string]float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := &Account{
+		Key:        key,
+		Secret:     secret,
+		Passphrase: passphrase,
+		Balances:   make(map[string]*Balance),
+		Positions:  make(map[string]*Position),
+	}
+	for ccy, avail := range balances {
+		a.Balances[ccy] = &Balance{Avail: avail}
+	}
+	s.accounts[key] = a
+}
+
+// Balance get the balance of a currency of an account
+func (s *Server) Balance(key, ccy string) Balance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.accounts[key]; ok {
+		return *a.balance(ccy)
+	}
+	return Balance{}
+}
+
+// Position get a swap position of an account
+func (s *Server) Position(key, instID, posSide string) Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.accounts[key]; ok {
+		return *a.position(instID, posSide)
+	}
+	return Position{}
+}
+
+// Orders get all the orders of an account, sorted by the creation
+func (s *Server) Orders(key string) (orders []Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.sortedOrders(func(o *Order) bool { return o.Key == key }) {
+		orders = append(orders, *o)
+	}
+	return
+}
+
+// AddLiquidity rest a market maker order which has no account
+func (s *Server) AddLiquidity(instID, side string, px, sz float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.newOrder("", instID, side, "", "limit", px, sz)
+	s.match(o)
+}
+
+// SetCandles set the candlesticks of an instId and a bar, eg: 1m, 1H
+func (s *Server) SetCandles(instID, bar string, candles []Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	candles = append([]Candle{}, candles...)
+	sort.Slice(candles, func(i, j int) bool { return candles[i].Ts > candles[j].Ts })
+	s.candles[instID+"@"+bar] = candles
+}
+
+// Fail make the next request of the path(eg: /api/v5/trade/order) fail,
+// the response is returned with the HTTP status, or a code != "0" when status is 200
+func (s *Server) Fail(path string, status int, code, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], failure{status: status, code: code, msg: msg})
+}
+
+func (s *Server) now() int64 {
+	return s.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func (s *Server) newOrder(key, instID, side, posSide, ordType string, px, sz float64) *Order {
+	s.seq++
+	now := s.now()
+	o := &Order{
+		OrdID:   strconv.FormatInt(100000000+s.seq, 10),
+		Key:     key,
+		InstID:  instID,
+		Side:    side,
+		PosSide: posSide,
+		OrdType: ordType,
+		Px:      px,
+		Sz:      sz,
+		State:   StateLive,
+		CTime:   now,
+		UTime:   now,
+		seq:     s.seq,
+	}
+	s.orders[o.OrdID] = o
+	return o
+}
+
+func (s *Server) sortedOrders(filter func(o *Order) bool) (orders []*Order) {
+	for _, o := range s.orders {
+		if filter(o) {
+			orders = append(orders, o)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].seq < orders[j].seq })
+	return
+}
+
+// ServeHTTP dispatch a request of the v5 API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fs := s.failures[r.URL.Path]; len(fs) > 0 {
+		s.failures[r.URL.Path] = fs[1:]
+		writeError(w, fs[0].status, fs[0].code, fs[0].msg)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v5/")
+	query := r.URL.Query()
+	public := map[string]func(url.Values) (interface{}, error){
+		"public/time":            s.serverTime,
+		"market/books":           s.marketBooks,
+		"market/candles":         s.marketCandles,
+		"market/history-candles": s.marketCandles,
+		"market/trades":          s.marketTrades,
+	}
+	if handler, ok := public[path]; ok && r.Method == "GET" {
+		data, err := handler(query)
+		writeData(w, data, err)
+		return
+	}
+	a, status, code, msg := s.authenticate(r, body)
+	if a == nil {
+		writeError(w, status, code, msg)
+		return
+	}
+	type private func(a *Account, query url.Values, body map[string]interface{}) (interface{}, error)
+	handlers := map[string]map[string]private{
+		"GET": {
+			"account/balance":      s.accountBalance,
+			"account/positions":    s.accountPositions,
+			"account/trade-fee":    s.accountTradeFee,
+			"trade/order":          s.tradeGetOrder,
+			"trade/orders-pending": s.tradeOrdersPending,
+			"trade/orders-history": s.tradeOrdersHistory,
+			"trade/fills":          s.tradeFills,
+		},
+		"POST": {
+			"trade/order":          s.tradeOrder,
+			"trade/cancel-order":   s.tradeCancelOrder,
+			"trade/close-position": s.tradeClosePosition,
+		},
+	}
+	handler, ok := handlers[r.Method][path]
+	if !ok {
+		writeError(w, http.StatusNotFound, "50000", "Not Found")
+		return
+	}
+	params := map[string]interface{}{}
+	if r.Method == "POST" && len(body) > 0 {
+		if err := json.Unmarshal(body, &params); err != nil {
+			writeError(w, http.StatusBadRequest, "50002", "Json data format error")
+			return
+		}
+	}
+	data, err := handler(a, query, params)
+	writeData(w, data, err)
+}
+
+// authenticate check the api key, the passphrase, the timestamp, the signature and the rate limit
+func (s *Server) authenticate(r *http.Request, body []byte) (a *Account, status int, code, msg string) {
+	a, ok := s.accounts[r.Header.Get("OK-ACCESS-KEY")]
+	if !ok {
+		return nil, http.StatusUnauthorized, "50111", "Invalid OK-ACCESS-KEY"
+	}
+	if r.Header.Get("OK-ACCESS-PASSPHRASE") != a.Passphrase {
+		return nil, http.StatusUnauthorized, "50105", "Invalid OK-ACCESS-PASSPHRASE"
+	}
+	timestamp := r.Header.Get("OK-ACCESS-TIMESTAMP")
+	t, err := time.Parse("2006-01-02T15:04:05.999Z07:00", timestamp)
+	if err != nil {
+		return nil, http.StatusUnauthorized, "50112", "Invalid OK-ACCESS-TIMESTAMP"
+	}
+	if d := s.Now().Sub(t); d > 30*time.Second || d < -30*time.Second {
+		return nil, http.StatusUnauthorized, "50102", "Timestamp request expired"
+	}
+	requestPath := r.URL.Path
+	if r.URL.RawQuery != "" {
+		requestPath += "?" + r.URL.RawQuery
+	}
+	signBody := ""
+	if r.Method == "POST" {
+		signBody = string(body)
+	}
+	if r.Header.Get("OK-ACCESS-SIGN") != Sign(timestamp, r.Method, requestPath, signBody, a.Secret) {
+		return nil, http.StatusUnauthorized, "50113", "Invalid Sign"
+	}
+	if s.RateLimit > 0 {
+		now := s.Now()
+		recent := []time.Time{}
+		for _, t := range s.requests[a.Key] {
+			if now.Sub(t) < time.Second {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) >= s.RateLimit {
+			s.requests[a.Key] = recent
+			return nil, http.StatusTooManyRequests, "50011", "Too Many Requests"
+		}
+		s.requests[a.Key] = append(recent, now)
+	}
+	return a, http.StatusOK, "0", ""
+}
+
+// Sign the OKEX v5 signature: base64(hmac_sha256(timestamp + method + requestPath + body, secret))
+func Sign(timestamp, method, requestPath, body, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + method + requestPath + body))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func writeData(w http.ResponseWriter, data interface{}, err error) {
+	if err != nil {
+		code, msg := "50000", err.Error()
+		switch e := err.(type) {
+		case rejectError:
+			code, msg = e.code, e.msg
+		case operationError:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": "1", "msg": e.Error(), "data": e.data})
+			return
+		}
+		writeError(w, http.StatusOK, code, msg)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": "0", "msg": "", "data": data})
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "msg": msg, "data": []interface{}{}})
+}
+
+func format(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func intParam(query url.Values, key string, def int) int {
+	if v, err := strconv.Atoi(query.Get(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
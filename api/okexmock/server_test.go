@@ -0,0 +1,175 @@
+package okexmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testKey        = "key"
+	testSecret     = "secret"
+	testPassphrase = "passphrase"
+)
+
+func newTestServer() *Server {
+	s := NewServer()
+	s.AddAccount(testKey, testSecret, testPassphrase, map[string]float64{"USDT": 100000, "BTC": 1})
+	s.AddLiquidity("BTC-USDT", "sell", 30010, 0.5)
+	s.AddLiquidity("BTC-USDT", "sell", 30020, 1)
+	s.AddLiquidity("BTC-USDT", "buy", 29990, 0.5)
+	return s
+}
+
+// do send a signed request and decode the response
+func do(t *testing.T, s *Server, method, path, body string, header map[string]string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	ts := s.Now().UTC().Format("2006-01-02T15:04:05.999Z07:00")
+	req.Header.Set("OK-ACCESS-KEY", testKey)
+	req.Header.Set("OK-ACCESS-PASSPHRASE", testPassphrase)
+	req.Header.Set("OK-ACCESS-TIMESTAMP", ts)
+	req.Header.Set("OK-ACCESS-SIGN", Sign(ts, method, path, body, testSecret))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	resp := map[string]interface{}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v %v returns an invalid json: %s", method, path, w.Body.String())
+	}
+	return w.Code, resp
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := newTestServer()
+	expired := s.Now().Add(-time.Minute).UTC().Format("2006-01-02T15:04:05.999Z07:00")
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+		code   string
+	}{
+		{name: "valid", status: http.StatusOK, code: "0"},
+		{name: "invalid key", header: map[string]string{"OK-ACCESS-KEY": "other"}, status: http.StatusUnauthorized, code: "50111"},
+		{name: "invalid passphrase", header: map[string]string{"OK-ACCESS-PASSPHRASE": "other"}, status: http.StatusUnauthorized, code: "50105"},
+		{name: "invalid sign", header: map[string]string{"OK-ACCESS-SIGN": "other"}, status: http.StatusUnauthorized, code: "50113"},
+		{name: "expired", header: map[string]string{"OK-ACCESS-TIMESTAMP": expired}, status: http.StatusUnauthorized, code: "50102"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := do(t, s, "GET", "/api/v5/account/balance?ccy=USDT", "", tt.header)
+			if status != tt.status || resp["code"] != tt.code {
+				t.Errorf("status = %v, code = %v, want %v, %v", status, resp["code"], tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestRateLimitAndFailure(t *testing.T) {
+	s := newTestServer()
+	s.RateLimit = 2
+	for i := 0; i < 2; i++ {
+		if status, _ := do(t, s, "GET", "/api/v5/account/balance", "", nil); status != http.StatusOK {
+			t.Fatalf("request %v status = %v, want 200", i, status)
+		}
+	}
+	if status, resp := do(t, s, "GET", "/api/v5/account/balance", "", nil); status != http.StatusTooManyRequests || resp["code"] != "50011" {
+		t.Errorf("status = %v, code = %v, want 429, 50011", status, resp["code"])
+	}
+	s.RateLimit = 0
+	s.Fail("/api/v5/account/balance", http.StatusServiceUnavailable, "50001", "Service temporarily unavailable")
+	if status, resp := do(t, s, "GET", "/api/v5/account/balance", "", nil); status != http.StatusServiceUnavailable || resp["code"] != "50001" {
+		t.Errorf("status = %v, code = %v, want 503, 50001", status, resp["code"])
+	}
+	if status, _ := do(t, s, "GET", "/api/v5/account/balance", "", nil); status != http.StatusOK {
+		t.Errorf("the failure should be used only once, status = %v", status)
+	}
+}
+
+func TestMatching(t *testing.T) {
+	s := newTestServer()
+	// 买入 1 BTC, 吃掉 30010 的 0.5 后剩余 0.5 挂在 30015
+	_, resp := do(t, s, "POST", "/api/v5/trade/order", `{"instId":"BTC-USDT","tdMode":"cash","side":"buy","ordType":"limit","px":"30015","sz":"1"}`, nil)
+	data := resp["data"].([]interface{})[0].(map[string]interface{})
+	if resp["code"] != "0" || data["sCode"] != "0" {
+		t.Fatalf("place order = %v", resp)
+	}
+	ordID := data["ordId"].(string)
+	orders := s.Orders(testKey)
+	if len(orders) != 1 || orders[0].AccFillSz != 0.5 || orders[0].AvgPx != 30010 || orders[0].State != StatePartiallyFilled {
+		t.Fatalf("orders = %+v", orders)
+	}
+	if b := s.Balance(testKey, "BTC"); b.Avail != 1.5 {
+		t.Errorf("BTC = %+v, want 1.5 available", b)
+	}
+	if b := s.Balance(testKey, "USDT"); b.Frozen != 30015*0.5 {
+		t.Errorf("USDT frozen = %v, want %v", b.Frozen, 30015*0.5)
+	}
+	_, resp = do(t, s, "GET", "/api/v5/market/books?instId=BTC-USDT&sz=5", "", nil)
+	books := resp["data"].([]interface{})[0].(map[string]interface{})
+	if bid := books["bids"].([]interface{})[0].([]interface{}); bid[0] != "30015" || bid[1] != "0.5" {
+		t.Errorf("best bid = %v, want [30015 0.5]", bid)
+	}
+
+	// 撤单后解冻剩余资金
+	_, resp = do(t, s, "POST", "/api/v5/trade/cancel-order", `{"instId":"BTC-USDT","ordId":"`+ordID+`"}`, nil)
+	if resp["code"] != "0" {
+		t.Fatalf("cancel order = %v", resp)
+	}
+	want := 100000 - 30010*0.5 - 30010*0.5*s.TakerRate
+	if b := s.Balance(testKey, "USDT"); b.Frozen != 0 || b.Avail != want {
+		t.Errorf("USDT = %+v, want %v available", b, want)
+	}
+	_, resp = do(t, s, "POST", "/api/v5/trade/cancel-order", `{"instId":"BTC-USDT","ordId":"`+ordID+`"}`, nil)
+	if resp["code"] != "1" {
+		t.Errorf("cancel a canceled order = %v, want code 1", resp)
+	}
+
+	// 市价买单按金额成交, 30010 的卖单已经被吃完, 全部在 30020 成交
+	_, resp = do(t, s, "POST", "/api/v5/trade/order", `{"instId":"BTC-USDT","tdMode":"cash","side":"buy","ordType":"market","sz":"30020"}`, nil)
+	if resp["code"] != "0" {
+		t.Fatalf("place a market order = %v", resp)
+	}
+	if o := s.Orders(testKey)[1]; o.TgtCcy != "quote_ccy" || o.State != StateFilled || o.AccFillSz != 1 || o.AvgPx != 30020 {
+		t.Errorf("the market order = %+v, want 1 BTC filled at 30020", o)
+	}
+
+	// 余额不足
+	_, resp = do(t, s, "POST", "/api/v5/trade/order", `{"instId":"BTC-USDT","tdMode":"cash","side":"sell","ordType":"limit","px":"29000","sz":"100"}`, nil)
+	if data := resp["data"].([]interface{})[0].(map[string]interface{}); resp["code"] != "1" || data["sCode"] != "51008" {
+		t.Errorf("place order = %v, want sCode 51008", resp)
+	}
+}
+
+func TestSwapPosition(t *testing.T) {
+	s := newTestServer()
+	s.AddLiquidity("BTC-USDT-SWAP", "sell", 30000, 10)
+	s.AddLiquidity("BTC-USDT-SWAP", "buy", 29900, 10)
+	_, resp := do(t, s, "POST", "/api/v5/trade/order", `{"instId":"BTC-USDT-SWAP","tdMode":"cross","side":"buy","posSide":"long","ordType":"market","sz":"2"}`, nil)
+	if resp["code"] != "0" {
+		t.Fatalf("open long = %v", resp)
+	}
+	if p := s.Position(testKey, "BTC-USDT-SWAP", "long"); p.Pos != 2 || p.AvgPx != 30000 || p.Margin != 6000 {
+		t.Fatalf("position = %+v", p)
+	}
+	_, resp = do(t, s, "POST", "/api/v5/trade/order", `{"instId":"BTC-USDT-SWAP","tdMode":"cross","side":"sell","posSide":"long","ordType":"limit","px":"29900","sz":"3"}`, nil)
+	if data := resp["data"].([]interface{})[0].(map[string]interface{}); data["sCode"] != "51023" {
+		t.Errorf("close more than the position = %v, want sCode 51023", resp)
+	}
+	_, resp = do(t, s, "POST", "/api/v5/trade/close-position", `{"instId":"BTC-USDT-SWAP","mgnMode":"cross","posSide":"long"}`, nil)
+	if resp["code"] != "0" {
+		t.Fatalf("close position = %v", resp)
+	}
+	if p := s.Position(testKey, "BTC-USDT-SWAP", "long"); p.Pos != 0 {
+		t.Errorf("position = %+v, want closed", p)
+	}
+	// 亏损 (30000 - 29900) * 2, 开仓和平仓都是吃单
+	want := 100000 - 200 - 30000*2*s.TakerRate - 29900*2*s.TakerRate
+	if b := s.Balance(testKey, "USDT"); b.Frozen != 0 || b.Avail-want > 1e-9 || want-b.Avail > 1e-9 {
+		t.Errorf("USDT = %+v, want %v available", b, want)
+	}
+}
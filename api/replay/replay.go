@@ -0,0 +1,282 @@
+// Package replay records the HTTP interactions of the exchange adapters into fixture files
+// and serves them back, so that the adapters can be tested offline
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the secret values in the fixtures
+const Redacted = "REDACTED"
+
+// DefaultSecrets the request headers and query parameters which carry the api keys, signatures and nonces
+var DefaultSecrets = []string{
+	"OK-ACCESS-KEY", "OK-ACCESS-SIGN", "OK-ACCESS-PASSPHRASE", "OK-ACCESS-TIMESTAMP", //okex
+	"X-MBX-APIKEY", "signature", "timestamp", //binance
+	"AccessKeyId", "Signature", "Timestamp", //huobi
+	"accesskey", "sign", "reqTime", //zb
+	"Authorization", "Key", "Sign", //big.one, gate.io, poloniex
+}
+
+// Interaction a request and its response
+type Interaction struct {
+	Method        string            `json:"method"`
+	URL           string            `json:"url"`
+	RequestHeader map[string]string `json:"requestHeader,omitempty"`
+	RequestBody   string            `json:"requestBody,omitempty"`
+	Status        int               `json:"status"`
+	ResponseBody  string            `json:"responseBody"`
+}
+
+// Recorder an http.RoundTripper which sends the requests by Transport and records them with the secrets redacted
+type Recorder struct {
+	Transport http.RoundTripper //为空时使用 http.DefaultTransport
+	Secrets   []string          //为空时使用 DefaultSecrets
+
+	mu           sync.Mutex
+	path         string
+	interactions []Interaction
+}
+
+// NewRecorder create a recorder which saves the interactions to the fixture file
+func NewRecorder(path string, transport http.RoundTripper) *Recorder {
+	return &Recorder{Transport: transport, path: path}
+}
+
+// RoundTrip send the request and record it
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	secrets := secretSet(r.Secrets)
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Method:        req.Method,
+		URL:           redactURL(req.URL, secrets),
+		RequestHeader: redactHeader(req.Header, secrets),
+		RequestBody:   string(body),
+		Status:        resp.StatusCode,
+		ResponseBody:  string(respBody),
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Interactions get the recorded interactions
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction{}, r.interactions...)
+}
+
+// Save write the recorded interactions to the fixture file
+func (r *Recorder) Save() error {
+	data, err := json.MarshalIndent(r.Interactions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, 0644)
+}
+
+// Player an http.RoundTripper which serves the recorded interactions back,
+// a request matches an interaction by the method, the url without secrets and the body,
+// the matched interactions are served in order and the last one is repeated once they are used up
+type Player struct {
+	Secrets []string //为空时使用 DefaultSecrets
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+	requests     []Interaction
+}
+
+// Load create a player from the fixture files
+func Load(paths ...string) (*Player, error) {
+	interactions := []Interaction{}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		its := []Interaction{}
+		if err := json.Unmarshal(data, &its); err != nil {
+			return nil, fmt.Errorf("replay: parse %v error: %v", path, err)
+		}
+		interactions = append(interactions, its...)
+	}
+	return NewPlayer(interactions...), nil
+}
+
+// NewPlayer create a player from the interactions
+func NewPlayer(interactions ...Interaction) *Player {
+	return &Player{interactions: interactions, used: make([]bool, len(interactions))}
+}
+
+// RoundTrip serve the matched interaction, an error is returned if there is no match
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	secrets := secretSet(p.Secrets)
+	got := Interaction{
+		Method:        req.Method,
+		URL:           redactURL(req.URL, secrets),
+		RequestHeader: redactHeader(req.Header, secrets),
+		RequestBody:   string(body),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, got)
+	last := -1
+	for i, it := range p.interactions {
+		if !matchInteraction(it, got) {
+			continue
+		}
+		last = i
+		if !p.used[i] {
+			p.used[i] = true
+			return newResponse(req, it), nil
+		}
+	}
+	if last >= 0 {
+		return newResponse(req, p.interactions[last]), nil
+	}
+	return nil, fmt.Errorf("replay: no interaction for %v %v %v", got.Method, got.URL, got.RequestBody)
+}
+
+// Requests get the requests received by the player, with the secrets redacted
+func (p *Player) Requests() []Interaction {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Interaction{}, p.requests...)
+}
+
+// Unused get the interactions which have not been served
+func (p *Player) Unused() (unused []Interaction) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, it := range p.interactions {
+		if !p.used[i] {
+			unused = append(unused, it)
+		}
+	}
+	return
+}
+
+func matchInteraction(it, got Interaction) bool {
+	if !strings.EqualFold(it.Method, got.Method) {
+		return false
+	}
+	u, err := url.Parse(it.URL)
+	if err != nil || redactURL(u, nil) != got.URL {
+		return false
+	}
+	if it.RequestBody == "" || it.RequestBody == got.RequestBody {
+		return true
+	}
+	// JSON 请求体按内容比较, 忽略字段顺序
+	var want, have interface{}
+	if json.Unmarshal([]byte(it.RequestBody), &want) != nil || json.Unmarshal([]byte(got.RequestBody), &have) != nil {
+		return false
+	}
+	return reflect.DeepEqual(want, have)
+}
+
+func newResponse(req *http.Request, it Interaction) *http.Response {
+	status := it.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(strings.NewReader(it.ResponseBody)),
+		ContentLength: int64(len(it.ResponseBody)),
+		Request:       req,
+	}
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func secretSet(secrets []string) map[string]bool {
+	if len(secrets) == 0 {
+		secrets = DefaultSecrets
+	}
+	set := make(map[string]bool)
+	for _, s := range secrets {
+		set[strings.ToLower(s)] = true
+	}
+	return set
+}
+
+// redactURL replace the secret query values and sort the query by key
+func redactURL(u *url.URL, secrets map[string]bool) string {
+	query := u.Query()
+	for k := range query {
+		if secrets[strings.ToLower(k)] {
+			query.Set(k, Redacted)
+		}
+	}
+	r := *u
+	r.RawQuery = query.Encode()
+	return r.String()
+}
+
+func redactHeader(header http.Header, secrets map[string]bool) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	m := make(map[string]string)
+	for k := range header {
+		if secrets[strings.ToLower(k)] {
+			m[k] = Redacted
+		} else {
+			m[k] = header.Get(k)
+		}
+	}
+	return m
+}
@@ -0,0 +1,74 @@
+package replay
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"path":"` + r.URL.Path + `","body":` + string(body) + `}`))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	recorder := NewRecorder(path, nil)
+	client := &http.Client{Transport: recorder}
+	req, _ := http.NewRequest("POST", server.URL+"/order?symbol=BTCUSDT&signature=abc&timestamp=1", strings.NewReader(`{"a":1,"b":2}`))
+	req.Header.Set("X-MBX-APIKEY", "key")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := ioutil.ReadAll(resp.Body)
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	for _, secret := range []string{"abc", `"key"`} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("the fixture contains the secret %v: %s", secret, data)
+		}
+	}
+
+	player, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: player}
+	tests := []struct {
+		name  string
+		url   string
+		body  string
+		match bool
+	}{
+		{name: "other secrets", url: "/order?timestamp=2&symbol=BTCUSDT&signature=def", body: `{"b":2,"a":1}`, match: true},
+		{name: "other query", url: "/order?symbol=ETHUSDT&signature=def&timestamp=2", body: `{"a":1,"b":2}`, match: false},
+		{name: "other body", url: "/order?symbol=BTCUSDT&signature=def&timestamp=2", body: `{"a":2}`, match: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Post(server.URL+tt.url, "application/json", strings.NewReader(tt.body))
+			if !tt.match {
+				if err == nil {
+					t.Errorf("POST %v matches an interaction", tt.url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := ioutil.ReadAll(resp.Body); string(got) != string(want) {
+				t.Errorf("POST %v = %s, want %s", tt.url, got, want)
+			}
+		})
+	}
+	if unused := player.Unused(); len(unused) != 0 {
+		t.Errorf("Unused() = %v, want none", unused)
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+// Endpoint the base urls and the extra request headers of an exchange type
+type Endpoint struct {
+	REST   string            //REST API 地址
+	WS     string            //公共行情 websocket 地址
+	Header map[string]string //私有请求需要额外附带的请求头
+}
+
+var (
+	// endpoints the live endpoint of the exchange types which have an adapter
+	endpoints = map[string]Endpoint{
+		constant.Okex:       {REST: "https://www.okx.com/api/v5/", WS: okexPublicWS},
+		constant.OkexFuture: {REST: "https://www.okx.com/api/v5/", WS: okexPublicWS},
+	}
+	// sandboxEndpoints the sandbox(testnet) endpoint of the exchange types which provide one,
+	// the sandbox needs the api keys created on the sandbox site
+	sandboxEndpoints = map[string]Endpoint{
+		constant.Okex:       {REST: "https://www.okx.com/api/v5/", WS: okexPublicTestWS, Header: map[string]string{"x-simulated-trading": "1"}},
+		constant.OkexFuture: {REST: "https://www.okx.com/api/v5/", WS: okexPublicTestWS, Header: map[string]string{"x-simulated-trading": "1"}},
+	}
+)
+
+// GetEndpoint get the live or sandbox endpoint of an exchange type
+func GetEndpoint(exchangeType string, sandbox bool) (Endpoint, error) {
+	if sandbox {
+		if ep, ok := sandboxEndpoints[exchangeType]; ok {
+			return ep, nil
+		}
+		return Endpoint{}, fmt.Errorf("exchange type %v does not provide a sandbox", exchangeType)
+	}
+	if ep, ok := endpoints[exchangeType]; ok {
+		return ep, nil
+	}
+	return Endpoint{}, fmt.Errorf("unsupported exchange type: %v", exchangeType)
+}
+
+// SandboxTypes get the exchange types which provide a sandbox
+func SandboxTypes() (types []string) {
+	for _, t := range constant.ExchangeTypes {
+		if _, ok := sandboxEndpoints[t]; ok {
+			types = append(types, t)
+		}
+	}
+	return
+}
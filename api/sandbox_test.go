@@ -0,0 +1,23 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+func TestGetEndpoint(t *testing.T) {
+	if ep, err := GetEndpoint(constant.Okex, true); err != nil || ep.Header["x-simulated-trading"] != "1" {
+		t.Errorf("GetEndpoint(okex, sandbox) = %+v, %v", ep, err)
+	}
+	// 没有实现的交易所不提供地址, 避免误以为支持模拟盘
+	for _, sandbox := range []bool{false, true} {
+		if ep, err := GetEndpoint(constant.Binance, sandbox); err == nil {
+			t.Errorf("GetEndpoint(binance, %v) = %+v, want an error", sandbox, ep)
+		}
+	}
+	if types := SandboxTypes(); !reflect.DeepEqual(types, []string{constant.Okex, constant.OkexFuture}) && !reflect.DeepEqual(types, []string{constant.OkexFuture, constant.Okex}) {
+		t.Errorf("SandboxTypes() = %v", types)
+	}
+}
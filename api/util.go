@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha1"
@@ -12,6 +13,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,6 +31,24 @@ type timeout interface {
 	Timeout() bool
 }
 
+// httpError a response whose status is not 200
+type httpError struct {
+	method string
+	url    string
+	status int
+	body   []byte
+}
+
+func newHTTPError(method, url string, resp *http.Response) *httpError {
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	return &httpError{method: method, url: url, status: resp.StatusCode, body: body}
+}
+
+func (err *httpError) Error() string {
+	return fmt.Sprintf("[%s %s] HTTP Status: %d, Info: %s", err.method, err.url, err.status, err.body)
+}
+
 // Position struct
 type Position struct {
 	InstId        string
@@ -52,12 +72,28 @@ type Order struct {
 	Price      float64 //价格
 	Amount     float64 //总量
 	DealAmount float64 //成交量
-	Fee        float64 //这个订单的交易费
+	Fee        float64 //这个订单的交易费, 正数为支出, 负数为返佣
+	FeeCcy     string  //交易费的币种
 	TradeType  string  //交易类型
 	StockType  string  //货币类型
 	Pnl        float64
 }
 
+// FeeRate struct
+type FeeRate struct {
+	Maker float64 //挂单费率, 正数为支出, 负数为返佣
+	Taker float64 //吃单费率, 正数为支出, 负数为返佣
+}
+
+// PublicTrade struct, an execution on the public trade tape
+type PublicTrade struct {
+	ID     string  //成交ID
+	Time   int64   //unix时间戳,毫秒
+	Price  float64 //成交价
+	Amount float64 //成交量
+	Side   string  //主动成交方向, BUY 或 SELL
+}
+
 // Record struct
 type Record struct {
 	Time   int64   //unix时间戳
@@ -129,7 +165,7 @@ func post_gateio(url string, data []string, key string, sign string) (ret []byte
 		ret, _ = ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
 	} else {
-		err = fmt.Errorf("[POST %s] HTTP Status: %d, Info: %v", url, resp.StatusCode, err)
+		err = newHTTPError("POST", url, resp)
 	}
 	return ret, err
 }
@@ -153,19 +189,19 @@ func post(url string, data []string) (ret []byte, err error) {
 		ret, _ = ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
 	} else {
-		err = fmt.Errorf("[POST %s] HTTP Status: %d, Info: %v", url, resp.StatusCode, err)
+		err = newHTTPError("POST", url, resp)
 	}
 	return ret, err
 }
 
-func postWithHeader(url string, header map[string]string, data interface{}) (ret []byte, err error) {
+func postWithHeader(ctx context.Context, url string, header map[string]string, data interface{}) (ret []byte, err error) {
 	j, err := encodingJson.Marshal(data)
 	if err != nil {
 		return nil, err
 	}
 	body := string(j)
 
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer([]byte(body)))
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer([]byte(body)))
 	for k, v := range header {
 		req.Header.Set(k, v)
 	}
@@ -182,15 +218,15 @@ func postWithHeader(url string, header map[string]string, data interface{}) (ret
 		ret, _ = ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
 	} else {
-		err = fmt.Errorf("[POST %s] HTTP Status: %d, Info: %v", url, resp.StatusCode, err)
+		err = newHTTPError("POST", url, resp)
 	}
 	return ret, err
 
 }
 
-func getWithHeader(url string, header map[string]string, data interface{}) (ret []byte, err error) {
+func getWithHeader(ctx context.Context, url string, header map[string]string, data interface{}) (ret []byte, err error) {
 
-	req, _ := http.NewRequest("GET", url, nil)
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	for k, v := range header {
 		req.Header.Set(k, v)
 	}
@@ -207,14 +243,19 @@ func getWithHeader(url string, header map[string]string, data interface{}) (ret
 		ret, _ = ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
 	} else {
-		err = fmt.Errorf("[GET %s] HTTP Status: %d, Info: %v", url, resp.StatusCode, err)
+		err = newHTTPError("GET", url, resp)
 	}
 	return ret, err
 
 }
 
 func get(url string) (ret []byte, err error) {
-	req, err := http.NewRequest("GET", url, strings.NewReader(""))
+	return getContext(context.Background(), url)
+}
+
+// getContext is like get, the request is aborted when the ctx is done
+func getContext(ctx context.Context, url string) (ret []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, strings.NewReader(""))
 	if err != nil {
 		return
 	}
@@ -232,11 +273,18 @@ func get(url string) (ret []byte, err error) {
 		ret, _ = ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
 	} else {
-		err = fmt.Errorf("[GET %s] HTTP Status: %d, Info: %v", url, resp.StatusCode, err)
+		err = newHTTPError("GET", url, resp)
 	}
 	return ret, err
 }
 
+// formatDecimal format a price or an amount in the plain decimal notation and round it to 8 decimals,
+// eg: 0.1+0.2 is formatted as 0.3 and 1e-05 as 0.00001
+func formatDecimal(f float64) string {
+	s := strings.TrimRight(strconv.FormatFloat(f, 'f', 8, 64), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
 func getFloatValueFromJsonObject(json *simplejson.Json, key string) float64 {
 	return conver.Float64Must(json.Get(key).MustString())
 }
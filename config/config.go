@@ -1,32 +1,45 @@
 package config
 
 import (
-	"log"
 	"strings"
 
 	"github.com/go-ini/ini"
 )
 
-var confs = make(map[string]string)
+var (
+	confs   = make(map[string]string)
+	loadErr error //读取配置文件的错误, 由使用者决定是否退出
+)
 
 func init() {
 	conf, err := ini.InsensitiveLoad("custom/config.ini")
 	if err != nil {
 		conf, err = ini.InsensitiveLoad("config.ini")
-		if err != nil {
-			log.Fatalln("Load config.ini error:", err)
-		}
 	}
-	keys := conf.Section("").KeyStrings()
-	for _, k := range keys {
-		confs[k] = conf.Section("").Key(k).String()
+	if err != nil {
+		loadErr = err
+	} else {
+		keys := conf.Section("").KeyStrings()
+		for _, k := range keys {
+			confs[k] = conf.Section("").Key(k).String()
+		}
 	}
 	if confs["logstimezone"] == "" {
 		confs["logstimezone"] = "Local"
 	}
 }
 
+// Err get the error of loading config.ini, nil if it is loaded
+func Err() error {
+	return loadErr
+}
+
 // String ...
 func String(key string) string {
 	return confs[strings.ToLower(key)]
 }
+
+// Set override a config, eg: the tests set dbtype and dburl before model.Open
+func Set(key, value string) {
+	confs[strings.ToLower(key)] = value
+}
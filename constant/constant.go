@@ -20,6 +20,46 @@ const (
 	BigOne     = "big.one"
 )
 
+// trade modes of a trader
+const (
+	ModeLive    = "live"
+	ModeSandbox = "sandbox"
+	ModeMixed   = "mixed"
+)
+
+// lifecycle states of a trader
+const (
+	TraderIdle       = "idle"
+	TraderStarting   = "starting"
+	TraderRunning    = "running"
+	TraderStopping   = "stopping"
+	TraderStopped    = "stopped"
+	TraderCrashed    = "crashed"
+	TraderRestarting = "restarting"
+)
+
+// restart policies of a trader
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// formats of the trader status
+const (
+	StatusText  = "text"
+	StatusJSON  = "json"
+	StatusTable = "table"
+)
+
+// types of the algorithm parameters
+const (
+	ParamNumber  = "number"
+	ParamInteger = "integer"
+	ParamString  = "string"
+	ParamBool    = "bool"
+)
+
 // log types
 const (
 	ERROR      = "ERROR"
@@ -44,8 +84,17 @@ const (
 	TradeTypeShortClose = "SHORT_CLOSE"
 )
 
+// error categories of the exchange api, used to decide whether a failed call can be retried
+const (
+	ErrInvalid   = "INVALID"    //参数错误, 例如无法识别的交易对或数量
+	ErrRejected  = "REJECTED"   //交易所拒绝, 例如余额不足或订单不存在
+	ErrAuth      = "AUTH"       //API Key、签名或权限错误
+	ErrRateLimit = "RATE_LIMIT" //超过交易所的访问频率限制
+	ErrNetwork   = "NETWORK"    //网络错误、超时或交易所暂时不可用
+)
+
 // some variables
 var (
-	Consts        = []string{"M", "M5", "M15", "M30", "H", "D", "W"}
+	Consts        = []string{"M", "M5", "M15", "M30", "H", "D", "W", ErrInvalid, ErrRejected, ErrAuth, ErrRateLimit, ErrNetwork}
 	ExchangeTypes = []string{Zb, Okex, Huobi, Binance, GateIo, Poloniex, OkexFuture, BigOne}
 )
@@ -50,6 +50,14 @@ func (algorithm) Put(req model.Algorithm, ctx rpc.Context) (resp response) {
 		resp.Message = fmt.Sprint(err)
 		return
 	}
+	if _, err := model.ParseParams(req.EvnDefault); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	if err := model.CheckLibrary(req); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
 	algorithm := req
 	if req.ID > 0 {
 		if err := model.DB.First(&algorithm, req.ID).Error; err != nil {
@@ -60,6 +68,9 @@ func (algorithm) Put(req model.Algorithm, ctx rpc.Context) (resp response) {
 		algorithm.Description = req.Description
 		algorithm.Script = req.Script
 		algorithm.EvnDefault = req.EvnDefault
+		algorithm.IsLibrary = req.IsLibrary
+		algorithm.Version = req.Version
+		algorithm.Shared = req.Shared
 		if err := model.DB.Save(&algorithm).Error; err != nil {
 			resp.Message = fmt.Sprint(err)
 			return
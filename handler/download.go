@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/phonegapX/QuantBot/api"
+	"github.com/phonegapX/QuantBot/model"
+	"github.com/phonegapX/QuantBot/trader"
+)
+
+// Download the "download" command, download the history candlesticks into the candles table
+// eg: QuantBot download -type okex -stock BTC/USDT -period M -begin 2024-01-01 -end 2024-02-01
+func Download(args []string) {
+	flags := flag.NewFlagSet("download", flag.ExitOnError)
+	exchangeType := flags.String("type", "okex", "exchange type")
+	stockType := flags.String("stock", "BTC/USDT", "stock type")
+	period := flags.String("period", "M", "period of the candlesticks, one of M, M5, M15, M30, H, H4, D, W")
+	begin := flags.String("begin", time.Now().AddDate(0, 0, -30).Format("2006-01-02"), "begin date, 2006-01-02")
+	end := flags.String("end", time.Now().AddDate(0, 0, 1).Format("2006-01-02"), "end date(exclusive), 2006-01-02")
+	flags.Parse(args)
+	open()
+	beginAt, err := time.ParseInLocation("2006-01-02", *begin, time.Local)
+	if err != nil {
+		log.Fatalln("Invalid begin date:", err)
+	}
+	endAt, err := time.ParseInLocation("2006-01-02", *end, time.Local)
+	if err != nil {
+		log.Fatalln("Invalid end date:", err)
+	}
+	e := model.Exchange{Type: *exchangeType, Name: *exchangeType}
+	if err := download(e, *stockType, *period, beginAt.UnixNano()/1e6, endAt.UnixNano()/1e6, false); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// download download the history candlesticks by the exchange config, in background if async
+func download(e model.Exchange, stockType, period string, begin, end int64, async bool) error {
+	exchange, err := trader.NewExchange(0, e)
+	if err != nil {
+		return err
+	}
+	if begin >= end {
+		return fmt.Errorf("Invalid time range: %v - %v", begin, end)
+	}
+	run := func() error {
+		name := fmt.Sprintf("%v %v %v", e.Type, stockType, period)
+		log.Printf("Download %v candlesticks ...\n", name)
+		saved, err := api.DownloadRecords(exchange, stockType, period, begin, end, func(saved int) {
+			log.Printf("Download %v candlesticks, %v saved\n", name, saved)
+		})
+		if err != nil {
+			log.Printf("Download %v candlesticks error: %v\n", name, err)
+			return err
+		}
+		log.Printf("Download %v candlesticks done, %v saved\n", name, saved)
+		return nil
+	}
+	if async {
+		go run()
+		return nil
+	}
+	return run()
+}
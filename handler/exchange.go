@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/hprose/hprose-golang/rpc"
+	"github.com/phonegapX/QuantBot/api"
 	"github.com/phonegapX/QuantBot/constant"
 	"github.com/phonegapX/QuantBot/model"
 )
@@ -17,6 +18,13 @@ func (exchange) Types(_ string, ctx rpc.Context) (resp response) {
 	return
 }
 
+// SandboxTypes the exchange types which provide a sandbox
+func (exchange) SandboxTypes(_ string, ctx rpc.Context) (resp response) {
+	resp.Data = api.SandboxTypes()
+	resp.Success = true
+	return
+}
+
 // List ...
 func (exchange) List(size, page int64, order string, ctx rpc.Context) (resp response) {
 	username := ctx.GetString("username")
@@ -57,6 +65,17 @@ func (exchange) Put(req model.Exchange, ctx rpc.Context) (resp response) {
 		resp.Message = fmt.Sprint(err)
 		return
 	}
+	if req.IsSandbox() {
+		if _, err := api.GetEndpoint(req.Type, true); err != nil {
+			resp.Message = fmt.Sprint(err)
+			return
+		}
+	}
+	// 交易所的 API Key 会发送到自定义的地址, 只能使用配置中允许的地址
+	if err := req.CheckHost(); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
 	exchange := req
 	if req.ID > 0 {
 		if err := model.DB.First(&exchange, req.ID).Error; err != nil {
@@ -69,6 +88,7 @@ func (exchange) Put(req model.Exchange, ctx rpc.Context) (resp response) {
 		exchange.SecretKey = req.SecretKey
 		exchange.Passphrase = req.Passphrase
 		exchange.Test = req.Test
+		exchange.Host = req.Host
 		if err := model.DB.Save(&exchange).Error; err != nil {
 			resp.Message = fmt.Sprint(err)
 			return
@@ -113,3 +133,42 @@ func (exchange) Delete(ids []int64, ctx rpc.Context) (resp response) {
 	}
 	return
 }
+
+// Download download the history candlesticks of [begin, end)(unix ms) in background
+func (exchange) Download(id int64, stockType, period string, begin, end int64, ctx rpc.Context) (resp response) {
+	username := ctx.GetString("username")
+	if username == "" {
+		resp.Message = constant.ErrAuthorizationError
+		return
+	}
+	self, err := model.GetUser(username)
+	if err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	_, users, err := self.ListUser(-1, 1, "id")
+	if err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	e := model.Exchange{}
+	if err := model.DB.First(&e, id).Error; err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	resp.Message = constant.ErrInsufficientPermissions
+	for _, u := range users {
+		if u.ID == e.UserID {
+			resp.Message = ""
+		}
+	}
+	if resp.Message != "" {
+		return
+	}
+	if err := download(e, stockType, period, begin, end, true); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	resp.Success = true
+	return
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/hprose/hprose-golang/rpc"
 	"github.com/phonegapX/QuantBot/config"
 	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/model"
+	"github.com/phonegapX/QuantBot/trader"
 )
 
 type response struct {
@@ -25,8 +27,17 @@ func (e event) OnSendHeader(ctx *rpc.HTTPContext) {
 	ctx.Response.Header().Set("Access-Control-Allow-Headers", "Authorization")
 }
 
+// open check the config and connect to the database
+func open() {
+	if err := config.Err(); err != nil {
+		log.Fatalln("Load config.ini error:", err)
+	}
+	model.Open()
+}
+
 // Server ...
 func Server() {
+	open()
 	port := config.String("port")
 	service := rpc.NewHTTPService()
 	handler := struct {
@@ -59,6 +70,9 @@ func Server() {
 		return
 	})
 	service.AddAllMethods(handler)
+	if strings.ToLower(config.String("autoResume")) != "false" {
+		trader.Resume()
+	}
 	http.Handle("/api", service)
 	http.Handle("/", http.FileServer(http.Dir("web/dist")))
 	fmt.Printf("%v  Version %v\n", constant.Banner, constant.Version)
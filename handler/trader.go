@@ -29,7 +29,10 @@ func (runner) List(algorithmID int64, ctx rpc.Context) (resp response) {
 		return
 	}
 	for i, t := range traders {
+		traders[i].State = trader.GetTraderState(t.ID)
 		traders[i].Status = trader.GetTraderStatus(t.ID)
+		traders[i].Clocks = trader.GetTraderClocks(t.ID)
+		traders[i].Mode = model.TradeMode(t.Exchanges)
 	}
 	resp.Data = traders
 	resp.Success = true
@@ -63,7 +66,18 @@ func (runner) Put(req model.Trader, ctx rpc.Context) (resp response) {
 		resp.Success = true
 		return
 	}
+	if err := model.CheckEnvironment(req); err != nil {
+		db.Rollback()
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	if err := model.CheckRestartPolicy(req.RestartPolicy); err != nil {
+		db.Rollback()
+		resp.Message = fmt.Sprint(err)
+		return
+	}
 	req.UserID = self.ID
+	req.Running = false
 	if err := db.Create(&req).Error; err != nil {
 		db.Rollback()
 		resp.Message = fmt.Sprint(err)
@@ -136,3 +150,98 @@ func (runner) Switch(req model.Trader, ctx rpc.Context) (resp response) {
 	resp.Success = true
 	return
 }
+
+// Status
+func (runner) Status(id int64, ctx rpc.Context) (resp response) {
+	username := ctx.GetString("username")
+	if username == "" {
+		resp.Message = constant.ErrAuthorizationError
+		return
+	}
+	self, err := model.GetUser(username)
+	if err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	if _, err := self.GetTrader(id); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	resp.Data = trader.GetTraderRuntime(id)
+	resp.Success = true
+	return
+}
+
+// ListStore
+func (runner) ListStore(id int64, ctx rpc.Context) (resp response) {
+	username := ctx.GetString("username")
+	if username == "" {
+		resp.Message = constant.ErrAuthorizationError
+		return
+	}
+	self, err := model.GetUser(username)
+	if err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	if _, err := self.GetTrader(id); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	stores, err := model.ListStore(id)
+	if err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	resp.Data = stores
+	resp.Success = true
+	return
+}
+
+// PutStore
+func (runner) PutStore(req model.Store, ctx rpc.Context) (resp response) {
+	username := ctx.GetString("username")
+	if username == "" {
+		resp.Message = constant.ErrAuthorizationError
+		return
+	}
+	self, err := model.GetUser(username)
+	if err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	if _, err := self.GetTrader(req.TraderID); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	if err := model.SetStore(req.TraderID, req.Key, req.Value); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	resp.Success = true
+	return
+}
+
+// DeleteStore
+func (runner) DeleteStore(req model.Store, ctx rpc.Context) (resp response) {
+	username := ctx.GetString("username")
+	if username == "" {
+		resp.Message = constant.ErrAuthorizationError
+		return
+	}
+	self, err := model.GetUser(username)
+	if err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	if _, err := self.GetTrader(req.TraderID); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	if _, err := model.DeleteStore(req.TraderID, req.Key); err != nil {
+		resp.Message = fmt.Sprint(err)
+		return
+	}
+	resp.Success = true
+	return
+}
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,6 +15,9 @@ type Algorithm struct {
 	Description string     `gorm:"type:text" json:"description"`
 	Script      string     `gorm:"type:text" json:"script"`
 	EvnDefault  string     `gorm:"type:text" json:"evnDefault"`
+	IsLibrary   bool       `gorm:"index" json:"isLibrary"`          //是否是可以被 require() 的库
+	Version     string     `gorm:"type:varchar(50)" json:"version"` //库的版本, eg: 1.2.0
+	Shared      bool       `json:"shared"`                          //库是否对所有用户可见
 	CreatedAt   time.Time  `json:"createdAt"`
 	UpdatedAt   time.Time  `json:"updatedAt"`
 	DeletedAt   *time.Time `sql:"index" json:"-"`
@@ -39,3 +45,81 @@ func (user User) ListAlgorithm(size, page int64, order string) (total int64, alg
 	err = DB.Where("user_id in (?)", userIDs).Order(toUnderScoreCase(order)).Limit(size).Offset((page - 1) * size).Find(&algorithms).Error
 	return
 }
+
+// CheckLibrary check the name of a library, it is used as the module name in require()
+func CheckLibrary(algorithm Algorithm) error {
+	if !algorithm.IsLibrary {
+		return nil
+	}
+	if algorithm.Name == "" || strings.ContainsAny(algorithm.Name, "@ \t\n") {
+		return fmt.Errorf("Invalid library name: %q", algorithm.Name)
+	}
+	if strings.ContainsAny(algorithm.Version, "@ \t\n") {
+		return fmt.Errorf("Invalid library version: %q", algorithm.Version)
+	}
+	return nil
+}
+
+// FindLibrary find a library of the user, the shared libraries of the other users are used only if the user has no library
+// of the name, so that a shared library can not replace the library of the user, the latest version is used if version is empty
+func FindLibrary(userID int64, name, version string) (library Algorithm, err error) {
+	count := 0
+	if err = DB.Model(&Algorithm{}).Where("is_library = ? AND name = ? AND user_id = ?", true, name, userID).Count(&count).Error; err != nil {
+		return
+	}
+	libraries := []Algorithm{}
+	db := DB.Where("is_library = ? AND name = ?", true, name)
+	if count > 0 {
+		db = db.Where("user_id = ?", userID)
+	} else {
+		db = db.Where("shared = ?", true)
+	}
+	if version != "" {
+		db = db.Where("version = ?", version)
+	}
+	if err = db.Order("id").Find(&libraries).Error; err != nil {
+		return
+	}
+	if len(libraries) == 0 {
+		if version != "" {
+			name += "@" + version
+		}
+		return library, fmt.Errorf("Can not find the library: %v", name)
+	}
+	library = libraries[0]
+	for _, l := range libraries[1:] {
+		if compareVersion(l.Version, library.Version) > 0 {
+			library = l
+		}
+	}
+	return
+}
+
+// compareVersion compare two versions like 1.10.0 and 1.9.2, the numeric parts are compared as numbers
+func compareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := "0", "0" //缺少的部分视为 0
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		m, errm := strconv.ParseInt(x, 10, 64)
+		n, errn := strconv.ParseInt(y, 10, 64)
+		switch {
+		case errm == nil && errn == nil && m != n:
+			if m > n {
+				return 1
+			}
+			return -1
+		case (errm != nil || errn != nil) && x != y:
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package model
+
+import "testing"
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.10.0", "1.9.2", 1},
+		{"1.2", "1.2.0", 0},
+		{"1.2.rc2", "1.2.rc1", 1},
+		{"", "0.1", -1},
+		{"2.0.0", "10.0.0", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersion(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersion(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFindLibrary(t *testing.T) {
+	for _, l := range []Algorithm{
+		{UserID: 10, Name: "utilsx", Version: "1.0.0"},
+		{UserID: 10, Name: "utilsx", Version: "1.2.0"},
+		{UserID: 11, Name: "utilsx", Version: "99.0", Shared: true},
+		{UserID: 11, Name: "utilsx", Version: "100.0"},
+	} {
+		l.IsLibrary = true
+		if err := DB.Create(&l).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		userID  int64
+		version string
+		want    string
+		err     bool
+	}{
+		// 自己的库优先, 其他用户共享的库不能替换它
+		{userID: 10, want: "1.2.0"},
+		{userID: 10, version: "1.0.0", want: "1.0.0"},
+		{userID: 10, version: "99.0", err: true},
+		// 自己没有同名的库时才使用共享的库
+		{userID: 12, want: "99.0"},
+		{userID: 12, version: "100.0", err: true},
+		{userID: 11, want: "100.0"},
+	}
+	for _, tt := range tests {
+		library, err := FindLibrary(tt.userID, "utilsx", tt.version)
+		if (err != nil) != tt.err || !tt.err && library.Version != tt.want {
+			t.Errorf("FindLibrary(%v, utilsx, %q) = %v, %v, want %v", tt.userID, tt.version, library.Version, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package model
+
+// Candle struct
+type Candle struct {
+	ID           int64   `gorm:"primary_key;AUTO_INCREMENT" json:"-"`
+	ExchangeType string  `gorm:"type:varchar(50);unique_index:idx_candle" json:"exchangeType"`
+	StockType    string  `gorm:"type:varchar(50);unique_index:idx_candle" json:"stockType"`
+	Period       string  `gorm:"type:varchar(10);unique_index:idx_candle" json:"period"`
+	Time         int64   `gorm:"unique_index:idx_candle" json:"time"` //unix时间戳,毫秒
+	Open         float64 `json:"open"`
+	High         float64 `json:"high"`
+	Low          float64 `json:"low"`
+	Close        float64 `json:"close"`
+	Volume       float64 `json:"volume"`
+}
+
+// SaveCandles save candlesticks of the same exchangeType, stockType & period, the existing ones are replaced
+func SaveCandles(exchangeType, stockType, period string, candles []Candle) (err error) {
+	if len(candles) == 0 {
+		return
+	}
+	times := []int64{}
+	for _, c := range candles {
+		times = append(times, c.Time)
+	}
+	db := DB.Begin()
+	if err = db.Where("exchange_type = ? AND stock_type = ? AND period = ? AND time in (?)", exchangeType, stockType, period, times).Delete(&Candle{}).Error; err != nil {
+		db.Rollback()
+		return
+	}
+	for _, c := range candles {
+		c.ID = 0
+		c.ExchangeType = exchangeType
+		c.StockType = stockType
+		c.Period = period
+		if err = db.Create(&c).Error; err != nil {
+			db.Rollback()
+			return
+		}
+	}
+	return db.Commit().Error
+}
+
+// ListCandles list the saved candlesticks in [begin, end) ordered by time
+func ListCandles(exchangeType, stockType, period string, begin, end int64) (candles []Candle, err error) {
+	err = DB.Where("exchange_type = ? AND stock_type = ? AND period = ? AND time >= ? AND time < ?", exchangeType, stockType, period, begin, end).Order("time").Find(&candles).Error
+	return
+}
+
+// CandleRange get the earliest & latest time of the saved candlesticks in [begin, end), count is 0 if nothing saved
+func CandleRange(exchangeType, stockType, period string, begin, end int64) (earliest, latest, count int64, err error) {
+	row := struct {
+		Earliest int64
+		Latest   int64
+		Count    int64
+	}{}
+	err = DB.Model(&Candle{}).Select("COALESCE(MIN(time), 0) AS earliest, COALESCE(MAX(time), 0) AS latest, COUNT(*) AS count").
+		Where("exchange_type = ? AND stock_type = ? AND period = ? AND time >= ? AND time < ?", exchangeType, stockType, period, begin, end).
+		Scan(&row).Error
+	return row.Earliest, row.Latest, row.Count, err
+}
@@ -1,7 +1,11 @@
 package model
 
 import (
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/phonegapX/QuantBot/config"
 )
 
 // Exchange struct
@@ -16,7 +20,26 @@ type Exchange struct {
 	CreatedAt  time.Time  `json:"createdAt"`
 	UpdatedAt  time.Time  `json:"updatedAt"`
 	DeletedAt  *time.Time `sql:"index" json:"-"`
-	Test       string
+	Test       string     //"1" 表示模拟盘(sandbox)
+	Host       string     `gorm:"type:varchar(200)" json:"host"` //自定义的 REST API 地址, 为空时使用交易所的默认地址, 必须在配置的 allowHosts 中
+}
+
+// CheckHost check whether the custom host is in allowHosts of the config, the api keys are sent to the host
+func (e Exchange) CheckHost() error {
+	if e.Host == "" {
+		return nil
+	}
+	for _, h := range strings.Split(config.String("allowHosts"), ",") {
+		if h = strings.TrimSuffix(strings.TrimSpace(h), "/"); h != "" && h == strings.TrimSuffix(e.Host, "/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("Host %v is not allowed, add it to allowHosts in config.ini", e.Host)
+}
+
+// IsSandbox get whether the exchange connects to the sandbox
+func (e Exchange) IsSandbox() bool {
+	return e.Test == "1"
 }
 
 // ListExchange ...
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/phonegapX/QuantBot/config"
+)
+
+func TestCheckHost(t *testing.T) {
+	allowHosts := config.String("allowHosts")
+	defer config.Set("allowHosts", allowHosts)
+	config.Set("allowHosts", " http://127.0.0.1:8080/ ,http://localhost:9000")
+	tests := []struct {
+		host string
+		err  bool
+	}{
+		{"", false},
+		{"http://127.0.0.1:8080", false},
+		{"http://127.0.0.1:8080/", false},
+		{"http://localhost:9000", false},
+		{"http://127.0.0.1:8081", true},
+		{"https://attacker.example.com", true},
+	}
+	for _, tt := range tests {
+		if err := (Exchange{Host: tt.host}).CheckHost(); (err != nil) != tt.err {
+			t.Errorf("CheckHost(%q) = %v, want error %v", tt.host, err, tt.err)
+		}
+	}
+	config.Set("allowHosts", "")
+	if err := (Exchange{Host: "http://127.0.0.1:8080"}).CheckHost(); err == nil {
+		t.Error("CheckHost() should fail when allowHosts is empty")
+	}
+}
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"os"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/config"
+)
+
+func TestMain(m *testing.M) {
+	// 测试使用内存数据库, 不读写 custom/data.db
+	config.Set("dbtype", "SQLite3")
+	config.Set("dburl", "file::memory:?cache=shared")
+	Open()
+	os.Exit(m.Run())
+}
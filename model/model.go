@@ -17,8 +17,8 @@ import (
 var (
 	// DB Database
 	DB     *gorm.DB
-	dbType = config.String("dbtype")
-	dbURL  = config.String("dburl")
+	dbType string
+	dbURL  string
 )
 
 func init() {
@@ -27,6 +27,13 @@ func init() {
 	io.Register((*Algorithm)(nil), "Algorithm", "json")
 	io.Register((*Trader)(nil), "Trader", "json")
 	io.Register((*Log)(nil), "Log", "json")
+	io.Register((*Store)(nil), "Store", "json")
+}
+
+// Open connect to the database of dbtype and dburl in the config, it must be called once before using DB
+func Open() {
+	dbType = config.String("dbtype")
+	dbURL = config.String("dburl")
 	var err error
 	DB, err = gorm.Open(strings.ToLower(dbType), dbURL)
 	if err != nil {
@@ -38,7 +45,7 @@ func init() {
 			log.Fatalln("Connect to database error:", err)
 		}
 	}
-	DB.AutoMigrate(&User{}, &Exchange{}, &Algorithm{}, &TraderExchange{}, &Trader{}, &Log{})
+	DB.AutoMigrate(&User{}, &Exchange{}, &Algorithm{}, &TraderExchange{}, &Trader{}, &Log{}, &Candle{}, &Store{})
 	users := []User{}
 	DB.Find(&users)
 	if len(users) == 0 {
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+var (
+	paramName = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+	// 运行环境中已经存在的全局变量, 参数不能覆盖它们
+	reservedParams = append([]string{"Global", "G", "Exchange", "E", "Exchanges", "Es", "Talib", "Indicators", "require", "main", "exit"}, constant.Consts...)
+)
+
+// Param a parameter of an algorithm, it is declared as a json array in Algorithm.EvnDefault,
+// eg: [{"name": "Amount", "type": "number", "default": 0.1, "min": 0, "description": "amount of each order"}]
+type Param struct {
+	Name        string      `json:"name"`              //参数名, 即 js 中的全局变量名
+	Type        string      `json:"type"`              //constant.ParamNumber, ParamInteger, ParamString 或 ParamBool
+	Default     interface{} `json:"default"`           //默认值
+	Min         *float64    `json:"min,omitempty"`     //最小值, 只用于数字类型
+	Max         *float64    `json:"max,omitempty"`     //最大值, 只用于数字类型
+	Options     []string    `json:"options,omitempty"` //可选值, 只用于字符串类型
+	Description string      `json:"description"`
+}
+
+// Check check a value of the parameter, the returned value is the one to set in the js runtime
+func (p Param) Check(v interface{}) (interface{}, error) {
+	switch p.Type {
+	case constant.ParamNumber, constant.ParamInteger:
+		f, ok := v.(float64)
+		if !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("Parameter %v should be a number", p.Name)
+		}
+		if p.Type == constant.ParamInteger && f != math.Trunc(f) {
+			return nil, fmt.Errorf("Parameter %v should be an integer", p.Name)
+		}
+		if p.Min != nil && f < *p.Min {
+			return nil, fmt.Errorf("Parameter %v should not be less than %v", p.Name, *p.Min)
+		}
+		if p.Max != nil && f > *p.Max {
+			return nil, fmt.Errorf("Parameter %v should not be greater than %v", p.Name, *p.Max)
+		}
+		if p.Type == constant.ParamInteger {
+			return int64(f), nil
+		}
+		return f, nil
+	case constant.ParamString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("Parameter %v should be a string", p.Name)
+		}
+		if len(p.Options) == 0 {
+			return s, nil
+		}
+		for _, o := range p.Options {
+			if s == o {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("Parameter %v should be one of %v", p.Name, strings.Join(p.Options, ", "))
+	case constant.ParamBool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("Parameter %v should be a boolean", p.Name)
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("Parameter %v has an unsupported type: %v", p.Name, p.Type)
+}
+
+// ParseParams parse and check the parameters declared in Algorithm.EvnDefault
+func ParseParams(evnDefault string) (params []Param, err error) {
+	if strings.TrimSpace(evnDefault) == "" {
+		return
+	}
+	if err = json.Unmarshal([]byte(evnDefault), &params); err != nil {
+		return nil, fmt.Errorf("Invalid parameters: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, p := range params {
+		if !paramName.MatchString(p.Name) {
+			return nil, fmt.Errorf("Invalid parameter name: %q", p.Name)
+		}
+		for _, r := range reservedParams {
+			if p.Name == r {
+				return nil, fmt.Errorf("Parameter %v is reserved", p.Name)
+			}
+		}
+		if names[p.Name] {
+			return nil, fmt.Errorf("Duplicate parameter: %v", p.Name)
+		}
+		names[p.Name] = true
+		if p.Min != nil && p.Max != nil && *p.Min > *p.Max {
+			return nil, fmt.Errorf("Parameter %v has a min greater than the max", p.Name)
+		}
+		if _, err = p.Check(p.Default); err != nil {
+			return nil, fmt.Errorf("Invalid default value, %v", err)
+		}
+	}
+	return
+}
+
+// ParseEnvironment merge the parameter values of Trader.Environment, a json object like {"Amount": 0.2},
+// into the defaults, the parameters not declared by the algorithm are not allowed
+func ParseEnvironment(evnDefault, environment string) (env map[string]interface{}, err error) {
+	params, err := ParseParams(evnDefault)
+	if err != nil {
+		return
+	}
+	values := make(map[string]interface{})
+	if strings.TrimSpace(environment) != "" {
+		if err = json.Unmarshal([]byte(environment), &values); err != nil {
+			return nil, fmt.Errorf("Invalid environment: %v", err)
+		}
+	}
+	env = make(map[string]interface{})
+	for _, p := range params {
+		v, ok := values[p.Name]
+		if !ok {
+			v = p.Default
+		}
+		if env[p.Name], err = p.Check(v); err != nil {
+			return nil, err
+		}
+		delete(values, p.Name)
+	}
+	for name := range values {
+		return nil, fmt.Errorf("Unknown parameter: %v", name)
+	}
+	return
+}
+
+// CheckEnvironment check the parameter values of a trader against its algorithm
+func CheckEnvironment(trader Trader) error {
+	if trader.AlgorithmID <= 0 {
+		return nil
+	}
+	algorithm := Algorithm{}
+	if err := DB.First(&algorithm, trader.AlgorithmID).Error; err != nil {
+		return err
+	}
+	_, err := ParseEnvironment(algorithm.EvnDefault, trader.Environment)
+	return err
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testParams = `[
+	{"name": "Amount", "type": "number", "default": 0.1, "min": 0, "max": 10},
+	{"name": "Period", "type": "integer", "default": 20},
+	{"name": "Symbol", "type": "string", "default": "BTC/USDT", "options": ["BTC/USDT", "ETH/USDT"]},
+	{"name": "Debug", "type": "bool", "default": false}
+]`
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params string
+	}{
+		{"invalid json", `{"name": "Amount"}`},
+		{"invalid name", `[{"name": "a-b", "type": "number", "default": 1}]`},
+		{"reserved name", `[{"name": "E", "type": "number", "default": 1}]`},
+		{"duplicate name", `[{"name": "A", "type": "bool", "default": true}, {"name": "A", "type": "bool", "default": true}]`},
+		{"unsupported type", `[{"name": "A", "type": "date", "default": 1}]`},
+		{"invalid range", `[{"name": "A", "type": "number", "default": 1, "min": 2, "max": 0}]`},
+		{"invalid default", `[{"name": "A", "type": "integer", "default": 1.5}]`},
+		{"default out of range", `[{"name": "A", "type": "number", "default": 5, "max": 2}]`},
+		{"default not in options", `[{"name": "A", "type": "string", "default": "c", "options": ["a", "b"]}]`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseParams(tt.params); err == nil {
+			t.Errorf("ParseParams() with %v should fail", tt.name)
+		}
+	}
+	if params, err := ParseParams(testParams); err != nil || len(params) != 4 {
+		t.Errorf("ParseParams() = %+v, %v", params, err)
+	}
+}
+
+func TestParseEnvironment(t *testing.T) {
+	env, err := ParseEnvironment(testParams, `{"Amount": 0.5, "Period": 30}`)
+	if want := map[string]interface{}{"Amount": 0.5, "Period": int64(30), "Symbol": "BTC/USDT", "Debug": false}; err != nil || !reflect.DeepEqual(env, want) {
+		t.Errorf("ParseEnvironment() = %v, %v, want %v", env, err, want)
+	}
+	for _, environment := range []string{
+		`{"Amount": 11}`,
+		`{"Amount": "0.5"}`,
+		`{"Period": 1.5}`,
+		`{"Symbol": "LTC/USDT"}`,
+		`{"Debug": 1}`,
+		`{"Unknown": 1}`,
+		`[1]`,
+	} {
+		if _, err := ParseEnvironment(testParams, environment); err == nil {
+			t.Errorf("ParseEnvironment(%v) should fail", environment)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// limits of the storage of a trader
+var (
+	MaxStoreKeyLength = 200      //键的最大长度
+	MaxStoreValueSize = 64 << 10 //值的 json 的最大字节数
+	MaxStoreKeys      = 1000     //每个机器人最多保存的键的数量
+)
+
+// Store a key-value pair saved by G.Set, the value is a json
+type Store struct {
+	ID        int64     `gorm:"primary_key;AUTO_INCREMENT" json:"-"`
+	TraderID  int64     `gorm:"unique_index:idx_store" json:"traderId"`
+	Key       string    `gorm:"column:store_key;type:varchar(200);unique_index:idx_store" json:"key"`
+	Value     string    `gorm:"type:text" json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ListStore get all key-value pairs of a trader ordered by the keys
+func ListStore(traderID int64) (stores []Store, err error) {
+	err = DB.Where("trader_id = ?", traderID).Order("store_key").Find(&stores).Error
+	return
+}
+
+// GetStore get the json value of a key, false if the key does not exist
+func GetStore(traderID int64, key string) (value string, ok bool, err error) {
+	stores := []Store{}
+	if err = DB.Where("trader_id = ? AND store_key = ?", traderID, key).Find(&stores).Error; err != nil || len(stores) == 0 {
+		return
+	}
+	return stores[0].Value, true, nil
+}
+
+// SetStore save the json value of a key, the existing one is replaced
+func SetStore(traderID int64, key, value string) (err error) {
+	if key == "" || len(key) > MaxStoreKeyLength {
+		return fmt.Errorf("The length of the key should be between 1 and %v", MaxStoreKeyLength)
+	}
+	if len(value) > MaxStoreValueSize {
+		return fmt.Errorf("The value of %v is %v bytes, it should not exceed %v bytes", key, len(value), MaxStoreValueSize)
+	}
+	if !json.Valid([]byte(value)) {
+		return fmt.Errorf("The value of %v is not a valid json", key)
+	}
+	stores := []Store{}
+	if err = DB.Where("trader_id = ? AND store_key = ?", traderID, key).Find(&stores).Error; err != nil {
+		return
+	}
+	if len(stores) > 0 {
+		stores[0].Value = value
+		return DB.Save(&stores[0]).Error
+	}
+	total := 0
+	if err = DB.Model(&Store{}).Where("trader_id = ?", traderID).Count(&total).Error; err != nil {
+		return
+	}
+	if total >= MaxStoreKeys {
+		return fmt.Errorf("A trader can save at most %v keys", MaxStoreKeys)
+	}
+	return DB.Create(&Store{TraderID: traderID, Key: key, Value: value}).Error
+}
+
+// DeleteStore delete a key, false if the key does not exist
+func DeleteStore(traderID int64, key string) (ok bool, err error) {
+	db := DB.Where("trader_id = ? AND store_key = ?", traderID, key).Delete(&Store{})
+	return db.RowsAffected > 0, db.Error
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	const id = -100
+	if err := SetStore(id, "grid", `[1,2]`); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetStore(id, "grid", `[1,2,3]`); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := GetStore(id, "grid"); err != nil || !ok || v != `[1,2,3]` {
+		t.Errorf("GetStore() = %v, %v, %v", v, ok, err)
+	}
+	if _, ok, err := GetStore(id, "none"); err != nil || ok {
+		t.Errorf("GetStore() a missing key = %v, %v", ok, err)
+	}
+	for _, tt := range []struct{ key, value string }{
+		{"", "1"},
+		{strings.Repeat("k", MaxStoreKeyLength+1), "1"},
+		{"big", `"` + strings.Repeat("v", MaxStoreValueSize) + `"`},
+		{"invalid", `{`},
+	} {
+		if err := SetStore(id, tt.key, tt.value); err == nil {
+			t.Errorf("SetStore(%.20q, %.20q) should fail", tt.key, tt.value)
+		}
+	}
+	max := MaxStoreKeys
+	MaxStoreKeys = 2
+	defer func() { MaxStoreKeys = max }()
+	if err := SetStore(id, "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetStore(id, "b", "1"); err == nil {
+		t.Error("SetStore() more than MaxStoreKeys keys should fail")
+	}
+	if stores, err := ListStore(id); err != nil || len(stores) != 2 || stores[0].Key != "a" || stores[1].Key != "grid" {
+		t.Errorf("ListStore() = %+v, %v", stores, err)
+	}
+	if ok, err := DeleteStore(id, "a"); err != nil || !ok {
+		t.Errorf("DeleteStore() = %v, %v", ok, err)
+	}
+	if ok, err := DeleteStore(id, "a"); err != nil || ok {
+		t.Errorf("DeleteStore() a missing key = %v, %v", ok, err)
+	}
+}
@@ -9,21 +9,82 @@ import (
 
 // Trader struct
 type Trader struct {
-	ID          int64      `gorm:"primary_key" json:"id"`
-	UserID      int64      `gorm:"index" json:"userId"`
-	AlgorithmID int64      `gorm:"index" json:"algorithmId"`
-	Name        string     `gorm:"type:varchar(200)" json:"name"`
-	Environment string     `gorm:"type:text" json:"environment"`
-	LastRunAt   time.Time  `json:"lastRunAt"`
-	CreatedAt   time.Time  `json:"createdAt"`
-	UpdatedAt   time.Time  `json:"updatedAt"`
-	DeletedAt   *time.Time `sql:"index" json:"-"`
+	ID            int64      `gorm:"primary_key" json:"id"`
+	UserID        int64      `gorm:"index" json:"userId"`
+	AlgorithmID   int64      `gorm:"index" json:"algorithmId"`
+	Name          string     `gorm:"type:varchar(200)" json:"name"`
+	Environment   string     `gorm:"type:text" json:"environment"`
+	RestartPolicy string     `gorm:"type:varchar(20)" json:"restartPolicy"` //constant.RestartNever, RestartOnFailure 或 RestartAlways, 为空时不重启
+	MaxRestarts   int64      `json:"maxRestarts"`                           //restartWindow 内最多重启的次数, <= 0 表示不限制
+	RestartWindow int64      `json:"restartWindow"`                         //统计重启次数的时间窗口, 秒, <= 0 时为 3600
+	Running       bool       `gorm:"index" json:"running"`                  //是否应该运行, 服务重启后恢复运行
+	LastRunAt     time.Time  `json:"lastRunAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	DeletedAt     *time.Time `sql:"index" json:"-"`
 
 	Exchanges []Exchange `gorm:"-" json:"exchanges"`
 	Status    int64      `gorm:"-" json:"status"`
+	State     string     `gorm:"-" json:"state"` //constant.TraderIdle, TraderRunning 等
+	Mode      string     `gorm:"-" json:"mode"`  //live, sandbox 或 mixed
+	Clocks    []Clock    `gorm:"-" json:"clocks"`
 	Algorithm Algorithm  `gorm:"-" json:"algorithm"`
 }
 
+// Clock the clock synchronization status of an exchange
+type Clock struct {
+	ExchangeName string `json:"exchangeName"`
+	Offset       int64  `json:"offset"`  //本地时钟和服务器时钟的偏差,毫秒
+	Latency      int64  `json:"latency"` //往返延迟,毫秒
+}
+
+// TraderStatus the live status of a trader, it is shown as a status panel
+type TraderStatus struct {
+	ID          int64     `json:"id"`
+	State       string    `json:"state"`       //constant.TraderIdle, TraderStarting, TraderRunning, TraderStopping, TraderStopped, TraderCrashed 或 TraderRestarting
+	Status      int64     `json:"status"`      //0 停止, 1 运行中, 2 停止中, 3 等待重启
+	LastRunAt   time.Time `json:"lastRunAt"`   //启动时间
+	Heartbeat   time.Time `json:"heartbeat"`   //策略最后一次调用 G.Sleep 的时间
+	LastError   string    `json:"lastError"`   //最后一条错误日志
+	LastErrorAt time.Time `json:"lastErrorAt"` //最后一条错误日志的时间
+	Format      string    `json:"format"`      //状态信息的格式, constant.StatusText, StatusJSON 或 StatusTable
+	Content     string    `json:"content"`     //G.LogStatus 输出的状态信息, json 和 table 格式为 json 字符串
+	UpdatedAt   time.Time `json:"updatedAt"`   //状态信息的更新时间
+}
+
+// SetTraderRunning save whether the trader should be running
+func SetTraderRunning(id int64, running bool) error {
+	return DB.Model(&Trader{}).Where("id = ?", id).Update("running", running).Error
+}
+
+// CheckRestartPolicy check if the restart policy is supported
+func CheckRestartPolicy(policy string) error {
+	switch policy {
+	case "", constant.RestartNever, constant.RestartOnFailure, constant.RestartAlways:
+		return nil
+	}
+	return fmt.Errorf("Unsupported restart policy: %v", policy)
+}
+
+// TradeMode get the trade mode of the exchanges, live, sandbox or mixed
+func TradeMode(exchanges []Exchange) string {
+	sandbox, live := false, false
+	for _, e := range exchanges {
+		if e.IsSandbox() {
+			sandbox = true
+		} else {
+			live = true
+		}
+	}
+	switch {
+	case sandbox && live:
+		return constant.ModeMixed
+	case sandbox:
+		return constant.ModeSandbox
+	}
+	return constant.ModeLive
+}
+
 // TraderExchange struct
 type TraderExchange struct {
 	ID         int64 `gorm:"primary_key"`
@@ -98,6 +159,17 @@ func (user User) UpdateTrader(req Trader) (err error) {
 	}
 	runner.Name = req.Name
 	runner.Environment = req.Environment
+	runner.RestartPolicy = req.RestartPolicy
+	runner.MaxRestarts = req.MaxRestarts
+	runner.RestartWindow = req.RestartWindow
+	if err := CheckRestartPolicy(runner.RestartPolicy); err != nil {
+		db.Rollback()
+		return err
+	}
+	if err := CheckEnvironment(runner); err != nil {
+		db.Rollback()
+		return err
+	}
 	rs, err := user.GetTraderExchanges(runner.ID)
 	if err != nil {
 		db.Rollback()
@@ -0,0 +1,170 @@
+package symbol
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+// Converter converts between the canonical symbols and the instrument IDs of an exchange
+type Converter interface {
+	ToExchange(sym Symbol) (string, error)
+	FromExchange(id string) (Symbol, error)
+}
+
+var (
+	converters = map[string]Converter{
+		constant.Okex:       okex{},
+		constant.OkexFuture: okex{},
+		constant.Binance:    concat{lower: false},
+		constant.Huobi:      concat{lower: true},
+		constant.Zb:         separated{sep: "_", lower: true},
+		constant.GateIo:     separated{sep: "_", lower: true},
+		constant.Poloniex:   separated{sep: "_", lower: false, reverse: true},
+		constant.BigOne:     separated{sep: "-", lower: false},
+	}
+	// quotes the known quote currencies, used to split the symbols without separator, eg: BTCUSDT
+	quotes = []string{"USDT", "USDC", "BUSD", "TUSD", "FDUSD", "BTC", "ETH", "BNB", "HT", "EUR", "TRY", "USD"}
+)
+
+// ToExchange convert a symbol(canonical or legacy) to the instrument ID of an exchange type
+func ToExchange(exchangeType, s string) (string, error) {
+	c, ok := converters[exchangeType]
+	if !ok {
+		return "", fmt.Errorf("unsupported exchange type: %v", exchangeType)
+	}
+	sym, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return c.ToExchange(sym)
+}
+
+// Market convert a canonical symbol to the instrument ID of an exchange type for the raw api clients,
+// an ID which is not a canonical symbol(eg: btc_usdt) is returned as it is
+func Market(exchangeType, s string) string {
+	if id, err := ToExchange(exchangeType, s); err == nil {
+		return id
+	}
+	return s
+}
+
+// FromExchange convert an instrument ID of an exchange type to the canonical symbol
+func FromExchange(exchangeType, id string) (Symbol, error) {
+	c, ok := converters[exchangeType]
+	if !ok {
+		return Symbol{}, fmt.Errorf("unsupported exchange type: %v", exchangeType)
+	}
+	return c.FromExchange(id)
+}
+
+// okex BTC-USDT, BTC-USDT-SWAP, BTC-USD-240329, BTC-USD-240329-30000-C
+type okex struct{}
+
+func (okex) ToExchange(sym Symbol) (string, error) {
+	// U本位合约用计价货币结算, 币本位合约(计价货币为 USD)用基础货币结算
+	if sym.Type != Spot && sym.Settle != okexSettle(sym) {
+		return "", fmt.Errorf("unsupported symbol: %v, the settle currency should be %v", sym, okexSettle(sym))
+	}
+	switch sym.Type {
+	case Spot:
+		return sym.Base + "-" + sym.Quote, nil
+	case Swap:
+		return sym.Base + "-" + sym.Quote + "-SWAP", nil
+	case Future, Option:
+		return sym.Base + "-" + sym.Quote + "-" + sym.Expiry, nil
+	}
+	return "", fmt.Errorf("unsupported symbol: %v", sym)
+}
+
+func (okex) FromExchange(id string) (sym Symbol, err error) {
+	parts := strings.Split(strings.ToUpper(id), "-")
+	if len(parts) < 2 {
+		return sym, fmt.Errorf("invalid okex instId: %v", id)
+	}
+	sym.Base, sym.Quote = parts[0], parts[1]
+	if len(parts) == 2 {
+		sym.Type = Spot
+		return
+	}
+	sym.Settle = okexSettle(sym)
+	switch {
+	case len(parts) == 3 && parts[2] == Swap:
+		sym.Type = Swap
+	case len(parts) == 3:
+		sym.Type = Future
+		sym.Expiry = parts[2]
+	case len(parts) == 5:
+		sym.Type = Option
+		sym.Expiry = strings.Join(parts[2:], "-")
+	default:
+		err = fmt.Errorf("invalid okex instId: %v", id)
+	}
+	return
+}
+
+// okexSettle get the settle currency of an okex derivative
+func okexSettle(sym Symbol) string {
+	if sym.Quote == "USD" {
+		return sym.Base
+	}
+	return sym.Quote
+}
+
+// concat BTCUSDT(binance), btcusdt(huobi), spot only
+type concat struct {
+	lower bool
+}
+
+func (c concat) ToExchange(sym Symbol) (string, error) {
+	if sym.Type != Spot {
+		return "", fmt.Errorf("unsupported symbol: %v", sym)
+	}
+	if c.lower {
+		return strings.ToLower(sym.Base + sym.Quote), nil
+	}
+	return sym.Base + sym.Quote, nil
+}
+
+func (c concat) FromExchange(id string) (sym Symbol, err error) {
+	id = strings.ToUpper(id)
+	for _, q := range quotes {
+		if strings.HasSuffix(id, q) && len(id) > len(q) {
+			return Symbol{Base: strings.TrimSuffix(id, q), Quote: q, Type: Spot}, nil
+		}
+	}
+	return sym, fmt.Errorf("unrecognized symbol: %v", id)
+}
+
+// separated btc_usdt(zb, gate.io), USDT_BTC(poloniex), BTC-USDT(big.one), spot only
+type separated struct {
+	sep     string
+	lower   bool
+	reverse bool //计价货币在前
+}
+
+func (c separated) ToExchange(sym Symbol) (string, error) {
+	if sym.Type != Spot {
+		return "", fmt.Errorf("unsupported symbol: %v", sym)
+	}
+	id := sym.Base + c.sep + sym.Quote
+	if c.reverse {
+		id = sym.Quote + c.sep + sym.Base
+	}
+	if c.lower {
+		id = strings.ToLower(id)
+	}
+	return id, nil
+}
+
+func (c separated) FromExchange(id string) (sym Symbol, err error) {
+	parts := strings.Split(strings.ToUpper(id), c.sep)
+	if len(parts) != 2 {
+		return sym, fmt.Errorf("unrecognized symbol: %v", id)
+	}
+	if c.reverse {
+		parts[0], parts[1] = parts[1], parts[0]
+	}
+	return Symbol{Base: parts[0], Quote: parts[1], Type: Spot}, nil
+}
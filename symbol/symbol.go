@@ -0,0 +1,97 @@
+package symbol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// instrument types
+const (
+	Spot   = "SPOT"
+	Swap   = "SWAP"
+	Future = "FUTURES"
+	Option = "OPTION"
+)
+
+// Symbol a market identified as BASE/QUOTE[:SETTLE][@EXPIRY]
+//
+//	BTC/USDT                   现货
+//	BTC/USDT:USDT              U本位永续合约
+//	BTC/USD:BTC                币本位永续合约
+//	BTC/USD:BTC@240329         交割合约, 到期日 yyMMdd
+//	BTC/USD:BTC@240329-30000-C 期权, 到期日-行权价-C/P
+type Symbol struct {
+	Base   string //基础货币
+	Quote  string //计价货币
+	Settle string //结算货币, 现货为空
+	Expiry string //到期日, 期权还包含行权价和类型
+	Type   string //SPOT, SWAP, FUTURES, OPTION
+}
+
+// Parse parse a canonical symbol, the legacy BASE/QUOTE/SWAP is also accepted
+func Parse(s string) (sym Symbol, err error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if i := strings.Index(s, "@"); i >= 0 {
+		sym.Expiry = s[i+1:]
+		s = s[:i]
+	}
+	if i := strings.Index(s, ":"); i >= 0 {
+		sym.Settle = s[i+1:]
+		s = s[:i]
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) == 3 && parts[2] == Swap && sym.Settle == "" && sym.Expiry == "" {
+		parts = parts[:2]
+		sym.Settle = parts[1]
+		if parts[1] == "USD" {
+			sym.Settle = parts[0]
+		}
+	}
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return sym, fmt.Errorf("invalid symbol: %v", s)
+	}
+	sym.Base, sym.Quote = parts[0], parts[1]
+	switch {
+	case sym.Settle == "" && sym.Expiry == "":
+		sym.Type = Spot
+	case sym.Settle == "":
+		return sym, fmt.Errorf("invalid symbol: %v, the settle currency is required by a derivative", s)
+	case sym.Expiry == "":
+		sym.Type = Swap
+	case strings.Count(sym.Expiry, "-") == 2:
+		sym.Type = Option
+	default:
+		sym.Type = Future
+	}
+	return
+}
+
+// MustParse parse a symbol, panic if it is invalid
+func MustParse(s string) Symbol {
+	sym, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return sym
+}
+
+// String get the canonical identifier
+func (s Symbol) String() string {
+	id := s.Base + "/" + s.Quote
+	if s.Settle != "" {
+		id += ":" + s.Settle
+	}
+	if s.Expiry != "" {
+		id += "@" + s.Expiry
+	}
+	return id
+}
+
+// Normalize get the canonical identifier of a symbol, the input is returned if it is invalid
+func Normalize(s string) string {
+	sym, err := Parse(s)
+	if err != nil {
+		return strings.ToUpper(s)
+	}
+	return sym.String()
+}
@@ -0,0 +1,136 @@
+package symbol
+
+import (
+	"testing"
+
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Symbol
+		err  bool
+	}{
+		{s: "btc/usdt", want: Symbol{Base: "BTC", Quote: "USDT", Type: Spot}},
+		{s: " BTC/USDT:USDT ", want: Symbol{Base: "BTC", Quote: "USDT", Settle: "USDT", Type: Swap}},
+		{s: "BTC/USD:BTC", want: Symbol{Base: "BTC", Quote: "USD", Settle: "BTC", Type: Swap}},
+		{s: "BTC/USD:BTC@240329", want: Symbol{Base: "BTC", Quote: "USD", Settle: "BTC", Expiry: "240329", Type: Future}},
+		{s: "BTC/USD:BTC@240329-30000-C", want: Symbol{Base: "BTC", Quote: "USD", Settle: "BTC", Expiry: "240329-30000-C", Type: Option}},
+		{s: "BTC/USDT/SWAP", want: Symbol{Base: "BTC", Quote: "USDT", Settle: "USDT", Type: Swap}},
+		{s: "btc/usd/swap", want: Symbol{Base: "BTC", Quote: "USD", Settle: "BTC", Type: Swap}},
+		{s: "BTC/USD@240329", err: true},
+		{s: "BTCUSDT", err: true},
+		{s: "BTC/", err: true},
+		{s: "BTC/USDT/ETH", err: true},
+	}
+	for _, tt := range tests {
+		sym, err := Parse(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("Parse(%q) error = %v, want error %v", tt.s, err, tt.err)
+			continue
+		}
+		if !tt.err && sym != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.s, sym, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		s, want string
+	}{
+		{"btc/usdt", "BTC/USDT"},
+		{"btc/usdt/swap", "BTC/USDT:USDT"},
+		{"BTC/USD/SWAP", "BTC/USD:BTC"},
+		{"btc/usd:btc@240329", "BTC/USD:BTC@240329"},
+		{"btc_usdt", "BTC_USDT"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.s); got != tt.want {
+			t.Errorf("Normalize(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestExchangeRoundTrip(t *testing.T) {
+	tests := []struct {
+		exchangeType, s, id string
+	}{
+		{constant.Okex, "BTC/USDT", "BTC-USDT"},
+		{constant.Okex, "BTC/USDT:USDT", "BTC-USDT-SWAP"},
+		{constant.Okex, "BTC/USD:BTC", "BTC-USD-SWAP"},
+		{constant.Okex, "BTC/USD:BTC@240329", "BTC-USD-240329"},
+		{constant.Okex, "BTC/USD:BTC@240329-30000-C", "BTC-USD-240329-30000-C"},
+		{constant.Binance, "BTC/USDT", "BTCUSDT"},
+		{constant.Huobi, "ETH/BTC", "ethbtc"},
+		{constant.Zb, "EOS/USDT", "eos_usdt"},
+		{constant.GateIo, "BTC/USDT", "btc_usdt"},
+		{constant.Poloniex, "ETH/BTC", "BTC_ETH"},
+		{constant.BigOne, "ONE/USDT", "ONE-USDT"},
+	}
+	for _, tt := range tests {
+		id, err := ToExchange(tt.exchangeType, tt.s)
+		if err != nil || id != tt.id {
+			t.Errorf("ToExchange(%v, %v) = %v, %v, want %v", tt.exchangeType, tt.s, id, err, tt.id)
+			continue
+		}
+		sym, err := FromExchange(tt.exchangeType, id)
+		if err != nil || sym.String() != tt.s {
+			t.Errorf("FromExchange(%v, %v) = %v, %v, want %v", tt.exchangeType, id, sym, err, tt.s)
+		}
+	}
+}
+
+func TestToExchangeErrors(t *testing.T) {
+	tests := []struct {
+		exchangeType, s string
+	}{
+		// 结算货币和合约类型不匹配
+		{constant.Okex, "BTC/USD:USDT"},
+		{constant.Okex, "BTC/USDT:BTC"},
+		{constant.Okex, "BTC/USD:USD@240329"},
+		// 只支持现货
+		{constant.Binance, "BTC/USDT:USDT"},
+		{constant.Zb, "BTC/USD:BTC@240329"},
+		{"unknown", "BTC/USDT"},
+		{constant.Okex, "BTCUSDT"},
+	}
+	for _, tt := range tests {
+		if id, err := ToExchange(tt.exchangeType, tt.s); err == nil {
+			t.Errorf("ToExchange(%v, %v) = %v, want an error", tt.exchangeType, tt.s, id)
+		}
+	}
+}
+
+func TestMarket(t *testing.T) {
+	tests := []struct {
+		exchangeType, s, want string
+	}{
+		{constant.Zb, "BTC/USDT", "btc_usdt"},
+		{constant.Zb, "btc_usdt", "btc_usdt"},
+		{constant.Huobi, "btcusdt", "btcusdt"},
+		{constant.BigOne, "BTC/USDT:USDT", "BTC/USDT:USDT"},
+	}
+	for _, tt := range tests {
+		if got := Market(tt.exchangeType, tt.s); got != tt.want {
+			t.Errorf("Market(%v, %v) = %v, want %v", tt.exchangeType, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestFromExchangeErrors(t *testing.T) {
+	tests := []struct {
+		exchangeType, id string
+	}{
+		{constant.Okex, "BTC"},
+		{constant.Okex, "BTC-USD-240329-30000"},
+		{constant.Binance, "XYZ"},
+		{constant.Zb, "btcusdt"},
+	}
+	for _, tt := range tests {
+		if sym, err := FromExchange(tt.exchangeType, tt.id); err == nil {
+			t.Errorf("FromExchange(%v, %v) = %v, want an error", tt.exchangeType, tt.id, sym)
+		}
+	}
+}
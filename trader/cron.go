@@ -0,0 +1,138 @@
+package trader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule a parsed cron expression, every field is a bit set of the matched values
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool //日期和星期是否为 *, 都不为 * 时满足其中一个即可
+}
+
+// cronField the range of a field of the cron expression
+type cronField struct {
+	min, max uint
+}
+
+var cronFields = []cronField{
+	{0, 59}, // 秒
+	{0, 59}, // 分
+	{0, 23}, // 时
+	{1, 31}, // 日
+	{1, 12}, // 月
+	{0, 7},  // 星期, 0 和 7 都表示星期日
+}
+
+// parseCron parse a cron expression like "0 * * * *", it has 5 fields (minute hour day month weekday)
+// or 6 fields with the leading second, every field supports *, lists, ranges and steps like 1,5,10-20/2
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("Invalid cron expression %q, it should have 5 or 6 fields", spec)
+	}
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		b, err := parseCronField(f, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cron expression %q, %v", spec, err)
+		}
+		bits[i] = b
+	}
+	// 星期日可以写成 7
+	if bits[5]&(1<<7) > 0 {
+		bits[5] |= 1
+	}
+	return &cronSchedule{
+		second:  bits[0],
+		minute:  bits[1],
+		hour:    bits[2],
+		dom:     bits[3],
+		month:   bits[4],
+		dow:     bits[5],
+		domStar: strings.HasPrefix(fields[3], "*"),
+		dowStar: strings.HasPrefix(fields[5], "*"),
+	}, nil
+}
+
+// parseCronField parse a field to a bit set
+func parseCronField(field string, r cronField) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, uint64(1)
+		if i := strings.Index(part, "/"); i >= 0 {
+			rng = part[:i]
+			if step, err = strconv.ParseUint(part[i+1:], 10, 8); err != nil || step == 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+		lo, hi := uint64(r.min), uint64(r.max)
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			if lo, err = strconv.ParseUint(bounds[0], 10, 8); err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+			if hi, err = strconv.ParseUint(bounds[1], 10, 8); err != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			if lo, err = strconv.ParseUint(rng, 10, 8); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			// 单个值带步长时表示从这个值开始到最大值
+			if strings.Contains(part, "/") {
+				hi = uint64(r.max)
+			}
+		}
+		if lo < uint64(r.min) || hi > uint64(r.max) || lo > hi {
+			return 0, fmt.Errorf("%q is out of the range %v-%v", part, r.min, r.max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// matchDay check if the day of t matches the day of month and the day of week
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) > 0
+	dow := c.dow&(1<<uint(t.Weekday())) > 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next get the first matched time after t, zero if there is none in 5 years
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Second).Add(time.Second)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		// Truncate 按绝对时间取整, 在 +05:30 这样的时区会落在半点, 所以按本地时间构造
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+		case c.second&(1<<uint(t.Second())) == 0:
+			t = t.Add(time.Second)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,212 @@
+package trader
+
+import (
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/phonegapX/QuantBot/api"
+)
+
+// eventInterval the default interval between two polls of the event-driven mode
+var eventInterval = time.Second
+
+// eventHandlers the functions of a script which make it run in the event-driven mode when there is no main()
+var eventHandlers = []string{"onTick", "onBar", "onOrder", "onPosition", "onTimer"}
+
+// subscription a market watched by the event-driven mode
+type subscription struct {
+	symbol  string
+	periods []string
+}
+
+// events the subscriptions and the last seen states of the event-driven mode
+type events struct {
+	interval  time.Duration
+	subs      []subscription
+	tickers   map[string]api.Ticker           //symbol -> 最新的行情
+	bars      map[string]int64                //symbol@period -> 未完成的K线的时间
+	orders    map[string]map[string]api.Order //symbol -> id -> 未完成的订单
+	positions map[string]api.Position         //instId@posSide -> 持仓
+}
+
+func newEvents() *events {
+	return &events{
+		interval:  eventInterval,
+		tickers:   make(map[string]api.Ticker),
+		bars:      make(map[string]int64),
+		orders:    make(map[string]map[string]api.Order),
+		positions: make(map[string]api.Position),
+	}
+}
+
+// Subscribe watch the ticker, the orders and the bars of the periods of a symbol of the main exchange in the event-driven mode
+func (g *Global) Subscribe(symbol string, periods ...string) bool {
+	if !g.onMainLoop() {
+		g.logError("Subscribe(), can not subscribe in tasks")
+		return false
+	}
+	if symbol == "" {
+		g.logError("Subscribe(), Invalid symbol")
+		return false
+	}
+	for i, sub := range g.events.subs {
+		if sub.symbol == symbol {
+			g.events.subs[i].periods = append(sub.periods, periods...)
+			return true
+		}
+	}
+	g.events.subs = append(g.events.subs, subscription{symbol: symbol, periods: periods})
+	return true
+}
+
+// SetEventInterval set the interval between two polls of the event-driven mode, in milliseconds
+func (g *Global) SetEventInterval(ms int64) bool {
+	interval := time.Duration(ms) * time.Millisecond
+	if interval < minTimerInterval {
+		g.logError("SetEventInterval(), Invalid interval")
+		return false
+	}
+	g.events.interval = interval
+	return true
+}
+
+// handlers get the event handlers defined by the script
+func (g *Global) handlers() map[string]goja.Value {
+	handlers := make(map[string]goja.Value)
+	for _, name := range eventHandlers {
+		if fn := g.ctx.Get(name); isFunction(fn) {
+			handlers[name] = fn
+		}
+	}
+	return handlers
+}
+
+// emit call an event handler, an error of the handler is logged and does not stop the trader
+func (g *Global) emit(fn goja.Value, args ...interface{}) {
+	if g.runContext().Err() != nil {
+		return
+	}
+	g.monitor.beat()
+	if _, err := callJS(g.ctx, fn, args...); err != nil && !isHalt(err) {
+		g.logError(err)
+	}
+}
+
+// eventLoop poll the subscriptions and call the handlers every interval until the trader is stopped,
+// the timers run between two polls
+func (g *Global) eventLoop(handlers map[string]goja.Value) {
+	ctx := g.runContext()
+	for ctx.Err() == nil {
+		next := g.clock.Now().Add(g.events.interval)
+		g.poll(handlers)
+		g.waitUntil(next)
+	}
+}
+
+// poll query the main exchange once and call the handlers of the changes
+func (g *Global) poll(handlers map[string]goja.Value) {
+	e := g.es[0]
+	for _, sub := range g.events.subs {
+		if fn, ok := handlers["onTick"]; ok {
+			g.pollTicker(e, sub.symbol, fn)
+		}
+		if fn, ok := handlers["onBar"]; ok {
+			for _, period := range sub.periods {
+				g.pollBars(e, sub.symbol, period, fn)
+			}
+		}
+		if fn, ok := handlers["onOrder"]; ok {
+			g.pollOrders(e, sub.symbol, fn)
+		}
+	}
+	if fn, ok := handlers["onPosition"]; ok {
+		g.pollPositions(e, fn)
+	}
+	if fn, ok := handlers["onTimer"]; ok {
+		g.emit(fn)
+	}
+}
+
+// pollTicker call onTick(ticker, symbol) when the best prices change
+func (g *Global) pollTicker(e api.Exchange, symbol string, fn goja.Value) {
+	ticker, ok := e.GetTicker(symbol).(api.Ticker)
+	if !ok {
+		return
+	}
+	last, seen := g.events.tickers[symbol]
+	g.events.tickers[symbol] = ticker
+	if !seen || last.Buy != ticker.Buy || last.Sell != ticker.Sell {
+		g.emit(fn, ticker, symbol)
+	}
+}
+
+// pollBars call onBar(symbol, period, record) for every bar closed since the last poll,
+// the first poll only remembers the forming bar
+func (g *Global) pollBars(e api.Exchange, symbol, period string, fn goja.Value) {
+	records, ok := e.GetRecords(symbol, period).([]api.Record)
+	if !ok || len(records) == 0 {
+		return
+	}
+	key := symbol + "@" + period
+	forming := records[len(records)-1].Time
+	last, seen := g.events.bars[key]
+	g.events.bars[key] = forming
+	if !seen {
+		return
+	}
+	for _, r := range records[:len(records)-1] {
+		if r.Time >= last && r.Time < forming {
+			g.emit(fn, symbol, period, r)
+		}
+	}
+}
+
+// pollOrders call onOrder(order) when an order is placed or filled, and once more with its final state when it is closed
+func (g *Global) pollOrders(e api.Exchange, symbol string, fn goja.Value) {
+	orders, ok := e.GetOrders(symbol).([]api.Order)
+	if !ok {
+		return
+	}
+	last := g.events.orders[symbol]
+	current := make(map[string]api.Order)
+	for _, o := range orders {
+		current[o.ID] = o
+		if old, seen := last[o.ID]; !seen || old.DealAmount != o.DealAmount {
+			g.emit(fn, o)
+		}
+	}
+	g.events.orders[symbol] = current
+	for id, old := range last {
+		if _, open := current[id]; open {
+			continue
+		}
+		// 已经成交或者撤销的订单, 查询它最后的状态
+		if final, ok := e.GetOrder(symbol, id).([]api.Order); ok && len(final) > 0 {
+			old = final[0]
+		}
+		g.emit(fn, old)
+	}
+}
+
+// pollPositions call onPosition(pos) when a position is opened or changed, a closed position is passed with the amount 0
+func (g *Global) pollPositions(e api.Exchange, fn goja.Value) {
+	positions, ok := e.GetPositions().([]api.Position)
+	if !ok {
+		return
+	}
+	current := make(map[string]api.Position)
+	for _, p := range positions {
+		key := p.InstId + "@" + p.PosSide
+		current[key] = p
+		if old, seen := g.events.positions[key]; !seen || old.Amount != p.Amount || old.Price != p.Price {
+			g.emit(fn, p)
+		}
+	}
+	for key, old := range g.events.positions {
+		if _, open := current[key]; !open {
+			old.Amount, old.ConfirmAmount = 0, 0
+			g.emit(fn, old)
+		}
+	}
+	g.events.positions = current
+}
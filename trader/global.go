@@ -1,18 +1,19 @@
 package trader
 
 import (
-	//"encoding/json"
-	//"fmt"
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
-	//"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/dop251/goja"
 	"github.com/miaolz123/conver"
 	"github.com/phonegapX/QuantBot/api"
 	"github.com/phonegapX/QuantBot/constant"
 	"github.com/phonegapX/QuantBot/model"
-	"github.com/robertkrimen/otto"
 )
 
 type Tasks map[string][]task
@@ -20,30 +21,45 @@ type Tasks map[string][]task
 // Global ...
 type Global struct {
 	model.Trader
-	Logger  model.Logger   //利用这个对象保存日志
-	ctx     *otto.Otto     //js虚拟机
-	es      []api.Exchange //交易所列表
-	tasks   Tasks          //任务列表
-	running bool
-	//statusLog string
+	Logger  model.Logger           //利用这个对象保存日志
+	ctx     *goja.Runtime          //js虚拟机
+	env     map[string]interface{} //策略参数, 任务的虚拟机也需要设置
+	modules *modules               //require 加载的模块
+	es      []api.Exchange         //交易所列表
+	tasks   Tasks                  //任务列表
+	running int32                  //任务是否正在执行, 原子操作
+	stopped int32                  //被用户停止, 强制结束后协程才退出时也不能重启, 原子操作
+	monitor *monitor               //实时状态, 心跳和最后的错误
+	clock   Clock                  //定时器的时钟, 回测时使用虚拟时钟
+	timers  *timers                //定时器
+	events  *events                //事件驱动模式的订阅
+
+	mu     sync.Mutex         //保护 runCtx, cancel 和 tasks 中的虚拟机
+	runCtx context.Context    //停止时被取消, 用于唤醒 G.Sleep 和中断交易所的请求
+	cancel context.CancelFunc //取消 runCtx
+	done   chan struct{}      //策略的协程退出时关闭
 }
 
 //js中的一个任务,目的是可以并发工作
 type task struct {
-	ctx  *otto.Otto    //js虚拟机
-	fn   otto.Value    //代表该任务的js函数
+	ctx  *goja.Runtime //js虚拟机
+	fn   goja.Value    //代表该任务的js函数
 	args []interface{} //函数的参数
 }
 
 // Sleep ...
 func (g *Global) Sleep(intervals ...interface{}) {
+	g.monitor.beat()
 	interval := int64(0)
 	if len(intervals) > 0 {
 		interval = conver.Int64Must(intervals[0])
 	}
 	if interval > 0 {
-		time.Sleep(time.Duration(interval * 1000000))
+		g.waitUntil(g.clock.Now().Add(time.Duration(interval * 1000000)))
 	} else {
+		if g.onMainLoop() {
+			g.runTimers()
+		}
 		for _, e := range g.es {
 			e.AutoSleep()
 		}
@@ -70,66 +86,135 @@ func (g *Global) LogProfit(msgs ...interface{}) {
 }
 
 // LogStatus ...
-//func (g *Global) LogStatus(msgs ...interface{}) {
-//	go func() {
-//		msg := ""
-//		for _, m := range msgs {
-//			v := reflect.ValueOf(m)
-//			switch v.Kind() {
-//			case reflect.Struct, reflect.Map, reflect.Slice:
-//				if bs, err := json.Marshal(m); err == nil {
-//					msg += string(bs)
-//					continue
-//				}
-//			}
-//			msg += fmt.Sprintf("%+v", m)
-//		}
-//		g.statusLog = msg
-//	}()
-//}
+func (g *Global) LogStatus(msgs ...interface{}) bool {
+	format, content, err := formatStatus(msgs...)
+	if err != nil {
+		g.logError("LogStatus(), ", err)
+		return false
+	}
+	g.monitor.setStatus(format, content)
+	return true
+}
+
+// Set save a value which is kept after the trader restarts, the value is saved as a json
+func (g *Global) Set(key string, value interface{}) bool {
+	bs, err := json.Marshal(value)
+	if err != nil {
+		g.logError("Set(), ", err)
+		return false
+	}
+	if err := model.SetStore(g.ID, key, string(bs)); err != nil {
+		g.logError("Set(), ", err)
+		return false
+	}
+	return true
+}
+
+// Get get a value saved by Set, null if the key does not exist
+func (g *Global) Get(key string) interface{} {
+	value, ok, err := model.GetStore(g.ID, key)
+	if err != nil {
+		g.logError("Get(), ", err)
+		return goja.Null()
+	}
+	if !ok {
+		return goja.Null()
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		g.logError("Get(), ", err)
+		return goja.Null()
+	}
+	return v
+}
+
+// Del delete a value saved by Set, false if the key does not exist
+func (g *Global) Del(key string) bool {
+	ok, err := model.DeleteStore(g.ID, key)
+	if err != nil {
+		g.logError("Del(), ", err)
+		return false
+	}
+	return ok
+}
+
+// Keys get the keys of the values saved by Set
+func (g *Global) Keys() []string {
+	keys := []string{}
+	stores, err := model.ListStore(g.ID)
+	if err != nil {
+		g.logError("Keys(), ", err)
+		return keys
+	}
+	for _, s := range stores {
+		keys = append(keys, s.Key)
+	}
+	return keys
+}
+
+// LoadRecords load the candlesticks of the main exchange in [begin, end)(unix ms) which were saved by the download command
+func (g *Global) LoadRecords(stockType, period string, begin, end int64) interface{} {
+	records, err := api.LoadRecords(g.es[0].GetType(), stockType, period, begin, end)
+	if err != nil {
+		g.logError("LoadRecords(), ", err)
+		return false
+	}
+	return records
+}
+
+// logError log an error and keep it as the last error of the trader
+func (g *Global) logError(msgs ...interface{}) {
+	g.monitor.setError(fmt.Sprint(msgs...))
+	g.Logger.Log(constant.ERROR, "", 0.0, 0.0, msgs...)
+}
 
 // AddTask ...
-func (g *Global) AddTask(group otto.Value, fn otto.Value, args ...interface{}) bool {
-	if g.running {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "AddTask(), tasks are running")
+func (g *Global) AddTask(group goja.Value, fn goja.Value, args ...interface{}) bool {
+	if atomic.LoadInt32(&g.running) == 1 {
+		g.logError("AddTask(), tasks are running")
 		return false
 	}
-	if !group.IsString() {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "AddTask(), Invalid group name")
+	if !isString(group) {
+		g.logError("AddTask(), Invalid group name")
 		return false
 	}
-	if !fn.IsString() {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "AddTask(), Invalid function name")
+	if !isString(fn) {
+		g.logError("AddTask(), Invalid function name")
 		return false
 	}
-	if _, ok := g.tasks[group.String()]; !ok {
-		g.tasks[group.String()] = []task{}
+	ctx, err := g.taskRuntime()
+	if err != nil {
+		g.logError("AddTask(), ", err)
+		return false
 	}
-	t := task{ctx: g.ctx.Copy(), fn: fn, args: args}
-	t.ctx.Interrupt = make(chan func(), 1)
+	t := task{ctx: ctx, fn: fn, args: args}
+	g.mu.Lock()
 	g.tasks[group.String()] = append(g.tasks[group.String()], t)
+	g.mu.Unlock()
 	return true
 }
 
 // BindTaskParam ...
-func (g *Global) BindTaskParam(group otto.Value, fn otto.Value, args ...interface{}) bool {
-	if g.running {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "BindTaskParam(), tasks are running")
+func (g *Global) BindTaskParam(group goja.Value, fn goja.Value, args ...interface{}) bool {
+	if atomic.LoadInt32(&g.running) == 1 {
+		g.logError("BindTaskParam(), tasks are running")
 		return false
 	}
-	if !group.IsString() {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "BindTaskParam(), Invalid group name")
+	if !isString(group) {
+		g.logError("BindTaskParam(), Invalid group name")
 		return false
 	}
-	if !fn.IsString() {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "BindTaskParam(), Invalid function name")
+	if !isString(fn) {
+		g.logError("BindTaskParam(), Invalid function name")
 		return false
 	}
-	if _, ok := g.tasks[group.String()]; !ok {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "BindTaskParam(), group not exist")
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ts, ok := g.tasks[group.String()]
+	if !ok {
+		g.logError("BindTaskParam(), group not exist")
 		return false
 	}
-	ts := g.tasks[group.String()]
 	for i := 0; i < len(ts); i++ {
 		t := &ts[i]
 		if t.fn.String() == fn.String() {
@@ -137,26 +222,29 @@ func (g *Global) BindTaskParam(group otto.Value, fn otto.Value, args ...interfac
 			return true
 		}
 	}
-	g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "BindTaskParam(), function not exist")
+	g.logError("BindTaskParam(), function not exist")
 	return false
 }
 
 // ExecTasks ...
-func (g *Global) ExecTasks(group otto.Value) (results []interface{}) {
-	if !group.IsString() {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "ExecTasks(), Invalid group name")
+func (g *Global) ExecTasks(group goja.Value) (results []interface{}) {
+	if !isString(group) {
+		g.logError("ExecTasks(), Invalid group name")
 		return
 	}
-	if _, ok := g.tasks[group.String()]; !ok {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "ExecTasks(), group not exist")
+	g.mu.Lock()
+	ts, ok := g.tasks[group.String()]
+	ts = append([]task{}, ts...)
+	g.mu.Unlock()
+	if !ok {
+		g.logError("ExecTasks(), group not exist")
 		return
 	}
-	if g.running {
-		g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "ExecTasks(), tasks are running")
+	if !atomic.CompareAndSwapInt32(&g.running, 0, 1) {
+		g.logError("ExecTasks(), tasks are running")
 		return
 	}
-	g.running = true
-	ts := g.tasks[group.String()]
+	defer atomic.StoreInt32(&g.running, 0)
 	for range ts {
 		results = append(results, false)
 	}
@@ -164,20 +252,27 @@ func (g *Global) ExecTasks(group otto.Value) (results []interface{}) {
 	for i, t := range ts {
 		wg.Add(1)
 		go func(i int, t task) {
-			if f, err := t.ctx.Get(t.fn.String()); err != nil || !f.IsFunction() {
-				g.Logger.Log(constant.ERROR, "", 0.0, 0.0, "Can not get the task function")
-			} else {
-				result, err := f.Call(f, t.args...)
-				if err != nil || result.IsUndefined() || result.IsNull() {
-					results[i] = false
-				} else {
-					results[i] = result
+			defer wg.Done()
+			defer func() {
+				if err := recover(); err != nil {
+					g.logError(err)
 				}
+			}()
+			f := t.ctx.Get(t.fn.String())
+			if !isFunction(f) {
+				g.logError("Can not get the task function")
+				return
+			}
+			result, err := callJS(t.ctx, f, t.args...)
+			// 策略停止时任务被中断
+			if err != nil && !isHalt(err) {
+				g.logError(err)
+			}
+			if err == nil && !goja.IsUndefined(result) && !goja.IsNull(result) {
+				results[i] = result.Export()
 			}
-			wg.Done()
 		}(i, t)
 	}
 	wg.Wait()
-	g.running = false
 	return
 }
@@ -0,0 +1,441 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/phonegapX/QuantBot/api"
+)
+
+// streamer the state of a streaming indicator, next updates it by a closed bar in O(1)
+// and returns the value, nil before there are enough bars, the state is saved as a json
+type streamer interface {
+	next(r api.Record) interface{}
+	clone() streamer
+	params() []float64
+}
+
+// streamers the constructors and the default parameters of the streaming indicators,
+// they are named like the indicators of Talib
+var streamers = map[string]struct {
+	defaults []param
+	create   func(p []float64) streamer
+}{
+	"Sma":    {[]param{period(30)}, func(p []float64) streamer { return &smaStream{Period: int(p[0])} }},
+	"Ema":    {[]param{period(30)}, func(p []float64) streamer { return &emaStream{Period: int(p[0])} }},
+	"Rsi":    {[]param{period(14)}, func(p []float64) streamer { return &rsiStream{Period: int(p[0])} }},
+	"Atr":    {[]param{period(14)}, func(p []float64) streamer { return &atrStream{Period: int(p[0])} }},
+	"BBands": {[]param{period(20), factor(2)}, func(p []float64) streamer { return &bbandsStream{Period: int(p[0]), Dev: p[1]} }},
+	"Macd": {[]param{period(12), period(26), period(9)}, func(p []float64) streamer {
+		return &macdStream{
+			Fast:   emaStream{Period: int(p[0])},
+			Slow:   emaStream{Period: int(p[1])},
+			Signal: emaStream{Period: int(p[2])},
+		}
+	}},
+}
+
+// smaStream simple moving average of the close prices
+type smaStream struct {
+	Period int       `json:"period"`
+	Window []float64 `json:"window"`
+	Sum    float64   `json:"sum"`
+}
+
+func (s *smaStream) add(v float64) (float64, bool) {
+	s.Window = append(s.Window, v)
+	s.Sum += v
+	if len(s.Window) > s.Period {
+		s.Sum -= s.Window[0]
+		s.Window = s.Window[1:]
+	}
+	return s.Sum / float64(s.Period), len(s.Window) == s.Period
+}
+
+func (s *smaStream) next(r api.Record) interface{} {
+	if v, ok := s.add(r.Close); ok {
+		return v
+	}
+	return nil
+}
+
+func (s *smaStream) clone() streamer {
+	c := *s
+	c.Window = append([]float64{}, s.Window...)
+	return &c
+}
+
+func (s *smaStream) params() []float64 {
+	return []float64{float64(s.Period)}
+}
+
+// emaStream exponential moving average of the close prices, it starts from the sma of the first period like TA-Lib
+type emaStream struct {
+	Period int     `json:"period"`
+	Count  int     `json:"count"`
+	Value  float64 `json:"value"`
+}
+
+func (s *emaStream) add(v float64) (float64, bool) {
+	s.Count++
+	switch {
+	case s.Count < s.Period:
+		s.Value += v
+	case s.Count == s.Period:
+		s.Value = (s.Value + v) / float64(s.Period)
+	default:
+		k := 2 / float64(s.Period+1)
+		s.Value = (v-s.Value)*k + s.Value
+	}
+	return s.Value, s.Count >= s.Period
+}
+
+func (s *emaStream) next(r api.Record) interface{} {
+	if v, ok := s.add(r.Close); ok {
+		return v
+	}
+	return nil
+}
+
+func (s *emaStream) clone() streamer {
+	c := *s
+	return &c
+}
+
+func (s *emaStream) params() []float64 {
+	return []float64{float64(s.Period)}
+}
+
+// rsiStream relative strength index with the wilder smoothing like TA-Lib
+type rsiStream struct {
+	Period  int     `json:"period"`
+	Count   int     `json:"count"`
+	Prev    float64 `json:"prev"`
+	AvgGain float64 `json:"avgGain"`
+	AvgLoss float64 `json:"avgLoss"`
+}
+
+func (s *rsiStream) next(r api.Record) interface{} {
+	s.Count++
+	prev := s.Prev
+	s.Prev = r.Close
+	if s.Count == 1 {
+		return nil
+	}
+	gain, loss := math.Max(r.Close-prev, 0), math.Max(prev-r.Close, 0)
+	n := float64(s.Period)
+	switch {
+	case s.Count <= s.Period:
+		s.AvgGain += gain
+		s.AvgLoss += loss
+		return nil
+	case s.Count == s.Period+1:
+		s.AvgGain = (s.AvgGain + gain) / n
+		s.AvgLoss = (s.AvgLoss + loss) / n
+	default:
+		s.AvgGain = (s.AvgGain*(n-1) + gain) / n
+		s.AvgLoss = (s.AvgLoss*(n-1) + loss) / n
+	}
+	if s.AvgGain+s.AvgLoss == 0 {
+		return 0.0
+	}
+	return 100 * s.AvgGain / (s.AvgGain + s.AvgLoss)
+}
+
+func (s *rsiStream) clone() streamer {
+	c := *s
+	return &c
+}
+
+func (s *rsiStream) params() []float64 {
+	return []float64{float64(s.Period)}
+}
+
+// atrStream average true range with the wilder smoothing like TA-Lib
+type atrStream struct {
+	Period    int     `json:"period"`
+	Count     int     `json:"count"`
+	PrevClose float64 `json:"prevClose"`
+	Value     float64 `json:"value"`
+}
+
+func (s *atrStream) next(r api.Record) interface{} {
+	s.Count++
+	prev := s.PrevClose
+	s.PrevClose = r.Close
+	if s.Count == 1 {
+		return nil
+	}
+	tr := math.Max(r.High-r.Low, math.Max(math.Abs(r.High-prev), math.Abs(r.Low-prev)))
+	n := float64(s.Period)
+	switch {
+	case s.Count <= s.Period:
+		s.Value += tr
+		return nil
+	case s.Count == s.Period+1:
+		s.Value = (s.Value + tr) / n
+	default:
+		s.Value = (s.Value*(n-1) + tr) / n
+	}
+	return s.Value
+}
+
+func (s *atrStream) clone() streamer {
+	c := *s
+	return &c
+}
+
+func (s *atrStream) params() []float64 {
+	return []float64{float64(s.Period)}
+}
+
+// macdStream moving average convergence/divergence {macd, signal, hist}
+type macdStream struct {
+	Fast   emaStream `json:"fast"`
+	Slow   emaStream `json:"slow"`
+	Signal emaStream `json:"signal"`
+}
+
+func (s *macdStream) next(r api.Record) interface{} {
+	fast, _ := s.Fast.add(r.Close)
+	slow, ok := s.Slow.add(r.Close)
+	if !ok {
+		return nil
+	}
+	macd := fast - slow
+	signal, ok := s.Signal.add(macd)
+	if !ok {
+		return nil
+	}
+	return map[string]float64{"macd": macd, "signal": signal, "hist": macd - signal}
+}
+
+func (s *macdStream) clone() streamer {
+	c := *s
+	return &c
+}
+
+func (s *macdStream) params() []float64 {
+	return []float64{float64(s.Fast.Period), float64(s.Slow.Period), float64(s.Signal.Period)}
+}
+
+// bbandsStream bollinger bands {upper, middle, lower}
+type bbandsStream struct {
+	Period int       `json:"period"`
+	Dev    float64   `json:"dev"`
+	Window []float64 `json:"window"`
+	Sum    float64   `json:"sum"`
+	SumSq  float64   `json:"sumSq"`
+}
+
+func (s *bbandsStream) next(r api.Record) interface{} {
+	s.Window = append(s.Window, r.Close)
+	s.Sum += r.Close
+	s.SumSq += r.Close * r.Close
+	if len(s.Window) > s.Period {
+		s.Sum -= s.Window[0]
+		s.SumSq -= s.Window[0] * s.Window[0]
+		s.Window = s.Window[1:]
+	}
+	if len(s.Window) < s.Period {
+		return nil
+	}
+	n := float64(s.Period)
+	middle := s.Sum / n
+	dev := s.Dev * math.Sqrt(math.Max(s.SumSq/n-middle*middle, 0))
+	return map[string]float64{"upper": middle + dev, "middle": middle, "lower": middle - dev}
+}
+
+func (s *bbandsStream) clone() streamer {
+	c := *s
+	c.Window = append([]float64{}, s.Window...)
+	return &c
+}
+
+func (s *bbandsStream) params() []float64 {
+	return []float64{float64(s.Period), s.Dev}
+}
+
+// indicator a streaming indicator, current is base updated by the forming bar,
+// so that the forming bar can be replaced by an update with the same time
+type indicator struct {
+	typ     string
+	params  []float64
+	base    streamer //最后一根走完的K线之后的状态
+	current streamer //包括未完成的K线的状态
+	time    int64    //未完成的K线的时间
+	count   int64    //已经更新的K线数量
+	value   interface{}
+}
+
+// indicatorState the json of an indicator
+type indicatorState struct {
+	Type    string          `json:"type"`
+	Params  []float64       `json:"params"`
+	Time    int64           `json:"time"`
+	Count   int64           `json:"count"`
+	Value   interface{}     `json:"value"`
+	Base    json.RawMessage `json:"base"`
+	Current json.RawMessage `json:"current"`
+}
+
+func newIndicator(typ string, args []interface{}) (*indicator, error) {
+	s, ok := streamers[typ]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized indicator: %v", typ)
+	}
+	// 周期小于 1 时结果是 NaN, state() 无法保存
+	p, _, err := params(args, s.defaults...)
+	if err != nil {
+		return nil, fmt.Errorf("%v(), %v", typ, err)
+	}
+	base := s.create(p)
+	return &indicator{typ: typ, params: p, base: base, current: base.clone()}, nil
+}
+
+// update update by a record or a number, a record with the time of the forming bar replaces it,
+// a record with a later time closes the forming bar, a number is always a new bar
+func (in *indicator) update(v interface{}) (interface{}, error) {
+	r, ok := toRecord(v)
+	if !ok {
+		f, ok := toFloat(v)
+		if !ok {
+			return nil, fmt.Errorf("the data should be a record or a number")
+		}
+		r = api.Record{Time: in.time + 1, Open: f, High: f, Low: f, Close: f}
+	}
+	switch {
+	case in.count > 0 && r.Time == in.time:
+		in.current = in.base.clone()
+	case in.count == 0 || r.Time > in.time:
+		if in.count > 0 {
+			in.base = in.current
+			in.current = in.base.clone()
+		}
+		in.count++
+	default:
+		return nil, fmt.Errorf("the time %v is earlier than the forming bar %v", r.Time, in.time)
+	}
+	in.time = r.Time
+	in.value = in.current.next(r)
+	return in.value, nil
+}
+
+// state get the json of the indicator
+func (in *indicator) state() ([]byte, error) {
+	base, err := json.Marshal(in.base)
+	if err != nil {
+		return nil, err
+	}
+	current, err := json.Marshal(in.current)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(indicatorState{Type: in.typ, Params: in.params, Time: in.time, Count: in.count, Value: in.value, Base: base, Current: current})
+}
+
+// restoreIndicator create an indicator from its json
+func restoreIndicator(data []byte) (*indicator, error) {
+	state := indicatorState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	s, ok := streamers[state.Type]
+	if !ok || len(state.Params) != len(s.defaults) {
+		return nil, fmt.Errorf("invalid indicator state")
+	}
+	for i, p := range state.Params {
+		if err := s.defaults[i].check(p); err != nil {
+			return nil, fmt.Errorf("invalid indicator state, the parameter %v: %v", i+1, err)
+		}
+	}
+	in := &indicator{typ: state.Type, params: state.Params, time: state.Time, count: state.Count, value: state.Value}
+	in.base, in.current = s.create(state.Params), s.create(state.Params)
+	if err := json.Unmarshal(state.Base, in.base); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(state.Current, in.current); err != nil {
+		return nil, err
+	}
+	// 保存的状态中也有周期等参数, 必须和校验过的参数一致
+	for _, st := range []streamer{in.base, in.current} {
+		for i, p := range st.params() {
+			if p != state.Params[i] {
+				return nil, fmt.Errorf("invalid indicator state, the parameter %v of the state is %v, want %v", i+1, p, state.Params[i])
+			}
+		}
+	}
+	return in, nil
+}
+
+// object wrap the indicator as a js object with the methods update, value, state and toJSON
+func (in *indicator) object(vm *goja.Runtime) goja.Value {
+	obj := vm.NewObject()
+	obj.Set("update", func(call goja.FunctionCall) goja.Value {
+		value, err := in.update(call.Argument(0).Export())
+		if err != nil {
+			throw(vm, "IndicatorError", in.typ+".update(), "+err.Error())
+		}
+		return jsValue(vm, value)
+	})
+	obj.Set("value", func(call goja.FunctionCall) goja.Value {
+		return jsValue(vm, in.value)
+	})
+	state := func(call goja.FunctionCall) goja.Value {
+		data, err := in.state()
+		if err != nil {
+			throw(vm, "IndicatorError", in.typ+".state(), "+err.Error())
+		}
+		v, _ := vm.RunString("(" + string(data) + ")")
+		return v
+	}
+	obj.Set("state", state)
+	obj.Set("toJSON", state)
+	return obj
+}
+
+// jsValue convert the value of an indicator, null if it is not ready
+func jsValue(vm *goja.Runtime, v interface{}) goja.Value {
+	if v == nil {
+		return goja.Null()
+	}
+	return vm.ToValue(v)
+}
+
+// newIndicators create the js object Indicators, eg: Indicators.Ema(20), Indicators.restore(state)
+func newIndicators(vm *goja.Runtime) goja.Value {
+	obj := vm.NewObject()
+	for typ := range streamers {
+		typ := typ
+		obj.Set(typ, func(call goja.FunctionCall) goja.Value {
+			args := []interface{}{}
+			for _, arg := range call.Arguments {
+				args = append(args, arg.Export())
+			}
+			in, err := newIndicator(typ, args)
+			if err != nil {
+				throw(vm, "IndicatorError", err.Error())
+			}
+			return in.object(vm)
+		})
+	}
+	obj.Set("restore", func(call goja.FunctionCall) goja.Value {
+		v := call.Argument(0).Export()
+		if s, ok := v.(string); ok {
+			v = json.RawMessage(strings.TrimSpace(s))
+		}
+		data, err := json.Marshal(v)
+		if err == nil {
+			var in *indicator
+			if in, err = restoreIndicator(data); err == nil {
+				return in.object(vm)
+			}
+		}
+		throw(vm, "IndicatorError", "restore(), "+err.Error())
+		return nil
+	})
+	return obj
+}
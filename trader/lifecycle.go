@@ -0,0 +1,82 @@
+package trader
+
+import (
+	"context"
+	"time"
+)
+
+// contextual an exchange whose requests can be aborted by a context
+type contextual interface {
+	SetContext(ctx context.Context)
+}
+
+// setContext replace the context of the trader and its exchanges
+func (g *Global) setContext(ctx context.Context, cancel context.CancelFunc) {
+	g.mu.Lock()
+	g.runCtx, g.cancel = ctx, cancel
+	g.mu.Unlock()
+	for _, e := range g.es {
+		if c, ok := e.(contextual); ok {
+			c.SetContext(ctx)
+		}
+	}
+}
+
+// runContext get the context of the trader
+func (g *Global) runContext() context.Context {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.runCtx == nil {
+		return context.Background()
+	}
+	return g.runCtx
+}
+
+// cancelContext cancel the context of the trader, G.Sleep returns and the requests in flight are aborted
+func (g *Global) cancelContext() {
+	g.mu.Lock()
+	cancel := g.cancel
+	g.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// interrupt halt the js runtime and the running tasks before their next statements, it never blocks
+func (g *Global) interrupt() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.ctx.Interrupt(errHalt)
+	for _, ts := range g.tasks {
+		for _, t := range ts {
+			t.ctx.Interrupt(errHalt)
+		}
+	}
+}
+
+// exit call the exit function of the script with a new context, it is interrupted after exitTimeout
+func (g *Global) exit() {
+	ctx, cancel := context.WithTimeout(context.Background(), exitTimeout)
+	defer cancel()
+	g.setContext(ctx, cancel)
+	// exit() 中调用 G.Sleep 时不再运行定时器
+	g.timers.clear()
+	// 丢弃停止时没有被处理的中断, 否则 exit() 一开始就会被中断
+	g.ctx.ClearInterrupt()
+	exit := g.ctx.Get("exit")
+	if !isFunction(exit) {
+		return
+	}
+	timer := time.AfterFunc(exitTimeout, g.interrupt)
+	defer timer.Stop()
+	defer func() {
+		if err := recover(); err != nil {
+			g.logError(err)
+		}
+	}()
+	if _, err := callJS(g.ctx, exit); isHalt(err) {
+		g.logError("exit() did not return in ", exitTimeout)
+	} else if err != nil {
+		g.logError(err)
+	}
+}
@@ -0,0 +1,17 @@
+package trader
+
+import (
+	"os"
+	"testing"
+
+	"github.com/phonegapX/QuantBot/config"
+	"github.com/phonegapX/QuantBot/model"
+)
+
+func TestMain(m *testing.M) {
+	// 测试使用内存数据库, 不读写 custom/data.db
+	config.Set("dbtype", "SQLite3")
+	config.Set("dburl", "file::memory:?cache=shared")
+	model.Open()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,83 @@
+package trader
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/phonegapX/QuantBot/model"
+)
+
+// modules load the libraries required by a trader
+type modules struct {
+	userID int64
+}
+
+// vmModules the modules of a js runtime, it is kept by the require function of the runtime and freed with it,
+// so that a task running in another runtime does not share the values of this runtime
+type vmModules struct {
+	caches  map[string]goja.Value //已经加载的模块, name@version -> module.exports
+	loading []string              //正在加载的模块, 用于检测循环依赖
+}
+
+func newModules(userID int64) *modules {
+	return &modules{userID: userID}
+}
+
+// requirer get the require function of a js runtime
+func (m *modules) requirer(vm *goja.Runtime) func(call goja.FunctionCall) goja.Value {
+	vms := &vmModules{caches: make(map[string]goja.Value)}
+	return func(call goja.FunctionCall) goja.Value {
+		return m.require(vm, vms, call.Argument(0).String())
+	}
+}
+
+// require load a library like require("name") or require("name@version"), the library runs in its own function scope
+// with the variables module, exports and require, and module.exports is returned
+func (m *modules) require(vm *goja.Runtime, vms *vmModules, spec string) goja.Value {
+	spec = strings.TrimSpace(spec)
+	name, version := spec, ""
+	if i := strings.Index(spec, "@"); i >= 0 {
+		name, version = spec[:i], spec[i+1:]
+	}
+	library, err := model.FindLibrary(m.userID, name, version)
+	if err != nil {
+		throw(vm, "RequireError", err.Error())
+	}
+	key := library.Name + "@" + library.Version
+	if exports, ok := vms.caches[key]; ok {
+		return exports
+	}
+	for i, k := range vms.loading {
+		if k == key {
+			throw(vm, "RequireError", "Circular require: "+strings.Join(append(vms.loading[i:], key), " -> "))
+		}
+	}
+	vms.loading = append(vms.loading, key)
+	defer func() {
+		vms.loading = vms.loading[:len(vms.loading)-1]
+	}()
+
+	exports, err := m.load(vm, library)
+	if err != nil {
+		throw(vm, "RequireError", fmt.Sprintf("Load the library %v error: %v", key, err))
+	}
+	vms.caches[key] = exports
+	return exports
+}
+
+// load run the script of a library and get its module.exports
+func (m *modules) load(vm *goja.Runtime, library model.Algorithm) (exports goja.Value, err error) {
+	fn, err := vm.RunString("(function(module, exports, require) {\n" + library.Script + "\n})")
+	if err != nil {
+		return nil, jsError(err)
+	}
+	module := vm.NewObject()
+	if err = module.Set("exports", vm.NewObject()); err != nil {
+		return
+	}
+	if _, err = callJS(vm, fn, module, module.Get("exports"), vm.Get("require")); err != nil {
+		return
+	}
+	return module.Get("exports"), nil
+}
@@ -5,16 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/robertkrimen/otto/registry"
 )
 
-var (
-	scripts = []string{}
-	entry   = registry.Register(func() string {
-		return strings.Join(scripts, "")
-	})
-)
+// scripts the js files in the plugin directory, they are loaded into every js runtime by newRuntime
+var scripts = []string{}
 
 func init() {
 	filepath.Walk("plugin", func(path string, info os.FileInfo, err error) error {
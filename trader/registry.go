@@ -0,0 +1,145 @@
+package trader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/phonegapX/QuantBot/constant"
+)
+
+// StateChange a transition of the lifecycle state of a trader
+type StateChange struct {
+	TraderID int64     `json:"traderId"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Time     time.Time `json:"time"`
+}
+
+// registryEntry the state and the runtime of a trader
+type registryEntry struct {
+	state   string
+	runtime *Global //最后一次运行的运行时, 停止后保留, 用于查询状态
+}
+
+// stateRegistry keep the lifecycle states and the runtimes of the traders, it is safe for concurrent use
+type stateRegistry struct {
+	mu      sync.RWMutex
+	entries map[int64]*registryEntry
+	subs    map[int]chan StateChange
+	nextSub int
+}
+
+var traderRegistry = &stateRegistry{
+	entries: make(map[int64]*registryEntry),
+	subs:    make(map[int]chan StateChange),
+}
+
+// state get the state of a trader, constant.TraderIdle if it never ran
+func (r *stateRegistry) state(id int64) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.entries[id]; ok {
+		return e.state
+	}
+	return constant.TraderIdle
+}
+
+// runtime get the last runtime of a trader, nil if it never ran
+func (r *stateRegistry) runtime(id int64) *Global {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.entries[id]; ok {
+		return e.runtime
+	}
+	return nil
+}
+
+// transition change the state of a trader to the state to if its state is one of from,
+// if g is not nil, the runtime of the trader must be g, so that a stale runtime can not change the state
+func (r *stateRegistry) transition(id int64, g *Global, to string, from ...string) (prev string, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, exists := r.entries[id]
+	if !exists {
+		e = &registryEntry{state: constant.TraderIdle}
+	}
+	prev = e.state
+	if g != nil && e.runtime != g {
+		return
+	}
+	for _, f := range from {
+		if f == prev {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return
+	}
+	e.state = to
+	r.entries[id] = e
+	r.publish(StateChange{TraderID: id, From: prev, To: to, Time: time.Now()})
+	return
+}
+
+// setRuntime replace the runtime of a starting trader
+func (r *stateRegistry) setRuntime(id int64, g *Global) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.entries[id]; ok {
+		e.runtime = g
+	}
+}
+
+// publish send a change to the subscribers without blocking, the caller must hold the lock
+func (r *stateRegistry) publish(change StateChange) {
+	for _, ch := range r.subs {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
+
+// Subscribe receive the state changes of all traders, the changes are dropped if the channel is full,
+// call the returned function to unsubscribe
+func Subscribe(buffer int) (<-chan StateChange, func()) {
+	return traderRegistry.subscribe(buffer)
+}
+
+// subscribe receive the state changes of the registry
+func (r *stateRegistry) subscribe(buffer int) (<-chan StateChange, func()) {
+	ch := make(chan StateChange, buffer)
+	r.mu.Lock()
+	id := r.nextSub
+	r.nextSub++
+	r.subs[id] = ch
+	r.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			r.mu.Lock()
+			delete(r.subs, id)
+			r.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// GetTraderState get the lifecycle state of a trader
+func GetTraderState(id int64) string {
+	return traderRegistry.state(id)
+}
+
+// statusOf convert a state to the status shown in the trader list, 0 stopped, 1 running, 2 stopping or 3 restarting
+func statusOf(state string) int64 {
+	switch state {
+	case constant.TraderStarting, constant.TraderRunning:
+		return 1
+	case constant.TraderStopping:
+		return 2
+	case constant.TraderRestarting:
+		return 3
+	}
+	return 0
+}
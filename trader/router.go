@@ -0,0 +1,205 @@
+package trader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miaolz123/conver"
+	"github.com/phonegapX/QuantBot/api"
+	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/symbol"
+)
+
+// AggregatedOrderBook an order book level of a bound exchange
+type AggregatedOrderBook struct {
+	Exchange string  //交易所名称
+	Index    int     //交易所在 Es 中的序号, 名称可能重复
+	Price    float64 //价格
+	Amount   float64 //市场深度量
+}
+
+// AggregatedTicker the order books of all the bound exchanges merged by price
+type AggregatedTicker struct {
+	Bids         []AggregatedOrderBook //买单市场深度列表,价格从高到低
+	Buy          float64               //最高买一价
+	BuyExchange  string                //最高买一价所在的交易所
+	BuyIndex     int                   //最高买一价所在的交易所在 Es 中的序号
+	Mid          float64               //(Buy + Sell) / 2
+	Sell         float64               //最低卖一价
+	SellExchange string                //最低卖一价所在的交易所
+	SellIndex    int                   //最低卖一价所在的交易所在 Es 中的序号
+	Asks         []AggregatedOrderBook //卖单市场深度列表,价格从低到高
+}
+
+// ChildOrder an order placed on a bound exchange by G.RouteOrder
+type ChildOrder struct {
+	Exchange string  //交易所名称
+	Index    int     //交易所在 Es 中的序号
+	ID       string  //订单ID, 下单失败时为空
+	Price    float64 //委托价格, 即成交所需的最差价格
+	Amount   float64 //委托数量
+	AvgPrice float64 //按市场深度估算的平均成交价
+	Error    string  //下单失败的原因
+}
+
+// RouteResult the result of G.RouteOrder
+type RouteResult struct {
+	TradeType string       //交易类型
+	StockType string       //货币类型
+	Amount    float64      //母单数量
+	Routed    float64      //已拆分到各个交易所的数量
+	Children  []ChildOrder //各个交易所的子订单
+}
+
+// aggregate get the depths of all the bound exchanges concurrently and merge them by price
+func (g *Global) aggregate(stockType string) (ticker AggregatedTicker, err error) {
+	depths := make([]interface{}, len(g.es))
+	wg := sync.WaitGroup{}
+	for i, e := range g.es {
+		wg.Add(1)
+		go func(i int, e api.Exchange) {
+			defer wg.Done()
+			depths[i] = e.GetDepth(stockType)
+		}(i, e)
+	}
+	wg.Wait()
+	for i, d := range depths {
+		depth, ok := d.(api.Depth)
+		if !ok {
+			continue
+		}
+		name := g.es[i].GetName()
+		for _, b := range depth.Bids {
+			ticker.Bids = append(ticker.Bids, AggregatedOrderBook{Exchange: name, Index: i, Price: b.Price, Amount: b.Amount})
+		}
+		for _, a := range depth.Asks {
+			ticker.Asks = append(ticker.Asks, AggregatedOrderBook{Exchange: name, Index: i, Price: a.Price, Amount: a.Amount})
+		}
+	}
+	if len(ticker.Bids) < 1 || len(ticker.Asks) < 1 {
+		err = fmt.Errorf("can not get enough Bids or Asks of %v", stockType)
+		return
+	}
+	sort.SliceStable(ticker.Bids, func(i, j int) bool { return ticker.Bids[i].Price > ticker.Bids[j].Price })
+	sort.SliceStable(ticker.Asks, func(i, j int) bool { return ticker.Asks[i].Price < ticker.Asks[j].Price })
+	ticker.Buy, ticker.BuyExchange, ticker.BuyIndex = ticker.Bids[0].Price, ticker.Bids[0].Exchange, ticker.Bids[0].Index
+	ticker.Sell, ticker.SellExchange, ticker.SellIndex = ticker.Asks[0].Price, ticker.Asks[0].Exchange, ticker.Asks[0].Index
+	ticker.Mid = (ticker.Buy + ticker.Sell) / 2
+	return
+}
+
+// AggregatedTicker ...
+func (g *Global) AggregatedTicker(stockType string) interface{} {
+	ticker, err := g.aggregate(symbol.Normalize(stockType))
+	if err != nil {
+		g.logError("AggregatedTicker(), ", err)
+		return false
+	}
+	return ticker
+}
+
+// RouteOrder split a parent order across the bound exchanges by the best price and the available balance,
+// a BUY order takes the Asks whose price <= limitPrice and a SELL order takes the Bids whose price >= limitPrice,
+// limitPrice <= 0 means no limit
+func (g *Global) RouteOrder(tradeType, stockType string, _amount, _limitPrice interface{}) interface{} {
+	tradeType = strings.ToUpper(tradeType)
+	stockType = symbol.Normalize(stockType)
+	amount := conver.Float64Must(_amount)
+	limitPrice := conver.Float64Must(_limitPrice)
+	if tradeType != constant.TradeTypeBuy && tradeType != constant.TradeTypeSell {
+		g.logError("RouteOrder(), unrecognized tradeType: ", tradeType)
+		return false
+	}
+	if amount <= 0 {
+		g.logError("RouteOrder(), invalid amount: ", amount)
+		return false
+	}
+	ticker, err := g.aggregate(stockType)
+	if err != nil {
+		g.logError("RouteOrder(), ", err)
+		return false
+	}
+	// 买单消耗计价货币, 卖单消耗基础货币, 不能查询余额的交易所不限制
+	sym, err := symbol.Parse(stockType)
+	if err != nil {
+		g.logError("RouteOrder(), ", err)
+		return false
+	}
+	currency := sym.Base
+	books := ticker.Bids
+	if tradeType == constant.TradeTypeBuy {
+		currency = sym.Quote
+		books = ticker.Asks
+	}
+	// 交易所的名称可能重复, 按在 Es 中的序号区分
+	budgets := make([]float64, len(g.es))
+	for i, e := range g.es {
+		budgets[i] = -1.0
+		if available, ok, err := api.GetAvailable(e, currency); ok && err == nil {
+			budgets[i] = available
+		}
+	}
+	result := RouteResult{TradeType: tradeType, StockType: stockType, Amount: amount}
+	children := map[int]*ChildOrder{}
+	indexes := []int{}
+	left := amount
+	for _, b := range books {
+		if left <= 0 {
+			break
+		}
+		if limitPrice > 0 && ((tradeType == constant.TradeTypeBuy && b.Price > limitPrice) || (tradeType == constant.TradeTypeSell && b.Price < limitPrice)) {
+			break
+		}
+		fill := b.Amount
+		if fill > left {
+			fill = left
+		}
+		if budget := budgets[b.Index]; budget >= 0 {
+			capacity := budget
+			if tradeType == constant.TradeTypeBuy {
+				capacity = budget / b.Price
+			}
+			if fill > capacity {
+				fill = capacity
+			}
+		}
+		if fill <= 0 {
+			continue
+		}
+		child, ok := children[b.Index]
+		if !ok {
+			child = &ChildOrder{Exchange: b.Exchange, Index: b.Index}
+			children[b.Index] = child
+			indexes = append(indexes, b.Index)
+		}
+		child.AvgPrice = (child.AvgPrice*child.Amount + b.Price*fill) / (child.Amount + fill)
+		child.Amount += fill
+		child.Price = b.Price
+		if budget := budgets[b.Index]; budget >= 0 {
+			if tradeType == constant.TradeTypeBuy {
+				budgets[b.Index] = budget - fill*b.Price
+			} else {
+				budgets[b.Index] = budget - fill
+			}
+		}
+		left -= fill
+	}
+	for _, i := range indexes {
+		child := children[i]
+		e := g.es[i]
+		if min := e.GetMinAmount(stockType); child.Amount < min {
+			child.Error = fmt.Sprintf("amount %v is less than the min amount %v", child.Amount, min)
+		} else if id, ok := e.Trade(tradeType, stockType, child.Price, child.Amount, "RouteOrder").(string); ok {
+			child.ID = id
+			result.Routed += child.Amount
+		} else if err, ok := e.GetLastError().(api.Error); ok {
+			child.Error = err.Error()
+		} else {
+			child.Error = "trade failed"
+		}
+		result.Children = append(result.Children, *child)
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// the js runtime is goja, these helpers keep the semantics of the bindings which were written for otto:
+// the Go names of the fields and the methods are used as they are, an exception is reported by its value
+// without the stack like otto, and a stopped trader is interrupted by errHalt
+
+// newRuntime create a js runtime with the plugins loaded
+func newRuntime() (*goja.Runtime, error) {
+	vm := goja.New()
+	for _, script := range scripts {
+		if _, err := vm.RunString(script); err != nil {
+			return nil, fmt.Errorf("Load the plugins error: %v", jsError(err))
+		}
+	}
+	return vm, nil
+}
+
+// isFunction check if a value is a js function
+func isFunction(v goja.Value) bool {
+	_, ok := goja.AssertFunction(v)
+	return ok
+}
+
+// isString check if a value is a js string
+func isString(v goja.Value) bool {
+	if v == nil {
+		return false
+	}
+	_, ok := v.Export().(string)
+	return ok
+}
+
+// isHalt check if an error is the interrupt of a stopped trader
+func isHalt(err error) bool {
+	var interrupted *goja.InterruptedError
+	return errors.As(err, &interrupted) && interrupted.Value() == errHalt
+}
+
+// jsError convert an exception to an error of its value, the other errors are kept
+func jsError(err error) error {
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return errors.New(exception.Value().String())
+	}
+	return err
+}
+
+// callJS call a js function with Go values, the interrupt is kept if the function is interrupted,
+// so that the js code which calls the Go code calling the function is interrupted too
+func callJS(vm *goja.Runtime, fn goja.Value, args ...interface{}) (goja.Value, error) {
+	f, ok := goja.AssertFunction(fn)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a function", fn)
+	}
+	values := make([]goja.Value, len(args))
+	for i, arg := range args {
+		values[i] = vm.ToValue(arg)
+	}
+	result, err := f(goja.Undefined(), values...)
+	if isHalt(err) {
+		vm.Interrupt(errHalt)
+	} else if err != nil {
+		err = jsError(err)
+	}
+	return result, err
+}
+
+// throw throw a js error with a name, eg: RequireError
+func throw(vm *goja.Runtime, name, msg string) {
+	e, err := vm.New(vm.Get("Error"), vm.ToValue(msg))
+	if err != nil {
+		panic(vm.NewGoError(errors.New(msg)))
+	}
+	e.Set("name", name)
+	panic(e)
+}
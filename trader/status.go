@@ -0,0 +1,113 @@
+package trader
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/model"
+)
+
+// monitor keep the live status of a trader, it is safe for concurrent use
+type monitor struct {
+	mu          sync.RWMutex
+	format      string
+	content     string
+	updatedAt   time.Time
+	heartbeat   time.Time
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// setStatus replace the status buffer
+func (m *monitor) setStatus(format, content string) {
+	m.mu.Lock()
+	m.format, m.content, m.updatedAt = format, content, time.Now()
+	m.mu.Unlock()
+}
+
+// beat record the heartbeat of the main loop
+func (m *monitor) beat() {
+	m.mu.Lock()
+	m.heartbeat = time.Now()
+	m.mu.Unlock()
+}
+
+// setError record the last error
+func (m *monitor) setError(msg string) {
+	m.mu.Lock()
+	m.lastError, m.lastErrorAt = msg, time.Now()
+	m.mu.Unlock()
+}
+
+// fill copy the status to s
+func (m *monitor) fill(s *model.TraderStatus) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s.Format = m.format
+	s.Content = m.content
+	s.UpdatedAt = m.updatedAt
+	s.Heartbeat = m.heartbeat
+	s.LastError = m.lastError
+	s.LastErrorAt = m.lastErrorAt
+}
+
+// isTable check if the message is a table, eg: {type: 'table', title: 'Positions', cols: ['Symbol', 'Amount'], rows: [['BTC/USDT', 1]]}
+func isTable(m interface{}) bool {
+	table, ok := m.(map[string]interface{})
+	return ok && table["type"] == constant.StatusTable
+}
+
+// formatStatus format the messages of G.LogStatus,
+// tables are encoded as a json array, a single object or array is encoded as json, otherwise the messages are joined as text
+func formatStatus(msgs ...interface{}) (format, content string, err error) {
+	if len(msgs) == 0 {
+		return constant.StatusText, "", nil
+	}
+	tables := true
+	for _, m := range msgs {
+		if !isTable(m) {
+			tables = false
+			break
+		}
+	}
+	if tables {
+		bs, err := json.Marshal(msgs)
+		return constant.StatusTable, string(bs), err
+	}
+	if len(msgs) == 1 && isObject(msgs[0]) {
+		bs, err := json.Marshal(msgs[0])
+		return constant.StatusJSON, string(bs), err
+	}
+	for _, m := range msgs {
+		if isObject(m) {
+			bs, err := json.Marshal(m)
+			if err != nil {
+				return "", "", err
+			}
+			content += string(bs)
+			continue
+		}
+		content += fmt.Sprintf("%+v", m)
+	}
+	return constant.StatusText, content, nil
+}
+
+// isObject check if the message should be encoded as json
+func isObject(m interface{}) bool {
+	if m == nil {
+		return false
+	}
+	v := reflect.ValueOf(m)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package trader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/model"
+)
+
+var (
+	restartBackoff    = time.Second //第一次重启前的等待时间, 之后每次翻倍
+	maxRestartBackoff = time.Minute //重启前最长的等待时间
+	restartWindow     = time.Hour   //Trader.RestartWindow <= 0 时使用
+	supervisors       = make(map[int64]*supervisor)
+	supervisorsMu     sync.Mutex
+)
+
+// supervisor keep the restart history of a trader, it outlives the runtimes of the trader
+type supervisor struct {
+	mu       sync.Mutex
+	restarts []time.Time //时间窗口内的重启时间
+	timer    *time.Timer //等待中的重启
+}
+
+// getSupervisor get the supervisor of a trader
+func getSupervisor(id int64) *supervisor {
+	supervisorsMu.Lock()
+	defer supervisorsMu.Unlock()
+	s, ok := supervisors[id]
+	if !ok {
+		s = &supervisor{}
+		supervisors[id] = s
+	}
+	return s
+}
+
+// next get the delay before the next restart, false if the trader restarted too many times in the window
+func (s *supervisor) next(max int64, window time.Duration) (delay time.Duration, count int, ok bool) {
+	now := time.Now()
+	restarts := []time.Time{}
+	for _, t := range s.restarts {
+		if now.Sub(t) < window {
+			restarts = append(restarts, t)
+		}
+	}
+	s.restarts = restarts
+	if max > 0 && int64(len(restarts)) >= max {
+		return 0, len(restarts), false
+	}
+	delay = restartBackoff
+	for i := 0; i < len(restarts) && delay < maxRestartBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRestartBackoff {
+		delay = maxRestartBackoff
+	}
+	s.restarts = append(s.restarts, now)
+	return delay, len(s.restarts), true
+}
+
+// supervise restart a trader which exited by itself according to its restart policy,
+// reason is the error which crashed the trader, "" if main() returned, false if it will not be restarted
+func supervise(g *Global, reason string) bool {
+	switch g.RestartPolicy {
+	case constant.RestartAlways:
+		if reason == "" {
+			reason = "main() returned"
+		}
+	case constant.RestartOnFailure:
+		if reason == "" {
+			return false
+		}
+	default:
+		return false
+	}
+	window := restartWindow
+	if g.RestartWindow > 0 {
+		window = time.Duration(g.RestartWindow) * time.Second
+	}
+	s := getSupervisor(g.ID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delay, count, ok := s.next(g.MaxRestarts, window)
+	if !ok {
+		g.logError("The trader restarted ", count, " times in ", window, ", it will not be restarted, reason: ", reason)
+		return false
+	}
+	prev, ok := traderRegistry.transition(g.ID, g, constant.TraderRestarting, constant.TraderStopped, constant.TraderCrashed)
+	if !ok {
+		return false
+	}
+	g.Logger.Log(constant.INFO, "", 0.0, 0.0, "Restarting the trader in ", delay, " (restart ", count, "), reason: ", reason)
+	s.timer = time.AfterFunc(delay, func() {
+		// 等待期间被取消时状态已经不是 restarting
+		if _, ok := traderRegistry.transition(g.ID, g, prev, constant.TraderRestarting); !ok {
+			return
+		}
+		if err := run(g.ID); err != nil {
+			g.logError("Restart the trader error: ", err)
+			model.SetTraderRunning(g.ID, false)
+		}
+	})
+	return true
+}
+
+// cancelRestart cancel the pending restart of a trader, false if there is none
+func cancelRestart(id int64) bool {
+	if _, ok := traderRegistry.transition(id, nil, constant.TraderStopped, constant.TraderRestarting); !ok {
+		return false
+	}
+	s := getSupervisor(id)
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.mu.Unlock()
+	if g := traderRegistry.runtime(id); g != nil {
+		g.Logger.Log(constant.INFO, "", 0.0, 0.0, "The restart is canceled")
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package trader
+
+import (
+	"math"
+)
+
+// go-talib does not have the candlestick patterns, these are the common ones by their shapes,
+// the result is 100 for a bullish pattern, -100 for a bearish one and 0 otherwise like TA-Lib,
+// the trend before a pattern is left to the strategy
+
+// candle the shape of a bar
+type candle struct {
+	open, close, body, upper, lower, length float64
+}
+
+func newCandle(o, h, l, c float64) candle {
+	return candle{
+		open:   o,
+		close:  c,
+		body:   math.Abs(c - o),
+		upper:  h - math.Max(o, c),
+		lower:  math.Min(o, c) - l,
+		length: h - l,
+	}
+}
+
+// pattern run a pattern of n bars on every bar
+func (t Talib) pattern(name string, records interface{}, n int, fn func(cs []candle) int) interface{} {
+	return t.ohlcv(name, records, nil, nil, func(o, h, l, c, v, _ []float64) interface{} {
+		result := make([]int, len(c))
+		cs := make([]candle, len(c))
+		for i := range c {
+			cs[i] = newCandle(o[i], h[i], l[i], c[i])
+		}
+		for i := n - 1; i < len(c); i++ {
+			result[i] = fn(cs[i-n+1 : i+1])
+		}
+		return result
+	})
+}
+
+// CdlDoji the body is at most 10% of the length
+func (t Talib) CdlDoji(records interface{}) interface{} {
+	return t.pattern("CdlDoji", records, 1, func(cs []candle) int {
+		if c := cs[0]; c.length > 0 && c.body <= c.length*0.1 {
+			return 100
+		}
+		return 0
+	})
+}
+
+// CdlHammer the lower shadow is at least twice the body and the upper shadow is at most 10% of the length
+func (t Talib) CdlHammer(records interface{}) interface{} {
+	return t.pattern("CdlHammer", records, 1, func(cs []candle) int {
+		if c := cs[0]; c.body > 0 && c.lower >= c.body*2 && c.upper <= c.length*0.1 {
+			return 100
+		}
+		return 0
+	})
+}
+
+// CdlShootingStar the upper shadow is at least twice the body and the lower shadow is at most 10% of the length
+func (t Talib) CdlShootingStar(records interface{}) interface{} {
+	return t.pattern("CdlShootingStar", records, 1, func(cs []candle) int {
+		if c := cs[0]; c.body > 0 && c.upper >= c.body*2 && c.lower <= c.length*0.1 {
+			return -100
+		}
+		return 0
+	})
+}
+
+// CdlEngulfing the body of the bar engulfs the opposite body of the previous bar
+func (t Talib) CdlEngulfing(records interface{}) interface{} {
+	return t.pattern("CdlEngulfing", records, 2, func(cs []candle) int {
+		prev, c := cs[0], cs[1]
+		switch {
+		case prev.close < prev.open && c.close > c.open && c.open <= prev.close && c.close >= prev.open && c.body > prev.body:
+			return 100
+		case prev.close > prev.open && c.close < c.open && c.open >= prev.close && c.close <= prev.open && c.body > prev.body:
+			return -100
+		}
+		return 0
+	})
+}
+
+// CdlHarami the body of the bar is inside the opposite body of the previous bar
+func (t Talib) CdlHarami(records interface{}) interface{} {
+	return t.pattern("CdlHarami", records, 2, func(cs []candle) int {
+		prev, c := cs[0], cs[1]
+		inside := math.Max(c.open, c.close) < math.Max(prev.open, prev.close) && math.Min(c.open, c.close) > math.Min(prev.open, prev.close)
+		switch {
+		case inside && prev.close < prev.open && c.close > c.open:
+			return 100
+		case inside && prev.close > prev.open && c.close < c.open:
+			return -100
+		}
+		return 0
+	})
+}
@@ -1,33 +1,608 @@
 package trader
 
 import (
+	"fmt"
+	"math"
+	"strings"
+
 	"github.com/markcheno/go-talib"
+	"github.com/phonegapX/QuantBot/api"
 )
 
+// Talib the technical indicators of go-talib, an indicator of one series accepts a number array
+// or a record array with an optional field (Open, High, Low, Close or Volume, Close by default),
+// an indicator of the prices and the volumes only accepts a record array,
+// the optional parameters are numbers in order, a missing one uses the default value and an invalid one is an error,
+// the values in the lookback period are 0
 type Talib struct {
+	logError func(msgs ...interface{})
+}
+
+// fail log the error of an indicator and return false
+func (t Talib) fail(name string, msgs ...interface{}) interface{} {
+	if t.logError != nil {
+		t.logError(append([]interface{}{"Talib.", name, "(), "}, msgs...)...)
+	}
+	return false
+}
+
+// call run an indicator, a panic of go-talib (eg: the data is too short) is logged as an error
+func (t Talib) call(name string, fn func() interface{}) (result interface{}) {
+	defer func() {
+		if err := recover(); err != nil {
+			result = t.fail(name, err)
+		}
+	}()
+	return fn()
+}
+
+// series call an indicator of one series, p are the parameters with the defaults
+func (t Talib) series(name string, data interface{}, args []interface{}, defaults []param, fn func(in, p []float64) interface{}) interface{} {
+	p, field, err := params(args, defaults...)
+	if err != nil {
+		return t.fail(name, err)
+	}
+	in, err := toSeries(data, field)
+	if err != nil {
+		return t.fail(name, err)
+	}
+	return t.call(name, func() interface{} { return fn(in, p) })
+}
+
+// pair call an indicator of two series, p are the parameters with the defaults
+func (t Talib) pair(name string, data0, data1 interface{}, args []interface{}, defaults []param, fn func(in0, in1, p []float64) interface{}) interface{} {
+	p, field, err := params(args, defaults...)
+	if err != nil {
+		return t.fail(name, err)
+	}
+	in0, err := toSeries(data0, field)
+	if err != nil {
+		return t.fail(name, err)
+	}
+	in1, err := toSeries(data1, field)
+	if err != nil {
+		return t.fail(name, err)
+	}
+	return t.call(name, func() interface{} { return fn(in0, in1, p) })
+}
+
+// ohlcv call an indicator of the prices and the volumes, p are the parameters with the defaults
+func (t Talib) ohlcv(name string, data interface{}, args []interface{}, defaults []param, fn func(o, h, l, c, v, p []float64) interface{}) interface{} {
+	p, _, err := params(args, defaults...)
+	if err != nil {
+		return t.fail(name, err)
+	}
+	records, err := toRecords(data)
+	if err != nil {
+		return t.fail(name, err)
+	}
+	o, h, l, c, v := splitRecords(records)
+	return t.call(name, func() interface{} { return fn(o, h, l, c, v, p) })
+}
+
+// param a parameter of an indicator and its default value
+type param struct {
+	def     float64
+	min     float64
+	max     float64 //0 表示没有上限
+	integer bool
+}
+
+// period a period, an integer >= 1
+func period(def float64) param {
+	return param{def: def, min: 1, integer: true}
+}
+
+// factor a factor or a deviation, a number >= 0
+func factor(def float64) param {
+	return param{def: def}
+}
+
+// maType a type of moving average, 0 SMA, 1 EMA, 2 WMA, 3 DEMA, 4 TEMA, 5 TRIMA, 6 KAMA, 7 MAMA or 8 T3
+func maType(def float64) param {
+	return param{def: def, max: float64(talib.T3MA), integer: true}
+}
+
+// check check a value of the parameter
+func (p param) check(f float64) error {
+	switch {
+	case math.IsNaN(f) || math.IsInf(f, 0) || f < p.min || p.max > 0 && f > p.max:
+	case p.integer && f != math.Trunc(f):
+	default:
+		return nil
+	}
+	if p.integer && p.max > 0 {
+		return fmt.Errorf("%v should be an integer in %v-%v", f, p.min, p.max)
+	} else if p.integer {
+		return fmt.Errorf("%v should be an integer >= %v", f, p.min)
+	}
+	return fmt.Errorf("%v should be a finite number >= %v", f, p.min)
+}
+
+// params get the parameters of an indicator from the js arguments, the numbers are the parameters in order,
+// a missing one uses its default value, and a string is the field of the records
+func params(args []interface{}, defaults ...param) (p []float64, field string, err error) {
+	for _, d := range defaults {
+		p = append(p, d.def)
+	}
+	i := 0
+	for _, arg := range args {
+		if s, ok := arg.(string); ok {
+			field = s
+			continue
+		}
+		f, ok := toFloat(arg)
+		switch {
+		case arg == nil:
+			// undefined 和 null 使用默认值
+		case !ok:
+			return nil, "", fmt.Errorf("the parameter %v should be a number", i+1)
+		case i >= len(p):
+			return nil, "", fmt.Errorf("too many parameters, at most %v", len(p))
+		default:
+			if err := defaults[i].check(f); err != nil {
+				return nil, "", fmt.Errorf("the parameter %v: %v", i+1, err)
+			}
+			p[i] = f
+		}
+		i++
+	}
+	return
 }
 
-func (t Talib) Rsi(prices []float64, period int) interface{} {
-	result := talib.Rsi(prices, period)
-	return result
+// toFloat convert a js number
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	}
+	return 0, false
 }
 
-func (t Talib) Ema(prices []float64, period int) interface{} {
-	result := talib.Ema(prices, period)
-	return result
+// toRecord convert a record or a js object with the fields of a record
+func toRecord(v interface{}) (r api.Record, ok bool) {
+	switch v := v.(type) {
+	case api.Record:
+		return v, true
+	case *api.Record:
+		return *v, v != nil
+	case map[string]interface{}:
+		fields := map[string]*float64{"open": &r.Open, "high": &r.High, "low": &r.Low, "close": &r.Close, "volume": &r.Volume}
+		for k, value := range v {
+			k = strings.ToLower(k)
+			if k == "time" {
+				t, _ := toFloat(value)
+				r.Time = int64(t)
+			} else if f, ok := fields[k]; ok {
+				*f, _ = toFloat(value)
+			}
+		}
+		return r, true
+	}
+	return
 }
 
-type booling struct {
-	upper  []float64
-	middle []float64
-	lower  []float64
+// toRecords convert a record array
+func toRecords(data interface{}) ([]api.Record, error) {
+	switch data := data.(type) {
+	case []api.Record:
+		return data, nil
+	case []interface{}:
+		records := make([]api.Record, len(data))
+		for i, v := range data {
+			r, ok := toRecord(v)
+			if _, number := toFloat(v); number {
+				// 数字数组也被导出为 []interface{}
+				return nil, fmt.Errorf("the data should be a record array")
+			} else if !ok {
+				return nil, fmt.Errorf("the item %v is not a record", i)
+			}
+			records[i] = r
+		}
+		return records, nil
+	case []map[string]interface{}:
+		records := make([]api.Record, len(data))
+		for i, v := range data {
+			records[i], _ = toRecord(v)
+		}
+		return records, nil
+	}
+	return nil, fmt.Errorf("the data should be a record array")
 }
 
-func (t Talib) Bool(price []float64, period int) map[string][]float64 {
-	upper, middle, lower := talib.BBands(price, period, 2, 2, 0)
-	return map[string][]float64{
-		"upper":  upper,
-		"middle": middle,
-		"lower":  lower,
+// toSeries convert a number array, or select a field of a record array, Close by default
+func toSeries(data interface{}, field string) ([]float64, error) {
+	name := "close"
+	if field != "" {
+		name = strings.ToLower(field)
+	}
+	selector := map[string]func(api.Record) float64{
+		"open":   func(r api.Record) float64 { return r.Open },
+		"high":   func(r api.Record) float64 { return r.High },
+		"low":    func(r api.Record) float64 { return r.Low },
+		"close":  func(r api.Record) float64 { return r.Close },
+		"volume": func(r api.Record) float64 { return r.Volume },
+	}[name]
+	if selector == nil {
+		return nil, fmt.Errorf("unrecognized field: %v", field)
+	}
+	switch data := data.(type) {
+	case []float64:
+		return data, nil
+	case []int64:
+		in := make([]float64, len(data))
+		for i, v := range data {
+			in[i] = float64(v)
+		}
+		return in, nil
+	case []interface{}:
+		in := make([]float64, len(data))
+		for i, v := range data {
+			if f, ok := toFloat(v); ok {
+				in[i] = f
+			} else if r, ok := toRecord(v); ok {
+				in[i] = selector(r)
+			} else {
+				return nil, fmt.Errorf("the item %v is neither a number nor a record", i)
+			}
+		}
+		return in, nil
+	case []api.Record, []map[string]interface{}:
+		records, err := toRecords(data)
+		if err != nil {
+			return nil, err
+		}
+		in := make([]float64, len(records))
+		for i, r := range records {
+			in[i] = selector(r)
+		}
+		return in, nil
 	}
+	return nil, fmt.Errorf("the data should be a number array or a record array")
+}
+
+// splitRecords get the open, high, low, close and volume series of the records
+func splitRecords(records []api.Record) (o, h, l, c, v []float64) {
+	n := len(records)
+	o, h, l, c, v = make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)
+	for i, r := range records {
+		o[i], h[i], l[i], c[i], v[i] = r.Open, r.High, r.Low, r.Close, r.Volume
+	}
+	return
+}
+
+// Sma simple moving average, period 30 by default
+func (t Talib) Sma(data interface{}, args ...interface{}) interface{} {
+	return t.series("Sma", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Sma(in, int(p[0])) })
+}
+
+// Ema exponential moving average, period 30 by default
+func (t Talib) Ema(data interface{}, args ...interface{}) interface{} {
+	return t.series("Ema", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Ema(in, int(p[0])) })
+}
+
+// Wma weighted moving average, period 30 by default
+func (t Talib) Wma(data interface{}, args ...interface{}) interface{} {
+	return t.series("Wma", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Wma(in, int(p[0])) })
+}
+
+// Dema double exponential moving average, period 30 by default
+func (t Talib) Dema(data interface{}, args ...interface{}) interface{} {
+	return t.series("Dema", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Dema(in, int(p[0])) })
+}
+
+// Tema triple exponential moving average, period 30 by default
+func (t Talib) Tema(data interface{}, args ...interface{}) interface{} {
+	return t.series("Tema", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Tema(in, int(p[0])) })
+}
+
+// Trima triangular moving average, period 30 by default
+func (t Talib) Trima(data interface{}, args ...interface{}) interface{} {
+	return t.series("Trima", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Trima(in, int(p[0])) })
+}
+
+// Kama kaufman adaptive moving average, period 30 by default
+func (t Talib) Kama(data interface{}, args ...interface{}) interface{} {
+	return t.series("Kama", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Kama(in, int(p[0])) })
+}
+
+// T3 triple exponential moving average (T3), period 5 and volume factor 0.7 by default
+func (t Talib) T3(data interface{}, args ...interface{}) interface{} {
+	return t.series("T3", data, args, []param{period(5), factor(0.7)}, func(in, p []float64) interface{} { return talib.T3(in, int(p[0]), p[1]) })
+}
+
+// BBands bollinger bands {upper, middle, lower}, period 20, 2 standard deviations and SMA by default
+func (t Talib) BBands(data interface{}, args ...interface{}) interface{} {
+	return t.series("BBands", data, args, []param{period(20), factor(2), factor(2), maType(0)}, func(in, p []float64) interface{} {
+		upper, middle, lower := talib.BBands(in, int(p[0]), p[1], p[2], talib.MaType(p[3]))
+		return map[string][]float64{"upper": upper, "middle": middle, "lower": lower}
+	})
+}
+
+// Bool bollinger bands with 2 standard deviations, kept for the old strategies, use BBands instead
+func (t Talib) Bool(data interface{}, args ...interface{}) interface{} {
+	p, field, err := params(args, period(20))
+	if err != nil {
+		return t.fail("Bool", err)
+	}
+	return t.BBands(data, p[0], 2, 2, field)
+}
+
+// MidPoint midpoint over period, period 14 by default
+func (t Talib) MidPoint(data interface{}, args ...interface{}) interface{} {
+	return t.series("MidPoint", data, args, []param{period(14)}, func(in, p []float64) interface{} { return talib.MidPoint(in, int(p[0])) })
+}
+
+// MidPrice midpoint price over period, period 14 by default
+func (t Talib) MidPrice(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("MidPrice", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.MidPrice(h, l, int(p[0])) })
+}
+
+// Sar parabolic sar, acceleration 0.02 and maximum 0.2 by default
+func (t Talib) Sar(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Sar", records, args, []param{factor(0.02), factor(0.2)}, func(o, h, l, c, v, p []float64) interface{} { return talib.Sar(h, l, p[0], p[1]) })
+}
+
+// Rsi relative strength index, period 14 by default
+func (t Talib) Rsi(data interface{}, args ...interface{}) interface{} {
+	return t.series("Rsi", data, args, []param{period(14)}, func(in, p []float64) interface{} { return talib.Rsi(in, int(p[0])) })
+}
+
+// StochRsi stochastic relative strength index {k, d}, periods 14, 5, 3 and SMA by default
+func (t Talib) StochRsi(data interface{}, args ...interface{}) interface{} {
+	return t.series("StochRsi", data, args, []param{period(14), period(5), period(3), maType(0)}, func(in, p []float64) interface{} {
+		k, d := talib.StochRsi(in, int(p[0]), int(p[1]), int(p[2]), talib.MaType(p[3]))
+		return map[string][]float64{"k": k, "d": d}
+	})
+}
+
+// Macd moving average convergence/divergence {macd, signal, hist}, periods 12, 26 and 9 by default
+func (t Talib) Macd(data interface{}, args ...interface{}) interface{} {
+	return t.series("Macd", data, args, []param{period(12), period(26), period(9)}, func(in, p []float64) interface{} {
+		macd, signal, hist := talib.Macd(in, int(p[0]), int(p[1]), int(p[2]))
+		return map[string][]float64{"macd": macd, "signal": signal, "hist": hist}
+	})
+}
+
+// Apo absolute price oscillator, periods 12, 26 and SMA by default
+func (t Talib) Apo(data interface{}, args ...interface{}) interface{} {
+	return t.series("Apo", data, args, []param{period(12), period(26), maType(0)}, func(in, p []float64) interface{} { return talib.Apo(in, int(p[0]), int(p[1]), talib.MaType(p[2])) })
+}
+
+// Ppo percentage price oscillator, periods 12, 26 and SMA by default
+func (t Talib) Ppo(data interface{}, args ...interface{}) interface{} {
+	return t.series("Ppo", data, args, []param{period(12), period(26), maType(0)}, func(in, p []float64) interface{} { return talib.Ppo(in, int(p[0]), int(p[1]), talib.MaType(p[2])) })
+}
+
+// Mom momentum, period 10 by default
+func (t Talib) Mom(data interface{}, args ...interface{}) interface{} {
+	return t.series("Mom", data, args, []param{period(10)}, func(in, p []float64) interface{} { return talib.Mom(in, int(p[0])) })
+}
+
+// Roc rate of change ((price/prevPrice)-1)*100, period 10 by default
+func (t Talib) Roc(data interface{}, args ...interface{}) interface{} {
+	return t.series("Roc", data, args, []param{period(10)}, func(in, p []float64) interface{} { return talib.Roc(in, int(p[0])) })
+}
+
+// Cmo chande momentum oscillator, period 14 by default
+func (t Talib) Cmo(data interface{}, args ...interface{}) interface{} {
+	return t.series("Cmo", data, args, []param{period(14)}, func(in, p []float64) interface{} { return talib.Cmo(in, int(p[0])) })
+}
+
+// Trix 1-day rate of change of a triple smooth ema, period 30 by default
+func (t Talib) Trix(data interface{}, args ...interface{}) interface{} {
+	return t.series("Trix", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Trix(in, int(p[0])) })
+}
+
+// Adx average directional movement index, period 14 by default
+func (t Talib) Adx(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Adx", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.Adx(h, l, c, int(p[0])) })
+}
+
+// Adxr average directional movement index rating, period 14 by default
+func (t Talib) Adxr(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Adxr", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.AdxR(h, l, c, int(p[0])) })
+}
+
+// Dx directional movement index, period 14 by default
+func (t Talib) Dx(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Dx", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.Dx(h, l, c, int(p[0])) })
+}
+
+// PlusDi plus directional indicator, period 14 by default
+func (t Talib) PlusDi(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("PlusDi", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.PlusDI(h, l, c, int(p[0])) })
+}
+
+// MinusDi minus directional indicator, period 14 by default
+func (t Talib) MinusDi(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("MinusDi", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.MinusDI(h, l, c, int(p[0])) })
+}
+
+// Aroon aroon {down, up}, period 14 by default
+func (t Talib) Aroon(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Aroon", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} {
+		down, up := talib.Aroon(h, l, int(p[0]))
+		return map[string][]float64{"down": down, "up": up}
+	})
+}
+
+// AroonOsc aroon oscillator, period 14 by default
+func (t Talib) AroonOsc(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("AroonOsc", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.AroonOsc(h, l, int(p[0])) })
+}
+
+// Cci commodity channel index, period 14 by default
+func (t Talib) Cci(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Cci", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.Cci(h, l, c, int(p[0])) })
+}
+
+// Mfi money flow index, period 14 by default
+func (t Talib) Mfi(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Mfi", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.Mfi(h, l, c, v, int(p[0])) })
+}
+
+// Willr williams' %R, period 14 by default
+func (t Talib) Willr(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Willr", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.WillR(h, l, c, int(p[0])) })
+}
+
+// UltOsc ultimate oscillator, periods 7, 14 and 28 by default
+func (t Talib) UltOsc(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("UltOsc", records, args, []param{period(7), period(14), period(28)}, func(o, h, l, c, v, p []float64) interface{} {
+		return talib.UltOsc(h, l, c, int(p[0]), int(p[1]), int(p[2]))
+	})
+}
+
+// Bop balance of power
+func (t Talib) Bop(records interface{}) interface{} {
+	return t.ohlcv("Bop", records, nil, nil, func(o, h, l, c, v, p []float64) interface{} { return talib.Bop(o, h, l, c) })
+}
+
+// Stoch stochastic {k, d}, periods 5, 3, 3 and SMA by default
+func (t Talib) Stoch(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Stoch", records, args, []param{period(5), period(3), period(3), maType(0)}, func(o, h, l, c, v, p []float64) interface{} {
+		k, d := talib.Stoch(h, l, c, int(p[0]), int(p[1]), talib.MaType(p[3]), int(p[2]), talib.MaType(p[3]))
+		return map[string][]float64{"k": k, "d": d}
+	})
+}
+
+// Kdj the kdj of the chinese markets {k, d, j}, periods 9, 3 and 3 by default,
+// k and d start from 50 and are smoothed by 1/m1 and 1/m2, j = 3k - 2d
+func (t Talib) Kdj(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Kdj", records, args, []param{period(9), period(3), period(3)}, func(o, h, l, c, v, p []float64) interface{} {
+		n, m1, m2 := int(p[0]), p[1], p[2]
+		size := len(c)
+		k, d, j := make([]float64, size), make([]float64, size), make([]float64, size)
+		lastK, lastD := 50.0, 50.0
+		for i := n - 1; i < size; i++ {
+			high, low := math.Inf(-1), math.Inf(1)
+			for x := i - n + 1; x <= i; x++ {
+				high, low = math.Max(high, h[x]), math.Min(low, l[x])
+			}
+			rsv := 50.0
+			if high > low {
+				rsv = (c[i] - low) / (high - low) * 100
+			}
+			lastK = (m1-1)/m1*lastK + rsv/m1
+			lastD = (m2-1)/m2*lastD + lastK/m2
+			k[i], d[i], j[i] = lastK, lastD, 3*lastK-2*lastD
+		}
+		return map[string][]float64{"k": k, "d": d, "j": j}
+	})
+}
+
+// Obv on balance volume
+func (t Talib) Obv(records interface{}) interface{} {
+	return t.ohlcv("Obv", records, nil, nil, func(o, h, l, c, v, p []float64) interface{} { return talib.Obv(c, v) })
+}
+
+// Ad chaikin a/d line
+func (t Talib) Ad(records interface{}) interface{} {
+	return t.ohlcv("Ad", records, nil, nil, func(o, h, l, c, v, p []float64) interface{} { return talib.Ad(h, l, c, v) })
+}
+
+// AdOsc chaikin a/d oscillator, periods 3 and 10 by default
+func (t Talib) AdOsc(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("AdOsc", records, args, []param{period(3), period(10)}, func(o, h, l, c, v, p []float64) interface{} { return talib.AdOsc(h, l, c, v, int(p[0]), int(p[1])) })
+}
+
+// Atr average true range, period 14 by default
+func (t Talib) Atr(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Atr", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.Atr(h, l, c, int(p[0])) })
+}
+
+// Natr normalized average true range, period 14 by default
+func (t Talib) Natr(records interface{}, args ...interface{}) interface{} {
+	return t.ohlcv("Natr", records, args, []param{period(14)}, func(o, h, l, c, v, p []float64) interface{} { return talib.Natr(h, l, c, int(p[0])) })
+}
+
+// TRange true range
+func (t Talib) TRange(records interface{}) interface{} {
+	return t.ohlcv("TRange", records, nil, nil, func(o, h, l, c, v, p []float64) interface{} { return talib.TRange(h, l, c) })
+}
+
+// AvgPrice average price (open + high + low + close) / 4
+func (t Talib) AvgPrice(records interface{}) interface{} {
+	return t.ohlcv("AvgPrice", records, nil, nil, func(o, h, l, c, v, p []float64) interface{} { return talib.AvgPrice(o, h, l, c) })
+}
+
+// MedPrice median price (high + low) / 2
+func (t Talib) MedPrice(records interface{}) interface{} {
+	return t.ohlcv("MedPrice", records, nil, nil, func(o, h, l, c, v, p []float64) interface{} { return talib.MedPrice(h, l) })
+}
+
+// TypPrice typical price (high + low + close) / 3
+func (t Talib) TypPrice(records interface{}) interface{} {
+	return t.ohlcv("TypPrice", records, nil, nil, func(o, h, l, c, v, p []float64) interface{} { return talib.TypPrice(h, l, c) })
+}
+
+// WclPrice weighted close price (high + low + close * 2) / 4
+func (t Talib) WclPrice(records interface{}) interface{} {
+	return t.ohlcv("WclPrice", records, nil, nil, func(o, h, l, c, v, p []float64) interface{} { return talib.WclPrice(h, l, c) })
+}
+
+// StdDev standard deviation, period 5 and 1 deviation by default
+func (t Talib) StdDev(data interface{}, args ...interface{}) interface{} {
+	return t.series("StdDev", data, args, []param{period(5), factor(1)}, func(in, p []float64) interface{} { return talib.StdDev(in, int(p[0]), p[1]) })
+}
+
+// Var variance, period 5 by default
+func (t Talib) Var(data interface{}, args ...interface{}) interface{} {
+	return t.series("Var", data, args, []param{period(5)}, func(in, p []float64) interface{} { return talib.Var(in, int(p[0])) })
+}
+
+// LinearReg linear regression, period 14 by default
+func (t Talib) LinearReg(data interface{}, args ...interface{}) interface{} {
+	return t.series("LinearReg", data, args, []param{period(14)}, func(in, p []float64) interface{} { return talib.LinearReg(in, int(p[0])) })
+}
+
+// LinearRegSlope slope of the linear regression, period 14 by default
+func (t Talib) LinearRegSlope(data interface{}, args ...interface{}) interface{} {
+	return t.series("LinearRegSlope", data, args, []param{period(14)}, func(in, p []float64) interface{} { return talib.LinearRegSlope(in, int(p[0])) })
+}
+
+// Tsf time series forecast, period 14 by default
+func (t Talib) Tsf(data interface{}, args ...interface{}) interface{} {
+	return t.series("Tsf", data, args, []param{period(14)}, func(in, p []float64) interface{} { return talib.Tsf(in, int(p[0])) })
+}
+
+// Max highest value over period, period 30 by default
+func (t Talib) Max(data interface{}, args ...interface{}) interface{} {
+	return t.series("Max", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Max(in, int(p[0])) })
+}
+
+// Min lowest value over period, period 30 by default
+func (t Talib) Min(data interface{}, args ...interface{}) interface{} {
+	return t.series("Min", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Min(in, int(p[0])) })
+}
+
+// Sum summation over period, period 30 by default
+func (t Talib) Sum(data interface{}, args ...interface{}) interface{} {
+	return t.series("Sum", data, args, []param{period(30)}, func(in, p []float64) interface{} { return talib.Sum(in, int(p[0])) })
+}
+
+// Correl pearson's correlation coefficient of two series, period 30 by default
+func (t Talib) Correl(data0, data1 interface{}, args ...interface{}) interface{} {
+	return t.pair("Correl", data0, data1, args, []param{period(30)}, func(in0, in1, p []float64) interface{} { return talib.Correl(in0, in1, int(p[0])) })
+}
+
+// Crossover check if the first series crosses over the second one at the last value
+func (t Talib) Crossover(data0, data1 interface{}, args ...interface{}) interface{} {
+	return t.pair("Crossover", data0, data1, args, nil, func(in0, in1, _ []float64) interface{} { return talib.Crossover(in0, in1) })
+}
+
+// Crossunder check if the first series crosses under the second one at the last value
+func (t Talib) Crossunder(data0, data1 interface{}, args ...interface{}) interface{} {
+	return t.pair("Crossunder", data0, data1, args, nil, func(in0, in1, _ []float64) interface{} { return talib.Crossunder(in0, in1) })
 }
@@ -0,0 +1,279 @@
+package trader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// Clock the time source of the timers, a backtest replaces it with a VirtualClock
+type Clock interface {
+	Now() time.Time
+	// Wait block until the time t or until ctx is done
+	Wait(ctx context.Context, t time.Time)
+}
+
+// realClock the wall clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Wait(ctx context.Context, t time.Time) {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// VirtualClock a clock which only moves when it is waited on or advanced, so that a backtest
+// replays the timers in the order of the virtual time without really sleeping
+type VirtualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewVirtualClock ...
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+// Now ...
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Wait move the clock to t at once
+func (c *VirtualClock) Wait(ctx context.Context, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ctx.Err() == nil && t.After(c.now) {
+		c.now = t
+	}
+}
+
+// Advance move the clock forward by d
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// minTimerInterval the minimum interval of G.SetInterval, so that a timer can not starve the others
+const minTimerInterval = time.Millisecond
+
+// timer a callback added by G.SetTimeout, G.SetInterval or G.Schedule
+type timer struct {
+	id       int64
+	fn       goja.Value
+	args     []interface{}
+	at       time.Time     //下一次运行的时间
+	interval time.Duration //重复运行的间隔, 0 表示只运行一次
+	cron     *cronSchedule //不为 nil 时按 cron 表达式重复运行
+}
+
+// timers the timers of a trader, the callbacks are only run on the goroutine of main()
+type timers struct {
+	mu     sync.Mutex
+	nextID int64
+	items  map[int64]*timer
+}
+
+func newTimers() *timers {
+	return &timers{items: make(map[int64]*timer)}
+}
+
+// add ...
+func (ts *timers) add(t *timer) int64 {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.nextID++
+	t.id = ts.nextID
+	ts.items[t.id] = t
+	return t.id
+}
+
+// remove ...
+func (ts *timers) remove(id int64) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	_, ok := ts.items[id]
+	delete(ts.items, id)
+	return ok
+}
+
+// clear remove all timers
+func (ts *timers) clear() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.items = make(map[int64]*timer)
+}
+
+// earliest get the timer which runs first, the one added first if they run at the same time,
+// the caller must hold the lock
+func (ts *timers) earliest() (first *timer) {
+	for _, t := range ts.items {
+		if first == nil || t.at.Before(first.at) || (t.at.Equal(first.at) && t.id < first.id) {
+			first = t
+		}
+	}
+	return
+}
+
+// nextAt get the time of the next timer, false if there is none
+func (ts *timers) nextAt() (time.Time, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if t := ts.earliest(); t != nil {
+		return t.at, true
+	}
+	return time.Time{}, false
+}
+
+// pop get a timer which is due at now and schedule its next run, nil if there is none
+func (ts *timers) pop(now time.Time) *timer {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := ts.earliest()
+	if t == nil || t.at.After(now) {
+		return nil
+	}
+	due := *t
+	switch {
+	case t.cron != nil:
+		t.at = t.cron.next(now)
+		if t.at.IsZero() {
+			delete(ts.items, t.id)
+		}
+	case t.interval > 0:
+		t.at = t.at.Add(t.interval)
+		// 错过的运行不再补上
+		if !t.at.After(now) {
+			t.at = now.Add(t.interval)
+		}
+	default:
+		delete(ts.items, t.id)
+	}
+	return &due
+}
+
+// onMainLoop check if the caller runs on the goroutine of main(), the main runtime is blocked while the tasks run
+func (g *Global) onMainLoop() bool {
+	return atomic.LoadInt32(&g.running) == 0
+}
+
+// addTimer ...
+func (g *Global) addTimer(name string, t *timer) interface{} {
+	if !g.onMainLoop() {
+		g.logError(name, "(), timers can not be used in tasks")
+		return false
+	}
+	if !isFunction(t.fn) {
+		g.logError(name, "(), Invalid callback function")
+		return false
+	}
+	return g.timers.add(t)
+}
+
+// SetTimeout run fn(args...) once after ms milliseconds, the id of the timer is returned
+func (g *Global) SetTimeout(fn goja.Value, ms int64, args ...interface{}) interface{} {
+	if ms < 0 {
+		ms = 0
+	}
+	return g.addTimer("SetTimeout", &timer{
+		fn:   fn,
+		args: args,
+		at:   g.clock.Now().Add(time.Duration(ms) * time.Millisecond),
+	})
+}
+
+// SetInterval run fn(args...) every ms milliseconds, the id of the timer is returned
+func (g *Global) SetInterval(fn goja.Value, ms int64, args ...interface{}) interface{} {
+	interval := time.Duration(ms) * time.Millisecond
+	if interval < minTimerInterval {
+		interval = minTimerInterval
+	}
+	return g.addTimer("SetInterval", &timer{
+		fn:       fn,
+		args:     args,
+		at:       g.clock.Now().Add(interval),
+		interval: interval,
+	})
+}
+
+// Schedule run fn(args...) at the times matched by a cron expression, the id of the timer is returned
+func (g *Global) Schedule(spec string, fn goja.Value, args ...interface{}) interface{} {
+	cron, err := parseCron(spec)
+	if err != nil {
+		g.logError("Schedule(), ", err)
+		return false
+	}
+	at := cron.next(g.clock.Now())
+	if at.IsZero() {
+		g.logError("Schedule(), ", fmt.Sprintf("%q never runs", spec))
+		return false
+	}
+	return g.addTimer("Schedule", &timer{
+		fn:   fn,
+		args: args,
+		at:   at,
+		cron: cron,
+	})
+}
+
+// ClearTimer cancel a timer, false if it does not exist or has already run
+func (g *Global) ClearTimer(id int64) bool {
+	return g.timers.remove(id)
+}
+
+// runTimers run the due timers one by one, an error of a callback is logged and does not stop the trader
+func (g *Global) runTimers() {
+	for g.runContext().Err() == nil {
+		t := g.timers.pop(g.clock.Now())
+		if t == nil {
+			return
+		}
+		g.monitor.beat()
+		if _, err := callJS(g.ctx, t.fn, t.args...); isHalt(err) {
+			return
+		} else if err != nil {
+			g.logError(err)
+		}
+	}
+}
+
+// waitUntil wait until the deadline, the timers due before it are run in order
+func (g *Global) waitUntil(deadline time.Time) {
+	ctx := g.runContext()
+	for g.onMainLoop() && ctx.Err() == nil {
+		at, ok := g.timers.nextAt()
+		if !ok || at.After(deadline) {
+			break
+		}
+		g.clock.Wait(ctx, at)
+		g.runTimers()
+	}
+	g.clock.Wait(ctx, deadline)
+}
+
+// loop keep running the timers after main() returns, until there is no timer or the trader is stopped
+func (g *Global) loop() {
+	ctx := g.runContext()
+	for ctx.Err() == nil {
+		at, ok := g.timers.nextAt()
+		if !ok {
+			return
+		}
+		g.clock.Wait(ctx, at)
+		g.runTimers()
+	}
+}
@@ -1,45 +1,123 @@
 package trader
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
 	"github.com/phonegapX/QuantBot/api"
+	"github.com/phonegapX/QuantBot/config"
 	"github.com/phonegapX/QuantBot/constant"
 	"github.com/phonegapX/QuantBot/model"
-	"github.com/robertkrimen/otto"
 )
 
 // Trader Variable
 var (
-	Executor      = make(map[int64]*Global) //保存正在运行的策略，防止重复运行
-	errHalt       = fmt.Errorf("HALT")
-	exchangeMaker = map[string]func(api.Option) api.Exchange{ //保存所有交易所的构造函数
+	errHalt        = fmt.Errorf("HALT")
+	exitTimeout    = 10 * time.Second                          //exit() 的最长执行时间
+	killTimeout    = 15 * time.Second                          //停止后超过这个时间没有退出则强制结束
+	resumeInterval = time.Second                               //服务重启后恢复运行的策略之间的间隔, 避免同时请求交易所
+	exchangeMaker  = map[string]func(api.Option) api.Exchange{ //保存所有交易所的构造函数
 		constant.Okex: api.NewOKEX,
 	}
 )
 
+// NewExchange create an exchange by its config
+func NewExchange(traderID int64, e model.Exchange) (api.Exchange, error) {
+	maker, ok := exchangeMaker[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported exchange type: %v", e.Type)
+	}
+	if _, err := api.GetEndpoint(e.Type, e.IsSandbox()); err != nil {
+		return nil, err
+	}
+	if err := e.CheckHost(); err != nil {
+		return nil, err
+	}
+	opt := api.Option{
+		TraderID:   traderID,
+		Type:       e.Type,
+		Name:       e.Name,
+		AccessKey:  e.AccessKey,
+		SecretKey:  e.SecretKey,
+		Passphrase: e.Passphrase,
+		Test:       e.Test,
+		Host:       e.Host,
+	}
+	return maker(opt), nil
+}
+
 // GetTraderStatus ...
 func GetTraderStatus(id int64) (status int64) {
-	if t, ok := Executor[id]; ok && t != nil {
-		status = t.Status
+	return statusOf(traderRegistry.state(id))
+}
+
+// GetTraderClocks ...
+func GetTraderClocks(id int64) (clocks []model.Clock) {
+	if t := traderRegistry.runtime(id); t != nil {
+		for _, e := range t.es {
+			status := e.GetClockStatus()
+			clocks = append(clocks, model.Clock{
+				ExchangeName: e.GetName(),
+				Offset:       status.Offset,
+				Latency:      status.Latency,
+			})
+		}
 	}
 	return
 }
 
 // Switch ...
 func Switch(id int64) (err error) {
-	if GetTraderStatus(id) > 0 {
-		return stop(id)
+	running := false
+	switch state := traderRegistry.state(id); state {
+	case constant.TraderRunning, constant.TraderRestarting:
+		err = stop(id)
+	case constant.TraderStarting, constant.TraderStopping:
+		return fmt.Errorf("The trader is %v", state)
+	default:
+		err = run(id)
+		running = true
+	}
+	if err != nil {
+		return
 	}
-	return run(id)
+	return model.SetTraderRunning(id, running)
 }
 
-//核心是初始化js运行环境，及其可以调用的api
-func initialize(id int64) (trader Global, err error) {
-	if t := Executor[id]; t != nil && t.Status > 0 {
+// Resume start the traders which were running when the server stopped, one by one every resumeInterval
+func Resume() {
+	traders := []model.Trader{}
+	if err := model.DB.Where("running = ?", true).Find(&traders).Error; err != nil {
+		log.Println("Resume the traders error:", err)
 		return
 	}
+	go func() {
+		for i, t := range traders {
+			if i > 0 {
+				time.Sleep(resumeInterval)
+			}
+			logger := model.Logger{TraderID: t.ID, ExchangeType: "global"}
+			if err := run(t.ID); err != nil {
+				logger.Log(constant.ERROR, "", 0.0, 0.0, "Resume the trader error: ", err)
+				model.SetTraderRunning(t.ID, false)
+				continue
+			}
+			logger.Log(constant.INFO, "", 0.0, 0.0, "Resumed after restart")
+		}
+	}()
+}
+
+//核心是初始化js运行环境，及其可以调用的api
+func initialize(id int64) (trader *Global, err error) {
+	trader = &Global{}
 	err = model.DB.First(&trader.Trader, id).Error
 	if err != nil {
 		return
@@ -56,6 +134,14 @@ func initialize(id int64) (trader Global, err error) {
 	if err != nil {
 		return
 	}
+	if trader.Algorithm.IsLibrary {
+		err = fmt.Errorf("A library can not be run, please require it in an algorithm")
+		return
+	}
+	env, err := model.ParseEnvironment(trader.Algorithm.EvnDefault, trader.Environment)
+	if err != nil {
+		return
+	}
 	es, err := self.GetTraderExchanges(trader.ID)
 	if err != nil {
 		return
@@ -65,96 +151,274 @@ func initialize(id int64) (trader Global, err error) {
 		ExchangeType: "global",
 	}
 	trader.tasks = make(Tasks)
-	trader.ctx = otto.New()
-	trader.ctx.Interrupt = make(chan func(), 1)
-	for _, c := range constant.Consts {
-		trader.ctx.Set(c, c)
-	}
+	trader.monitor = &monitor{}
+	trader.clock = realClock{}
+	trader.timers = newTimers()
+	trader.events = newEvents()
+	trader.env = env
+	trader.modules = newModules(trader.UserID)
+	exchanges := []model.Exchange{}
 	for _, e := range es {
-		if maker, ok := exchangeMaker[e.Type]; ok {
-			opt := api.Option{
-				TraderID:   trader.ID,
-				Type:       e.Type,
-				Name:       e.Name,
-				AccessKey:  e.AccessKey,
-				SecretKey:  e.SecretKey,
-				Passphrase: e.Passphrase,
-				Test:       e.Test,
-			}
-			trader.es = append(trader.es, maker(opt))
+		exchange, err := NewExchange(trader.ID, e.Exchange)
+		if err != nil {
+			trader.logError(e.Name, ": ", err)
+			continue
 		}
+		trader.es = append(trader.es, exchange)
+		exchanges = append(exchanges, e.Exchange)
 	}
 	if len(trader.es) == 0 {
 		err = fmt.Errorf("Please add at least one exchange")
 		return
 	}
-	trader.ctx.Set("Global", &trader)
-	trader.ctx.Set("G", &trader)
-	trader.ctx.Set("Exchange", trader.es[0])
-	trader.ctx.Set("E", trader.es[0])
-	trader.ctx.Set("Exchanges", trader.es)
-	trader.ctx.Set("Es", trader.es)
-	trader.ctx.Set("Talib", Talib{})
+	// 模拟盘和实盘混用容易造成误操作, 除非配置文件中明确允许
+	trader.Mode = model.TradeMode(exchanges)
+	if trader.Mode == constant.ModeMixed && strings.ToLower(config.String("allowMixedSandbox")) != "true" {
+		err = fmt.Errorf("Can not combine the sandbox and live exchanges in one trader, set allowMixedSandbox = true to allow it")
+		return
+	}
+	for _, e := range trader.es {
+		if e.IsSandbox() {
+			e.Log("running in the sandbox mode")
+		}
+	}
+	if trader.ctx, err = newRuntime(); err != nil {
+		return
+	}
+	trader.bind(trader.ctx)
 	return
 }
 
+// bind set the constants, the api objects and the parameters of the trader as the globals of a js runtime
+func (g *Global) bind(vm *goja.Runtime) {
+	for _, c := range constant.Consts {
+		vm.Set(c, c)
+	}
+	vm.Set("Global", g)
+	vm.Set("G", g)
+	vm.Set("Exchange", g.es[0])
+	vm.Set("E", g.es[0])
+	vm.Set("Exchanges", g.es)
+	vm.Set("Es", g.es)
+	vm.Set("Talib", Talib{logError: g.logError})
+	vm.Set("Indicators", newIndicators(vm))
+	vm.Set("require", g.modules.requirer(vm))
+	// 策略参数作为全局变量, 在 main() 之前设置
+	for name, v := range g.env {
+		vm.Set(name, v)
+	}
+}
+
+// identifier match the names which can be declared in a script
+var identifier = regexp.MustCompile(`^[A-Za-z_$][\w$]*$`)
+
+// taskRuntime create a js runtime for a task, goja can not copy a runtime, so only the function and the class
+// declarations of the script are run in a new one, the top-level variables get the values they have in the main
+// runtime when the task is added, values which can not be serialised as json are undefined in the task
+func (g *Global) taskRuntime() (*goja.Runtime, error) {
+	vm, err := newRuntime()
+	if err != nil {
+		return nil, err
+	}
+	g.bind(vm)
+	script, err := g.taskScript(vm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := vm.RunString(script); err != nil {
+		return nil, jsError(err)
+	}
+	return vm, nil
+}
+
+// taskScript build the script of a task runtime, the other top-level statements are not run again
+func (g *Global) taskScript(vm *goja.Runtime) (string, error) {
+	src := g.Algorithm.Script
+	prg, err := parser.ParseFile(nil, "", src, 0)
+	if err != nil {
+		return "", err
+	}
+	script := strings.Builder{}
+	kinds := map[string]string{}    // 顶层变量的声明方式
+	literals := map[string]string{} // 初始值是函数或者类的顶层变量
+	names := []string{}
+	declare := func(kind string, list []*ast.Binding) {
+		for _, b := range list {
+			// 解构赋值的变量不复制
+			id, ok := b.Target.(*ast.Identifier)
+			if !ok || kinds[string(id.Name)] != "" {
+				continue
+			}
+			kinds[string(id.Name)] = kind
+			names = append(names, string(id.Name))
+			switch b.Initializer.(type) {
+			case *ast.FunctionLiteral, *ast.ArrowFunctionLiteral, *ast.ClassLiteral:
+				literals[string(id.Name)] = src[b.Initializer.Idx0()-1 : b.Initializer.Idx1()-1]
+			}
+		}
+	}
+	for _, s := range prg.Body {
+		switch s := s.(type) {
+		case *ast.FunctionDeclaration, *ast.ClassDeclaration:
+			script.WriteString(src[s.Idx0()-1:s.Idx1()-1] + "\n")
+		case *ast.VariableStatement:
+			declare("var", s.List)
+		case *ast.LexicalDeclaration:
+			declare(s.Token.String(), s.List)
+		}
+	}
+	// 没有声明直接赋值的全局变量, 绑定的对象和插件定义的全局变量除外, 策略参数可能被 main() 修改
+	bound := map[string]bool{}
+	for _, name := range vm.GlobalObject().Keys() {
+		bound[name] = true
+	}
+	for _, name := range g.ctx.GlobalObject().Keys() {
+		if _, ok := g.env[name]; kinds[name] == "" && identifier.MatchString(name) && (!bound[name] || ok) {
+			kinds[name] = "var"
+			names = append(names, name)
+		}
+	}
+	stringify, _ := goja.AssertFunction(g.ctx.Get("JSON").ToObject(g.ctx).Get("stringify"))
+	for _, name := range names {
+		value := "undefined"
+		if v, err := g.ctx.RunString(name); err != nil {
+			continue
+		} else if isFunction(v) {
+			if value = literals[name]; value == "" {
+				continue
+			}
+		} else if data, err := stringify(goja.Undefined(), v); err == nil && isString(data) {
+			value = data.String()
+		}
+		script.WriteString(fmt.Sprintf("%v %v = %v;\n", kinds[name], name, value))
+	}
+	return script.String(), nil
+}
+
 // run ...
 func run(id int64) (err error) {
+	prev, ok := traderRegistry.transition(id, nil, constant.TraderStarting, constant.TraderIdle, constant.TraderStopped, constant.TraderCrashed)
+	if !ok {
+		return fmt.Errorf("The trader is %v", prev)
+	}
 	trader, err := initialize(id)
 	if err != nil {
+		traderRegistry.transition(id, nil, prev, constant.TraderStarting)
 		return
 	}
+	trader.setContext(context.WithCancel(context.Background()))
+	trader.done = make(chan struct{})
+	trader.LastRunAt = time.Now()
+	trader.monitor.beat()
+	traderRegistry.setRuntime(id, trader)
+	traderRegistry.transition(id, trader, constant.TraderRunning, constant.TraderStarting)
 	go func() {
+		reason := "" //策略崩溃的原因
+		defer close(trader.done)
 		defer func() {
-			if err := recover(); err != nil && err != errHalt {
-				trader.Logger.Log(constant.ERROR, "", 0.0, 0.0, err)
+			if err := recover(); err != nil {
+				trader.logError(err)
+				reason = fmt.Sprint(err)
 			}
-			if exit, err := trader.ctx.Get("exit"); err == nil && exit.IsFunction() {
-				if _, err := exit.Call(exit); err != nil {
-					trader.Logger.Log(constant.ERROR, "", 0.0, 0.0, err)
+			trader.exit()
+			_, stopped := traderRegistry.transition(id, trader, constant.TraderStopped, constant.TraderStopping)
+			// 超过 killTimeout 时 stop() 已经标记为停止, 状态转换会失败
+			stopped = stopped || atomic.LoadInt32(&trader.stopped) == 1
+			if !stopped {
+				to := constant.TraderStopped
+				if reason != "" {
+					to = constant.TraderCrashed
+				}
+				traderRegistry.transition(id, trader, to, constant.TraderRunning)
+			}
+			trader.Logger.Log(constant.INFO, "", 0.0, 0.0, "The trader stopped")
+			// 主动停止的策略不重启, 不再重启的策略在服务重启后也不恢复
+			if !stopped && !supervise(trader, reason) {
+				if err := model.SetTraderRunning(trader.ID, false); err != nil {
+					trader.logError(err)
 				}
 			}
-			trader.Status = 0
 		}()
-		trader.LastRunAt = time.Now()
-		trader.Status = 1
 
 		// RUN javascript
-		if _, err := trader.ctx.Run(trader.Algorithm.Script); err != nil {
-			trader.Logger.Log(constant.ERROR, "", 0.0, 0.0, err)
+		if _, err := trader.ctx.RunString(trader.Algorithm.Script); isHalt(err) {
+			return
+		} else if err != nil {
+			err = jsError(err)
+			trader.logError(err)
+			reason = fmt.Sprint(err)
 		}
-		if main, err := trader.ctx.Get("main"); err != nil || !main.IsFunction() {
-			trader.Logger.Log(constant.ERROR, "", 0.0, 0.0, "Can not get the main function")
+		if main := trader.ctx.Get("main"); !isFunction(main) {
+			// 没有 main() 时以事件驱动的方式运行
+			if handlers := trader.handlers(); len(handlers) > 0 {
+				trader.eventLoop(handlers)
+			} else {
+				trader.logError("Can not get the main function or an event handler")
+				reason = "Can not get the main function or an event handler"
+			}
 		} else {
-			if _, err := main.Call(main); err != nil {
-				trader.Logger.Log(constant.ERROR, "", 0.0, 0.0, err)
+			if _, err := callJS(trader.ctx, main); isHalt(err) {
+				return
+			} else if err != nil {
+				trader.logError(err)
+				reason = fmt.Sprint(err)
+			} else {
+				// main() 返回后继续运行定时器
+				trader.loop()
 			}
 		}
 	}()
-	Executor[trader.ID] = &trader
 	return
 }
 
-// getStatus ...
-//func getStatus(id int64) (status string) {
-//	if t := Executor[id]; t != nil {
-//		status = t.statusLog
-//	}
-//	return
-//}
+// GetTraderRuntime get the live status of a trader, the status is kept after the trader stopped
+func GetTraderRuntime(id int64) (status model.TraderStatus) {
+	status.ID = id
+	status.State = traderRegistry.state(id)
+	status.Status = statusOf(status.State)
+	if t := traderRegistry.runtime(id); t != nil {
+		status.LastRunAt = t.LastRunAt
+		t.monitor.fill(&status)
+	}
+	return
+}
 
-// stop ...
+// stop cancel the context of a running trader and interrupt its js runtime, then exit() is called,
+// the trader is marked as stopped if it does not exit after killTimeout
 func stop(id int64) (err error) {
-	if t, ok := Executor[id]; !ok || t == nil {
+	if cancelRestart(id) {
+		return
+	}
+	t := traderRegistry.runtime(id)
+	if t == nil || t.ctx == nil || t.done == nil {
 		return fmt.Errorf("Can not found the Trader")
 	}
-	Executor[id].ctx.Interrupt <- func() { panic(errHalt) }
+	if prev, ok := traderRegistry.transition(id, t, constant.TraderStopping, constant.TraderRunning); !ok {
+		return fmt.Errorf("The trader is %v", prev)
+	}
+	t.Logger.Log(constant.INFO, "", 0.0, 0.0, "Stopping the trader")
+	atomic.StoreInt32(&t.stopped, 1)
+	t.cancelContext()
+	t.interrupt()
+	timeout := killTimeout
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-t.done:
+		case <-timer.C:
+			// 无法结束阻塞在 Go 代码中的协程, 只能取消它的请求并标记为已停止
+			t.cancelContext()
+			traderRegistry.transition(id, t, constant.TraderStopped, constant.TraderStopping)
+			t.logError("The trader did not stop in ", timeout, ", it is killed")
+		}
+	}()
 	return
 }
 
 // clean ...
 //func clean(userID int64) {
-//	for _, t := range Executor {
+//	for _, t := range traderRegistry.entries {
 //		if t != nil && t.UserID == userID {
 //			stop(t.ID)
 //		}
@@ -0,0 +1,978 @@
+package trader
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/phonegapX/QuantBot/api"
+	"github.com/phonegapX/QuantBot/api/okexmock"
+	"github.com/phonegapX/QuantBot/config"
+	"github.com/phonegapX/QuantBot/constant"
+	"github.com/phonegapX/QuantBot/model"
+)
+
+// allowHost add the address of a mock server to allowHosts of the config
+func allowHost(host string) {
+	config.Set("allowHosts", config.String("allowHosts")+","+host)
+}
+
+// newMockTrader create a trader of the admin which trades on a mock okex server
+func newMockTrader(t *testing.T, script string) (int64, *okexmock.Server) {
+	s := okexmock.NewServer()
+	s.AddAccount("access", "secret", "passphrase", map[string]float64{"USDT": 100000, "BTC": 1})
+	s.AddLiquidity("BTC-USDT", "sell", 30010, 0.5)
+	s.AddLiquidity("BTC-USDT", "buy", 29990, 0.5)
+	s.Start()
+	t.Cleanup(s.Close)
+	allowHost(s.URL)
+	exchange := model.Exchange{
+		UserID:     1,
+		Name:       "mock",
+		Type:       constant.Okex,
+		AccessKey:  "access",
+		SecretKey:  "secret",
+		Passphrase: "passphrase",
+		Host:       s.URL,
+	}
+	algorithm := model.Algorithm{UserID: 1, Name: t.Name(), Script: script}
+	if err := model.DB.Create(&exchange).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := model.DB.Create(&algorithm).Error; err != nil {
+		t.Fatal(err)
+	}
+	trader := model.Trader{UserID: 1, AlgorithmID: algorithm.ID, Name: t.Name()}
+	if err := model.DB.Create(&trader).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := model.DB.Create(&model.TraderExchange{TraderID: trader.ID, ExchangeID: exchange.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return trader.ID, s
+}
+
+// runTrader run a trader and wait for it
+func runTrader(t *testing.T, id int64, message string) {
+	if err := run(id); err != nil {
+		t.Fatal(err)
+	}
+	waitTrader(t, id, message)
+}
+
+// waitTrader wait for the main function of a trader to return and the message to be logged,
+// the logs are saved asynchronously
+func waitTrader(t *testing.T, id int64, message string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		logs := []model.Log{}
+		if err := model.DB.Where("trader_id = ?", id).Order("timestamp").Find(&logs).Error; err != nil {
+			t.Fatal(err)
+		}
+		if state := GetTraderState(id); state == constant.TraderStopped || state == constant.TraderCrashed {
+			for _, l := range logs {
+				if l.Message == message {
+					return
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the trader did not log %q, logs = %+v", message, logs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunWithMockOKEX(t *testing.T) {
+	id, s := newMockTrader(t, `
+function main() {
+	var id = E.Trade("BUY", "BTC/USDT", 30010, 0.2);
+	var orders = E.GetOrder("BTC/USDT", id);
+	G.Log("deal", orders[0].DealAmount);
+}`)
+	runTrader(t, id, "deal0.2")
+	orders := s.Orders("access")
+	if len(orders) != 1 || orders[0].AccFillSz != 0.2 {
+		t.Fatalf("orders = %+v, want one order filled 0.2", orders)
+	}
+	if b := s.Balance("access", "BTC"); b.Avail != 1.2 {
+		t.Errorf("BTC = %+v, want 1.2 available", b)
+	}
+}
+
+func TestRunWithMockOKEXRateLimited(t *testing.T) {
+	id, s := newMockTrader(t, `
+function main() {
+	G.Log("result", E.Trade("BUY", "BTC/USDT", 30010, 0.2));
+}`)
+	s.Fail("/api/v5/trade/order", http.StatusTooManyRequests, "50011", "Too Many Requests")
+	runTrader(t, id, "resultfalse")
+	if orders := s.Orders("access"); len(orders) != 0 {
+		t.Errorf("orders = %+v, want none", orders)
+	}
+}
+
+func TestLogStatus(t *testing.T) {
+	id, _ := newMockTrader(t, `
+function main() {
+	G.LogStatus({type: "table", title: "Balance", cols: ["Currency", "Amount"], rows: [["BTC", 1]]});
+	G.Sleep(1);
+	G.ExecTasks("nothing");
+	G.Log("done");
+}`)
+	runTrader(t, id, "done")
+	s := GetTraderRuntime(id)
+	if s.Format != constant.StatusTable || s.Content != `[{"cols":["Currency","Amount"],"rows":[["BTC",1]],"title":"Balance","type":"table"}]` {
+		t.Errorf("status = %v %v, want a table", s.Format, s.Content)
+	}
+	if s.Heartbeat.Before(s.LastRunAt) || s.LastError != "ExecTasks(), group not exist" {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+func TestFormatStatus(t *testing.T) {
+	tests := []struct {
+		msgs    []interface{}
+		format  string
+		content string
+	}{
+		{[]interface{}{"price: ", 30000.5}, constant.StatusText, "price: 30000.5"},
+		{[]interface{}{"ticker: ", map[string]interface{}{"Buy": 1}}, constant.StatusText, `ticker: {"Buy":1}`},
+		{[]interface{}{[]interface{}{1, 2}}, constant.StatusJSON, "[1,2]"},
+		{[]interface{}{map[string]interface{}{"type": "table"}, map[string]interface{}{"type": "table"}}, constant.StatusTable, `[{"type":"table"},{"type":"table"}]`},
+	}
+	for _, tt := range tests {
+		if format, content, err := formatStatus(tt.msgs...); err != nil || format != tt.format || content != tt.content {
+			t.Errorf("formatStatus(%v) = %v, %v, %v, want %v, %v", tt.msgs, format, content, err, tt.format, tt.content)
+		}
+	}
+}
+
+func TestRunWithParams(t *testing.T) {
+	id, _ := newMockTrader(t, `
+function main() {
+	G.Log(Symbol, " ", Amount, " ", Period + 1);
+}`)
+	algorithm := model.Algorithm{}
+	model.DB.Raw("SELECT a.* FROM algorithms a, traders t WHERE t.id = ? AND a.id = t.algorithm_id", id).Scan(&algorithm)
+	algorithm.EvnDefault = `[{"name": "Symbol", "type": "string", "default": "BTC/USDT"}, {"name": "Amount", "type": "number", "default": 0.1}, {"name": "Period", "type": "integer", "default": 20}]`
+	if err := model.DB.Save(&algorithm).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := model.DB.Model(&model.Trader{}).Where("id = ?", id).Update("environment", `{"Amount": 0.5}`).Error; err != nil {
+		t.Fatal(err)
+	}
+	runTrader(t, id, "BTC/USDT 0.5 21")
+}
+
+// waitState wait for the trader to be in the state
+func waitState(t *testing.T, id int64, state string, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for GetTraderState(id) != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("state = %v, want %v", GetTraderState(id), state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopSleepingTrader(t *testing.T) {
+	id, _ := newMockTrader(t, `
+function main() {
+	G.Log("started");
+	G.Sleep(60000);
+	G.Log("unreachable");
+}
+function exit() {
+	G.Sleep(10);
+	G.Log("exited");
+}`)
+	if err := run(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(id); err == nil {
+		t.Error("run() a running trader should fail")
+	}
+	waitState(t, id, constant.TraderRunning, time.Second)
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	if err := Switch(id); err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(id); err == nil && GetTraderState(id) == constant.TraderStopping {
+		t.Error("stop() a stopping trader should fail")
+	}
+	waitState(t, id, constant.TraderStopped, time.Second)
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("the trader stopped in %v, G.Sleep should be woken up", d)
+	}
+	waitTrader(t, id, "exited")
+	if err := stop(id); err == nil {
+		t.Error("stop() a stopped trader should fail")
+	}
+}
+
+func TestExitTimeout(t *testing.T) {
+	exitTimeout, killTimeout = 100*time.Millisecond, time.Second
+	defer func() { exitTimeout, killTimeout = 10*time.Second, 15*time.Second }()
+	id, _ := newMockTrader(t, `
+function main() {
+	while (true) {
+		G.Sleep(10);
+	}
+}
+function exit() {
+	while (true) {
+		G.Sleep(10);
+	}
+}`)
+	if err := run(id); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := stop(id); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, id, constant.TraderStopped, time.Second)
+	if s := GetTraderRuntime(id); s.LastError != "exit() did not return in 100ms" {
+		t.Errorf("last error = %q", s.LastError)
+	}
+}
+
+func TestKilledTraderNotRestarted(t *testing.T) {
+	exitTimeout, killTimeout, restartBackoff = 500*time.Millisecond, 50*time.Millisecond, 10*time.Millisecond
+	defer func() { exitTimeout, killTimeout, restartBackoff = 10*time.Second, 15*time.Second, time.Second }()
+	id, _ := newMockTrader(t, `
+function main() {
+	G.Log("started");
+	G.Sleep(60000);
+}
+function exit() {
+	while (true) {
+		G.Sleep(10);
+	}
+}`)
+	if err := model.DB.Model(&model.Trader{}).Where("id = ?", id).Update("restart_policy", constant.RestartAlways).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := run(id); err != nil {
+		t.Fatal(err)
+	}
+	waitLog(t, id, "started")
+	done := traderRegistry.runtime(id).done
+	if err := stop(id); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, id, constant.TraderStopped, time.Second)
+	// exit() 被中断后协程才退出, 之后也不能重启
+	<-done
+	time.Sleep(100 * time.Millisecond)
+	if s := GetTraderState(id); s != constant.TraderStopped {
+		t.Errorf("state = %v, want stopped", s)
+	}
+	logs := []model.Log{}
+	model.DB.Where("trader_id = ? AND message = ?", id, "started").Find(&logs)
+	if len(logs) != 1 {
+		t.Errorf("the trader started %v times, want 1", len(logs))
+	}
+}
+
+func TestStopUnknownTrader(t *testing.T) {
+	traderRegistry.transition(-1, nil, constant.TraderStopped, constant.TraderIdle)
+	traderRegistry.setRuntime(-1, &Global{})
+	if err := stop(-1); err == nil {
+		t.Error("stop() a trader without a runtime should fail")
+	}
+	if err := stop(-2); err == nil {
+		t.Error("stop() an unknown trader should fail")
+	}
+}
+
+func TestRestartOnFailure(t *testing.T) {
+	restartBackoff = 10 * time.Millisecond
+	defer func() { restartBackoff = time.Second }()
+	id, _ := newMockTrader(t, `
+function main() {
+	G.Log("started");
+	throw "crashed";
+}`)
+	if err := model.DB.Model(&model.Trader{}).Where("id = ?", id).Updates(map[string]interface{}{"restart_policy": constant.RestartOnFailure, "max_restarts": 2}).Error; err != nil {
+		t.Fatal(err)
+	}
+	runTrader(t, id, "The trader restarted 2 times in 1h0m0s, it will not be restarted, reason: crashed")
+	if logs := waitLogs(t, id, "message = ?", "started", 3); len(logs) != 3 {
+		t.Errorf("the trader started %v times, want 3", len(logs))
+	}
+	if logs := waitLogs(t, id, "message LIKE ?", "Restarting the trader in %", 2); len(logs) != 2 || logs[0].Message != "Restarting the trader in 10ms (restart 1), reason: crashed" || logs[1].Message != "Restarting the trader in 20ms (restart 2), reason: crashed" {
+		t.Errorf("logs = %+v", logs)
+	}
+}
+
+// waitLogs wait for at least count logs of the trader which match the condition, the logs are saved asynchronously
+func waitLogs(t *testing.T, id int64, query string, arg interface{}, count int) []model.Log {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		logs := []model.Log{}
+		if err := model.DB.Where("trader_id = ? AND "+query, id, arg).Order("timestamp").Find(&logs).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(logs) >= count || time.Now().After(deadline) {
+			return logs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCancelRestart(t *testing.T) {
+	id, _ := newMockTrader(t, `
+function main() {
+	G.Log("started");
+}`)
+	if err := model.DB.Model(&model.Trader{}).Where("id = ?", id).Update("restart_policy", constant.RestartAlways).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := run(id); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, id, constant.TraderRestarting, time.Second)
+	if err := Switch(id); err != nil {
+		t.Fatal(err)
+	}
+	if s := GetTraderState(id); s != constant.TraderStopped {
+		t.Errorf("state = %v, want stopped", s)
+	}
+	if cancelRestart(id) {
+		t.Error("the restart should be canceled")
+	}
+}
+
+// isRunning get the persisted run state of a trader
+func isRunning(t *testing.T, id int64) bool {
+	tr := model.Trader{}
+	if err := model.DB.First(&tr, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return tr.Running
+}
+
+func TestResume(t *testing.T) {
+	resumeInterval = 10 * time.Millisecond
+	defer func() { resumeInterval = time.Second }()
+	model.DB.Model(&model.Trader{}).Where("running = ?", true).Update("running", false)
+	id1, _ := newMockTrader(t, `
+function main() {
+	G.Sleep(60000);
+}`)
+	id2, _ := newMockTrader(t, `
+function main() {
+	G.Log("done");
+}`)
+	for _, id := range []int64{id1, id2} {
+		if err := model.SetTraderRunning(id, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Resume()
+	waitTrader(t, id2, "Resumed after restart")
+	waitTrader(t, id2, "done")
+	if GetTraderState(id1) != constant.TraderRunning || !isRunning(t, id1) {
+		t.Errorf("trader %v should be resumed", id1)
+	}
+	// 自行退出的策略服务重启后不再恢复
+	deadline := time.Now().Add(time.Second)
+	for isRunning(t, id2) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if isRunning(t, id2) {
+		t.Errorf("trader %v exited, it should not be resumed", id2)
+	}
+	// 主动停止的策略服务重启后不再恢复
+	if err := Switch(id1); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, id1, constant.TraderStopped, time.Second)
+	if isRunning(t, id1) {
+		t.Errorf("trader %v is stopped, it should not be resumed", id1)
+	}
+	if err := Switch(id1); err != nil || !isRunning(t, id1) {
+		t.Errorf("Switch() = %v, the trader should be resumed", err)
+	}
+	stop(id1)
+	waitState(t, id1, constant.TraderStopped, time.Second)
+}
+
+func TestRegistry(t *testing.T) {
+	r := &stateRegistry{entries: make(map[int64]*registryEntry), subs: make(map[int]chan StateChange)}
+	changes, unsubscribe := r.subscribe(10)
+	if _, ok := r.transition(-10, nil, constant.TraderRunning, constant.TraderStopped); ok {
+		t.Error("an idle trader can not be running")
+	}
+	if prev, ok := r.transition(-10, nil, constant.TraderStarting, constant.TraderIdle); !ok || prev != constant.TraderIdle {
+		t.Errorf("transition() = %v, %v", prev, ok)
+	}
+	g := &Global{}
+	r.setRuntime(-10, g)
+	if _, ok := r.transition(-10, &Global{}, constant.TraderRunning, constant.TraderStarting); ok {
+		t.Error("a stale runtime can not change the state")
+	}
+	if _, ok := r.transition(-10, g, constant.TraderRunning, constant.TraderStarting); !ok || r.state(-10) != constant.TraderRunning || r.runtime(-10) != g {
+		t.Errorf("state = %v, want running", r.state(-10))
+	}
+	for _, want := range []StateChange{{TraderID: -10, From: constant.TraderIdle, To: constant.TraderStarting}, {TraderID: -10, From: constant.TraderStarting, To: constant.TraderRunning}} {
+		select {
+		case c := <-changes:
+			if c.TraderID != want.TraderID || c.From != want.From || c.To != want.To {
+				t.Errorf("change = %+v, want %+v", c, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no change, want %+v", want)
+		}
+	}
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-changes; ok {
+		t.Error("the channel should be closed after unsubscribing")
+	}
+}
+
+func TestSwitchConcurrently(t *testing.T) {
+	id, _ := newMockTrader(t, `
+function main() {
+	G.Sleep(60000);
+}`)
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() { errs <- run(id) }()
+	}
+	started := 0
+	for i := 0; i < 10; i++ {
+		if <-errs == nil {
+			started++
+		}
+	}
+	if started != 1 {
+		t.Errorf("the trader started %v times, want 1", started)
+	}
+	if err := stop(id); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, id, constant.TraderStopped, time.Second)
+}
+
+func TestRequire(t *testing.T) {
+	for _, l := range []model.Algorithm{
+		{UserID: 1, Name: "mathx", Version: "1.9.0", Script: `exports.add = function(a, b) { return a + b; };`},
+		{UserID: 1, Name: "mathx", Version: "1.10.0", Script: `var local = 1; exports.add = function(a, b) { return a + b + local; };`},
+		{UserID: 2, Name: "fmtx", Version: "1.0.0", Shared: true, Script: `var m = require("mathx@1.9.0"); module.exports = function(a) { return "=" + m.add(a, 1); };`},
+		{UserID: 2, Name: "private", Script: `exports.x = 1;`},
+		{UserID: 1, Name: "cycle-a", Script: `require("cycle-b");`},
+		{UserID: 1, Name: "cycle-b", Script: `require("cycle-a");`},
+	} {
+		l.IsLibrary = true
+		if err := model.DB.Create(&l).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	id, _ := newMockTrader(t, `
+function main() {
+	var fmt = require("fmtx");
+	G.Log(require("mathx").add(1, 2), " ", require("mathx@1.9.0").add(1, 2), " ", fmt(1), " ", typeof local, " ", require("fmtx") === fmt);
+	try {
+		require("private");
+	} catch (e) {
+		G.Log(e.message);
+	}
+	try {
+		require("cycle-a");
+	} catch (e) {
+		G.Log("cycle");
+	}
+}`)
+	runTrader(t, id, "4 3 =2 undefined true")
+	waitTrader(t, id, "Can not find the library: private")
+	waitTrader(t, id, "cycle")
+
+	m := newModules(1)
+	vm := goja.New()
+	vm.Set("require", m.requirer(vm))
+	_, err := vm.RunString(`require("cycle-a")`)
+	if err == nil || !strings.Contains(err.Error(), "Circular require: cycle-a@ -> cycle-b@ -> cycle-a@") {
+		t.Errorf("err = %v, want a circular require", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	id, _ := newMockTrader(t, `
+function main() {
+	var grid = G.Get("grid");
+	if (grid === null) {
+		G.Set("grid", {levels: [29000, 30000], profit: 1.5});
+		G.Log("saved");
+		return;
+	}
+	G.Log(grid.levels[1], " ", grid.profit, " ", G.Keys().join(","), " ", G.Del("grid"), " ", G.Del("grid"), " ", G.Get("grid") === null);
+}`)
+	runTrader(t, id, "saved")
+	runTrader(t, id, "30000 1.5 grid true false true")
+}
+
+func TestLoadRecords(t *testing.T) {
+	candles := []model.Candle{{Time: 1000, Close: 1}, {Time: 2000, Close: 2}, {Time: 3000, Close: 3}}
+	if err := model.SaveCandles(constant.Okex, "BTC/USDT:USDT", "M", candles); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := newMockTrader(t, `
+function main() {
+	var records = G.LoadRecords("btc/usdt/swap", "M", 2000, 4000);
+	G.Log(records.length, " ", records[0].Close, " ", G.LoadRecords("BTC/USDT", "M", 0, 4000).length);
+}`)
+	runTrader(t, id, "2 2 0")
+}
+
+func TestParseCron(t *testing.T) {
+	from := time.Date(2024, 1, 31, 23, 59, 30, 0, time.UTC) // 星期三
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/10 * * * * *", time.Date(2024, 1, 31, 23, 59, 40, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * 7", time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 12 15 * 0", time.Date(2024, 2, 4, 12, 0, 0, 0, time.UTC)},
+		{"5,10-20/5 3 * * *", time.Date(2024, 2, 1, 3, 5, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		c, err := parseCron(test.spec)
+		if err != nil {
+			t.Errorf("parseCron(%q) error: %v", test.spec, err)
+			continue
+		}
+		if next := c.next(from); !next.Equal(test.next) {
+			t.Errorf("next(%q) = %v, want %v", test.spec, next, test.next)
+		}
+	}
+	// 不是整小时偏移的时区
+	ist := time.FixedZone("IST", 5*3600+1800)
+	from = time.Date(2024, 1, 31, 23, 59, 30, 0, ist)
+	for spec, next := range map[string]time.Time{
+		"0 * * * *":    time.Date(2024, 2, 1, 0, 0, 0, 0, ist),
+		"15 * * * *":   time.Date(2024, 2, 1, 0, 15, 0, 0, ist),
+		"0 9 * * *":    time.Date(2024, 2, 1, 9, 0, 0, 0, ist),
+		"*/20 3 * * *": time.Date(2024, 2, 1, 3, 0, 0, 0, ist),
+	} {
+		c, err := parseCron(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.next(from); !got.Equal(next) {
+			t.Errorf("next(%q) in IST = %v, want %v", spec, got, next)
+		}
+	}
+	from = time.Date(2024, 1, 31, 10, 20, 0, 0, ist)
+	if c, _ := parseCron("0 * * * *"); !c.next(from).Equal(time.Date(2024, 1, 31, 11, 0, 0, 0, ist)) {
+		t.Errorf("next(\"0 * * * *\") in IST = %v, want 11:00", c.next(from))
+	}
+	if c, _ := parseCron("0 0 31 2 *"); !c.next(from).IsZero() {
+		t.Errorf("February 31 should never run")
+	}
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) should fail", spec)
+		}
+	}
+}
+
+func TestTimersOnVirtualClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewVirtualClock(start)
+	g := &Global{
+		ctx:     goja.New(),
+		monitor: &monitor{},
+		clock:   clock,
+		timers:  newTimers(),
+	}
+	g.setContext(context.WithCancel(context.Background()))
+	g.ctx.Set("G", g)
+	if _, err := g.ctx.RunString(`
+var calls = [];
+function main() {
+	var interval = G.SetInterval(function(name) { calls.push(name); }, 1000, "i");
+	G.SetTimeout(function() { calls.push("t"); }, 2500);
+	G.ClearTimer(G.SetTimeout(function() { calls.push("cleared"); }, 100));
+	var cron = G.Schedule("*/5 * * * * *", function() {
+		calls.push("c");
+		if (calls.length > 8) G.ClearTimer(cron);
+	});
+	G.Sleep(6000);
+	G.ClearTimer(interval);
+}
+main();`); err != nil {
+		t.Fatal(err)
+	}
+	g.loop()
+	calls, _ := g.ctx.RunString(`calls.join(",")`)
+	if calls.String() != "i,i,t,i,i,i,c,i,c" {
+		t.Errorf("calls = %v", calls)
+	}
+	if now := clock.Now(); !now.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("clock = %v, want 10s after the start", now)
+	}
+	if _, ok := g.timers.nextAt(); ok {
+		t.Errorf("all timers should be cleared")
+	}
+}
+
+func TestTimersAfterMain(t *testing.T) {
+	id, _ := newMockTrader(t, `
+var count = 0;
+function main() {
+	var id = G.SetInterval(function() {
+		count++;
+		if (count == 3) {
+			G.ClearTimer(id);
+			G.Log("ticks ", count);
+		}
+	}, 10);
+	G.AddTask("group", "task");
+	G.ExecTasks("group");
+}
+function task() {
+	return G.SetTimeout(function() {}, 10);
+}`)
+	runTrader(t, id, "ticks 3")
+	if s := GetTraderRuntime(id); s.LastError != "SetTimeout(), timers can not be used in tasks" {
+		t.Errorf("last error = %q", s.LastError)
+	}
+}
+
+func TestES2015AndTasks(t *testing.T) {
+	id, _ := newMockTrader(t, `
+G.Log("loaded");
+const factor = 10;
+const scale = n => n * factor;
+class Grid {
+	constructor(step) { this.step = step; }
+	levels(n) { return [...Array(n).keys()].map(i => i * this.step); }
+}
+let changed = false;
+var state = {};
+function main() {
+	changed = true;
+	state.offset = 1;
+	G.AddTask("group", "task", 2);
+	G.AddTask("group", "task", 3);
+	G.AddTask("group", "broken");
+	state.offset = 2;
+	const [a, b, c] = G.ExecTasks("group");
+	G.Log(new Grid(5).levels(3).join(","), " ", a.sum, " ", b.changed, " ", c, " ", `+"`${G.ExecTasks(\"none\")}`"+`);
+}
+function task(n) {
+	return {sum: scale(n) + state.offset, changed};
+}
+function broken() {
+	throw new Error("broken task");
+}`)
+	runTrader(t, id, "0,5,10 21 true false ")
+	logs := []model.Log{}
+	model.DB.Where("trader_id = ? AND message = ?", id, "Error: broken task").Find(&logs)
+	if len(logs) != 1 {
+		t.Errorf("the error of the task is not logged")
+	}
+	// 顶层代码只在主虚拟机中执行一次
+	model.DB.Where("trader_id = ? AND message = ?", id, "loaded").Find(&logs)
+	if len(logs) != 1 {
+		t.Errorf("the top-level code runs %v times, want 1", len(logs))
+	}
+}
+
+// waitLog wait for a message to be logged by a running trader
+func waitLog(t *testing.T, id int64, message string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		logs := []model.Log{}
+		if err := model.DB.Where("trader_id = ? AND message = ?", id, message).Find(&logs).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(logs) > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			all := []model.Log{}
+			model.DB.Where("trader_id = ?", id).Find(&all)
+			t.Fatalf("the trader did not log %q, logs = %+v", message, all)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEventDriven(t *testing.T) {
+	id, s := newMockTrader(t, `
+G.Subscribe("BTC/USDT", "M");
+G.SetEventInterval(20);
+var ticks = 0, timers = 0;
+function onTick(ticker, symbol) {
+	if (ticks++ == 0) {
+		G.Log("tick ", symbol, " ", ticker.Buy, " ", ticker.Sell);
+		E.Trade("BUY", "BTC/USDT", 29000, 0.125);
+	}
+}
+function onOrder(order) {
+	G.Log("order ", order.Price, " ", order.Amount, " ", order.DealAmount);
+}
+function onBar(symbol, period, record) {
+	G.Log("bar ", period, " ", record.Time, " ", record.Close);
+}
+function onTimer() {
+	timers++;
+}`)
+	s.SetCandles("BTC-USDT", "1m", []okexmock.Candle{
+		{Ts: 60000, Open: 1, High: 1, Low: 1, Close: 1, Volume: 1},
+		{Ts: 120000, Open: 2, High: 2, Low: 2, Close: 2, Volume: 1},
+	})
+	if err := run(id); err != nil {
+		t.Fatal(err)
+	}
+	waitLog(t, id, "tick BTC/USDT 29990 30010")
+	waitLog(t, id, "order 29000 0.125 0")
+	s.SetCandles("BTC-USDT", "1m", []okexmock.Candle{
+		{Ts: 60000, Open: 1, High: 1, Low: 1, Close: 1, Volume: 1},
+		{Ts: 120000, Open: 2, High: 2, Low: 2, Close: 2.5, Volume: 1},
+		{Ts: 180000, Open: 3, High: 3, Low: 3, Close: 3, Volume: 1},
+	})
+	waitLog(t, id, "bar M 120000 2.5")
+	// 吃掉买一和挂单后订单关闭, 再通知一次最终状态
+	s.AddLiquidity("BTC-USDT", "sell", 29000, 0.625)
+	waitLog(t, id, "order 29000 0.125 0.125")
+	if err := stop(id); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, id, constant.TraderStopped, 5*time.Second)
+	if timers := traderRegistry.runtime(id).ctx.Get("timers"); timers.ToInteger() < 2 {
+		t.Errorf("onTimer() called %v times", timers)
+	}
+}
+
+func TestTalib(t *testing.T) {
+	errors := []string{}
+	vm := goja.New()
+	vm.Set("Talib", Talib{logError: func(msgs ...interface{}) { errors = append(errors, fmt.Sprint(msgs...)) }})
+	records := []api.Record{}
+	for i := 0; i < 40; i++ {
+		p := float64(100 + i%10)
+		records = append(records, api.Record{Time: int64(i), Open: p, High: p + 2, Low: p - 2, Close: p + 1, Volume: 10})
+	}
+	vm.Set("records", records)
+	result, err := vm.RunString(`
+var closes = [];
+for (var i = 0; i < records.length; i++) closes.push(records[i].Close);
+var objects = closes.map(function(c) { return {High: c + 1, Low: c - 3, Close: c, Open: c - 1, Volume: 10}; });
+[
+	Talib.Sma([1, 2, 3, 4], 2)[3],
+	Talib.Sma(records, 5)[39] == Talib.Sma(closes, 5)[39],
+	Talib.Sma(records, 5, "High")[39] - Talib.Sma(records, 5)[39],
+	Talib.Macd(records).hist.length,
+	Talib.Atr(records, 14)[39] == Talib.Atr(objects, 14)[39],
+	Talib.Kdj(records).j.length,
+	Talib.BBands(closes, 20).upper[39] == Talib.Bool(closes, 20).upper[39],
+	Talib.CdlEngulfing([{Open: 10, High: 10.5, Low: 8.5, Close: 9}, {Open: 8.8, High: 11, Low: 8.5, Close: 10.6}])[1],
+	Talib.Atr(closes, 14),
+	Talib.Sma(closes, 5, "Weight"),
+].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "3.5,true,1,40,true,40,true,100,false,false" {
+		t.Errorf("result = %v", result)
+	}
+	if len(errors) != 2 || errors[0] != "Talib.Atr(), the data should be a record array" || errors[1] != "Talib.Sma(), unrecognized field: Weight" {
+		t.Errorf("errors = %q", errors)
+	}
+}
+
+func TestTalibParams(t *testing.T) {
+	errors := []string{}
+	vm := goja.New()
+	vm.Set("Talib", Talib{logError: func(msgs ...interface{}) { errors = append(errors, fmt.Sprint(msgs...)) }})
+	result, err := vm.RunString(`
+var closes = [];
+for (var i = 0; i < 60; i++) closes.push(100 + i % 7);
+[
+	Talib.T3(closes, 5, 0)[59] != Talib.T3(closes, 5)[59],
+	Talib.BBands(closes, 20, 2, 2, 1).middle[59] == Talib.Ema(closes, 20)[59],
+	Talib.Sma(closes, undefined)[59] == Talib.Sma(closes, 30)[59],
+	Talib.Ema(closes, 0),
+	Talib.Sma(closes, 2.5),
+	Talib.BBands(closes, 20, -1),
+	Talib.Apo(closes, 12, 26, 9),
+	Talib.Rsi(closes, 14, 1),
+	Talib.Sma(closes, {}),
+].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "true,true,true,false,false,false,false,false,false" {
+		t.Errorf("result = %v", result)
+	}
+	want := []string{
+		"Talib.Ema(), the parameter 1: 0 should be an integer >= 1",
+		"Talib.Sma(), the parameter 1: 2.5 should be an integer >= 1",
+		"Talib.BBands(), the parameter 2: -1 should be a finite number >= 0",
+		"Talib.Apo(), the parameter 3: 9 should be an integer in 0-8",
+		"Talib.Rsi(), too many parameters, at most 1",
+		"Talib.Sma(), the parameter 1 should be a number",
+	}
+	if strings.Join(errors, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors = %q", errors)
+	}
+}
+
+func TestIndicators(t *testing.T) {
+	vm := goja.New()
+	vm.Set("Talib", Talib{})
+	vm.Set("Indicators", newIndicators(vm))
+	records := []api.Record{}
+	for i := 0; i < 60; i++ {
+		p := 100 + 10*math.Sin(float64(i)/5)
+		records = append(records, api.Record{Time: int64(i), Open: p - 1, High: p + 2, Low: p - 2, Close: p, Volume: 10})
+	}
+	vm.Set("records", records)
+	result, err := vm.RunString(`
+var close = function(a, b) { return Math.abs(a - b) < 1e-9; };
+var ema = Indicators.Ema(10), rsi = Indicators.Rsi(), atr = Indicators.Atr(14), boll = Indicators.BBands(20, 2), macd = Indicators.Macd();
+var first = ema.value();
+for (var i = 0; i < records.length; i++) {
+	// 未完成的K线先更新一次错误的价格, 再被同一时间的K线替换
+	ema.update({Time: records[i].Time, Close: 0});
+	ema.update(records[i]);
+	rsi.update(records[i]);
+	atr.update(records[i]);
+	boll.update(records[i]);
+	macd.update(records[i]);
+}
+var saved = JSON.stringify(ema);
+var restored = Indicators.restore(JSON.parse(saved));
+var next = {Time: 60, Close: 90};
+[
+	first === null,
+	close(ema.value(), Talib.Ema(records, 10)[59]),
+	close(rsi.value(), Talib.Rsi(records, 14)[59]),
+	close(atr.value(), Talib.Atr(records, 14)[59]),
+	close(boll.value().upper, Talib.BBands(records, 20, 2, 2).upper[59]),
+	macd.value().hist > -100,
+	close(restored.value(), ema.value()),
+	close(restored.update(next), ema.update(next)),
+].join(",")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.String() != "true,true,true,true,true,true,true,true" {
+		t.Errorf("result = %v", result)
+	}
+	if _, err := vm.RunString(`var e = Indicators.Ema(3); e.update({Time: 5, Close: 1}); e.update({Time: 4, Close: 1});`); err == nil || !strings.Contains(err.Error(), "IndicatorError: Ema.update(), the time 4 is earlier than the forming bar 5") {
+		t.Errorf("err = %v", err)
+	}
+	// 无效的参数抛出 IndicatorError, 避免计算出 NaN 之后无法保存
+	for script, message := range map[string]string{
+		`Indicators.Sma(0)`:               "IndicatorError: Sma(), the parameter 1: 0 should be an integer >= 1",
+		`Indicators.BBands(0)`:            "IndicatorError: BBands(), the parameter 1: 0 should be an integer >= 1",
+		`Indicators.BBands(20, Infinity)`: "IndicatorError: BBands(), the parameter 2: +Inf should be a finite number >= 0",
+		`Indicators.Macd(12, 26, 1.5)`:    "IndicatorError: Macd(), the parameter 3: 1.5 should be an integer >= 1",
+		`Indicators.restore({type: "Ema", params: [-1], base: {}, current: {}})`:                                "IndicatorError: restore(), invalid indicator state, the parameter 1: -1 should be an integer >= 1",
+		`Indicators.restore({type: "Sma", params: [3], base: {period: 0}, current: {period: 3}})`:               "IndicatorError: restore(), invalid indicator state, the parameter 1 of the state is 0, want 3",
+		`Indicators.restore({type: "BBands", params: [20, 2], base: {period: 20, dev: 2}, current: {dev: -1}})`: "IndicatorError: restore(), invalid indicator state, the parameter 2 of the state is -1, want 2",
+	} {
+		if _, err := vm.RunString(script); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("%v error = %v, want %q", script, err, message)
+		}
+	}
+	if _, err := vm.RunString(`var b = Indicators.BBands(20, 0); b.update(1); JSON.stringify(b)`); err != nil {
+		t.Errorf("BBands with 0 deviation error = %v", err)
+	}
+}
+
+// addMockExchange bind another mock okex exchange named "mock" to the trader
+func addMockExchange(t *testing.T, id int64, balances map[string]float64) *okexmock.Server {
+	s := okexmock.NewServer()
+	s.AddAccount("access", "secret", "passphrase", balances)
+	s.Start()
+	t.Cleanup(s.Close)
+	allowHost(s.URL)
+	exchange := model.Exchange{
+		UserID:     1,
+		Name:       "mock",
+		Type:       constant.Okex,
+		AccessKey:  "access",
+		SecretKey:  "secret",
+		Passphrase: "passphrase",
+		Host:       s.URL,
+	}
+	if err := model.DB.Create(&exchange).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := model.DB.Create(&model.TraderExchange{TraderID: id, ExchangeID: exchange.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRouteOrder(t *testing.T) {
+	id, s := newMockTrader(t, `
+function main() {
+	var ticker = G.AggregatedTicker("btc/usdt");
+	G.Log("ticker ", ticker.SellIndex, " ", ticker.Sell, " ", ticker.BuyIndex, " ", ticker.Buy);
+	var result = G.RouteOrder("BUY", "btc/usdt", 0.8, 30010);
+	var children = result.Children.map(function(c) { return c.Exchange + c.Index + ":" + c.Amount + "@" + c.Price; });
+	G.Log("routed ", result.StockType, " ", result.Routed, " ", children.join(","));
+}`)
+	// 两个交易所同名, 第二个交易所的报价更好, 但是余额只够买 0.1
+	s2 := addMockExchange(t, id, map[string]float64{"USDT": 3000})
+	s2.AddLiquidity("BTC-USDT", "sell", 30000, 0.3)
+	s2.AddLiquidity("BTC-USDT", "buy", 29980, 0.3)
+	runTrader(t, id, "routed BTC/USDT 0.6 mock1:0.1@30000,mock0:0.5@30010")
+	waitLog(t, id, "ticker 1 30000 0 29990")
+	if orders := s.Orders("access"); len(orders) != 1 || orders[0].Sz != 0.5 {
+		t.Errorf("orders of the first exchange = %+v, want one order of 0.5", orders)
+	}
+	if orders := s2.Orders("access"); len(orders) != 1 || orders[0].Sz != 0.1 {
+		t.Errorf("orders of the second exchange = %+v, want one order of 0.1", orders)
+	}
+}
+
+func TestRouteOrderError(t *testing.T) {
+	id, s := newMockTrader(t, `
+function main() {
+	var result = G.RouteOrder("SELL", "BTC/USDT", 0.75, 0);
+	var children = result.Children.map(function(c) { return c.Index + ":" + c.Amount + ":" + (c.ID ? "ok" : c.Error); });
+	G.Log("routed ", result.Routed, " ", children.join(","));
+	G.RouteOrder("HOLD", "BTC/USDT", 1, 0);
+}`)
+	s2 := addMockExchange(t, id, map[string]float64{"BTC": 1})
+	s2.AddLiquidity("BTC-USDT", "buy", 29995, 0.25)
+	s2.AddLiquidity("BTC-USDT", "sell", 30020, 0.25)
+	s2.Fail("/api/v5/trade/order", http.StatusOK, "51008", "Order failed. Insufficient balance")
+	runTrader(t, id, "routed 0.5 1:0.25:Trade() error, [51008] Order failed. Insufficient balance,0:0.5:ok")
+	if orders := s.Orders("access"); len(orders) != 1 || orders[0].Sz != 0.5 {
+		t.Errorf("orders = %+v, want one order of 0.5", orders)
+	}
+	if s := GetTraderRuntime(id); s.LastError != "RouteOrder(), unrecognized tradeType: HOLD" {
+		t.Errorf("last error = %q", s.LastError)
+	}
+}
+
+func TestNewExchangeHost(t *testing.T) {
+	e := model.Exchange{Name: "mock", Type: constant.Okex, Host: "http://127.0.0.1:1"}
+	if _, err := NewExchange(0, e); err == nil {
+		t.Fatal("NewExchange() with a host not in allowHosts should fail")
+	}
+	allowHost(e.Host)
+	if _, err := NewExchange(0, e); err != nil {
+		t.Errorf("NewExchange() error = %v", err)
+	}
+}